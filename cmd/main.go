@@ -1,18 +1,37 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	deepspec "github.com/commercetools/deepspec/pkg"
+	"github.com/commercetools/deepspec/pkg/codespec"
 	"github.com/spf13/cobra"
 )
 
+// config is the effective configuration, loaded before any command runs
+var config *deepspec.Config
+
 var rootCmd = &cobra.Command{
 	Use:   "deepspec",
 	Short: "DeepSpec - Better spec driven development",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		var err error
+		if config, err = deepspec.LoadConfig(); err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+		if err := config.ApplyFlags(cmd.Flags()); err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+		deepspec.SetLogFile(config.LogFile)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// Default: start the TUI
-		if err := deepspec.StartTUI(); err != nil {
+		if err := deepspec.StartTUI(config); err != nil {
 			log.Fatalf("TUI error: %v", err)
 		}
 	},
@@ -22,15 +41,348 @@ var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start the MCP server",
 	Run: func(cmd *cobra.Command, args []string) {
-		server := deepspec.NewServer()
+		server := deepspec.NewServer(config)
 		if err := server.Start(); err != nil {
 			log.Fatalf("Server error: %v", err)
 		}
 	},
 }
 
+var extractCmd = &cobra.Command{
+	Use:   "extract [dir]",
+	Short: "Extract code specifications from Go, Python, TypeScript, JavaScript, Rust or Java source",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+		out, _ := cmd.Flags().GetString("out")
+
+		extractors, err := extractorsFromFlags(cmd, root)
+		if err != nil {
+			log.Fatalf("Extract error: %v", err)
+		}
+		specs, err := deepspec.ExtractSpecs(root, extractors...)
+		if err != nil {
+			log.Fatalf("Extract error: %v", err)
+		}
+
+		if out == "-" {
+			for _, spec := range specs {
+				data, err := spec.Marshal()
+				if err != nil {
+					log.Fatalf("Extract error: %v", err)
+				}
+				os.Stdout.Write(data)
+			}
+			return
+		}
+		if err := deepspec.WriteSpecs(specs, out); err != nil {
+			log.Fatalf("Extract error: %v", err)
+		}
+		log.Printf("Wrote %d specs to %s", len(specs), out)
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <paths...>",
+	Short: "Validate spec files against the code-spec schema",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := deepspec.FindSpecFiles(args)
+		if err != nil {
+			log.Fatalf("Validate error: %v", err)
+		}
+
+		failed := 0
+		for _, file := range files {
+			violations, err := deepspec.ValidateSpecFile(file)
+			if err != nil {
+				log.Fatalf("Validate error: %v", err)
+			}
+			for _, violation := range violations {
+				fmt.Printf("%s:%s\n", file, violation.Error())
+			}
+			if len(violations) > 0 {
+				failed++
+			}
+		}
+
+		fmt.Printf("%d of %d spec files valid\n", len(files)-failed, len(files))
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var generateCmd = &cobra.Command{
+	Use:   "generate <spec.json>",
+	Short: "Generate Go source from a spec file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		out, _ := cmd.Flags().GetString("out")
+
+		spec, err := codespec.ReadFile(args[0])
+		if err != nil {
+			log.Fatalf("Generate error: %v", err)
+		}
+		src, err := deepspec.GenerateGo(spec)
+		if err != nil {
+			log.Fatalf("Generate error: %v", err)
+		}
+
+		if out == "-" {
+			os.Stdout.Write(src)
+			return
+		}
+		if err := os.WriteFile(out, src, 0644); err != nil {
+			log.Fatalf("Generate error: %v", err)
+		}
+	},
+}
+
+var roundtripCmd = &cobra.Command{
+	Use:   "roundtrip [dir]",
+	Short: "Check that specs capture the source: extract, generate, compile and compare",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+		threshold, _ := cmd.Flags().GetFloat64("threshold")
+
+		report, err := deepspec.Roundtrip(root, threshold)
+		if err != nil {
+			log.Fatalf("Roundtrip error: %v", err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Roundtrip error: %v", err)
+		}
+		if !report.Passed {
+			os.Exit(1)
+		}
+	},
+}
+
+var driftCmd = &cobra.Command{
+	Use:   "drift [source]",
+	Short: "Detect specs that no longer match their source",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+		format, _ := cmd.Flags().GetString("format")
+		fix, _ := cmd.Flags().GetBool("fix")
+
+		extractors, err := extractorsFromFlags(cmd, root)
+		if err != nil {
+			log.Fatalf("Drift error: %v", err)
+		}
+		report, err := deepspec.DetectDrift(root, config.Specs, fix, extractors...)
+		if err != nil {
+			log.Fatalf("Drift error: %v", err)
+		}
+
+		switch format {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				log.Fatalf("Drift error: %v", err)
+			}
+		case "text":
+			fmt.Print(report.String())
+		default:
+			log.Fatalf("Unknown format %q, expected text or json", format)
+		}
+
+		if report.HasUnfixedDrift() {
+			os.Exit(1)
+		}
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Show semantic changes between two specs or spec directories",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+
+		var diffs []deepspec.FileDiff
+		oldInfo, errOld := os.Stat(args[0])
+		newInfo, errNew := os.Stat(args[1])
+		if errOld == nil && errNew == nil && !oldInfo.IsDir() && !newInfo.IsDir() {
+			old, err := codespec.ReadFile(args[0])
+			if err != nil {
+				log.Fatalf("Diff error: %v", err)
+			}
+			current, err := codespec.ReadFile(args[1])
+			if err != nil {
+				log.Fatalf("Diff error: %v", err)
+			}
+			if changes := deepspec.DiffSpecs(old, current); len(changes) > 0 {
+				diffs = append(diffs, deepspec.FileDiff{File: current.File, Status: "modified", Changes: changes})
+			}
+		} else {
+			old, err := deepspec.LoadSpecSet(args[0])
+			if err != nil {
+				log.Fatalf("Diff error: %v", err)
+			}
+			current, err := deepspec.LoadSpecSet(args[1])
+			if err != nil {
+				log.Fatalf("Diff error: %v", err)
+			}
+			diffs = deepspec.DiffSpecSets(old, current)
+		}
+
+		switch format {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if diffs == nil {
+				diffs = []deepspec.FileDiff{}
+			}
+			if err := enc.Encode(diffs); err != nil {
+				log.Fatalf("Diff error: %v", err)
+			}
+		case "text":
+			for _, diff := range diffs {
+				fmt.Printf("%s: %s\n", diff.File, diff.Status)
+				for _, change := range diff.Changes {
+					fmt.Printf("  %s\n", change)
+				}
+			}
+		default:
+			log.Fatalf("Unknown format %q, expected text or json", format)
+		}
+	},
+}
+
+// addTransportFlags adds the flags selecting the MCP transport to cmd
+func addTransportFlags(cmd *cobra.Command) {
+	cmd.Flags().String("transport", deepspec.TransportHTTP, "MCP transport: "+strings.Join(deepspec.Transports, ", "))
+	cmd.Flags().String("addr", deepspec.DefaultServerAddr, "Address of the sse and http transports, host:port")
+}
+
+// addChatFlags adds the flags configuring the chat of the TUI to cmd
+func addChatFlags(cmd *cobra.Command) {
+	cmd.Flags().String("server-cmd", "", "Command starting the MCP server for the stdio transport (default: deepspec server --transport stdio)")
+	cmd.Flags().String("provider", deepspec.ProviderVertex, "LLM provider: "+strings.Join(deepspec.ProviderNames(), ", "))
+	cmd.Flags().String("model", "", "Model name of the active provider (default: vertex.model or openai.model)")
+	cmd.Flags().String("system-prompt", "", "File with the base rules of the system prompt")
+	cmd.Flags().String("context", "", "File describing the project, added to the system prompt")
+	cmd.Flags().String("spec", "", "Spec file the chat works on, added to the system prompt")
+	cmd.Flags().Int("max-steps", deepspec.DefaultMaxSteps, "Rounds of tool calls the model may make per message, 0 for no limit")
+	cmd.Flags().Duration("tool-timeout", deepspec.DefaultToolTimeout, "Time a tool call may take, 0 for no limit")
+	cmd.Flags().String("cassette", "", "Cassette file recording or replaying the chat traffic")
+	cmd.Flags().String("cassette-mode", "", "Cassette mode: record or replay")
+}
+
+// addSpecsFlag adds the flags selecting the specs directory of the server and
+// the source directory they were extracted from
+func addSpecsFlag(cmd *cobra.Command) {
+	cmd.Flags().String("specs", deepspec.DefaultSpecsDir, "Directory containing the specs served by the spec tools")
+	cmd.Flags().String("source", ".", "Directory the specs were extracted from, read by the spec prompts")
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value came from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, value := range config.Values() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", value.Key, value.Value, value.Source)
+		}
+		if err := w.Flush(); err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+	},
+}
+
+// extractorsFromFlags creates the extractors selected by the --lang, --exec,
+// --match and --python flags of cmd
+func extractorsFromFlags(cmd *cobra.Command, root string) ([]deepspec.Extractor, error) {
+	languages, _ := cmd.Flags().GetStringSlice("lang")
+	command, _ := cmd.Flags().GetString("exec")
+	patterns, _ := cmd.Flags().GetStringSlice("match")
+	interpreter, _ := cmd.Flags().GetString("python")
+
+	if command != "" {
+		if len(languages) != 1 {
+			return nil, fmt.Errorf("--exec needs exactly one --lang, got %d", len(languages))
+		}
+		deepspec.RegisterExternalExtractor(languages[0], command, patterns)
+	}
+
+	var extractors []deepspec.Extractor
+	for _, language := range languages {
+		extractor, err := deepspec.NewExtractor(language, root)
+		if err != nil {
+			return nil, err
+		}
+		if python, ok := extractor.(*deepspec.PythonExtractor); ok {
+			python.Interpreter = interpreter
+		}
+		extractors = append(extractors, extractor)
+	}
+	return extractors, nil
+}
+
+// addExtractorFlags adds the flags selecting extractors to cmd
+func addExtractorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("lang", []string{codespec.LanguageGo}, "Source languages, comma separated: "+strings.Join(deepspec.ExtractorLanguages(), ", "))
+	cmd.Flags().String("exec", "", "External extractor command for --lang, reading a JSON request on stdin and printing the spec")
+	cmd.Flags().StringSlice("match", nil, "File name patterns handled by the --exec extractor, e.g. *.kt")
+	cmd.Flags().String("python", "", "Python interpreter to parse with instead of the built-in parser, e.g. python3")
+}
+
 func init() {
+	rootCmd.PersistentFlags().String("log-file", "out.log", "File receiving the debug log")
+	addTransportFlags(rootCmd)
+	addChatFlags(rootCmd)
+	addTransportFlags(serverCmd)
+	addSpecsFlag(serverCmd)
+	extractCmd.Flags().StringP("out", "o", "specs", "Output directory for spec files (- for stdout)")
+	addExtractorFlags(extractCmd)
+	generateCmd.Flags().StringP("out", "o", "-", "Output Go file (- for stdout)")
+	roundtripCmd.Flags().Float64("threshold", 0.9, "Minimum fidelity (0-1) required to pass")
+	driftCmd.Flags().String("specs", "specs", "Directory containing the committed specs")
+	driftCmd.Flags().String("format", "text", "Output format: text or json")
+	driftCmd.Flags().Bool("fix", false, "Rewrite stale specs and write missing ones")
+	addExtractorFlags(driftCmd)
+	diffCmd.Flags().String("format", "text", "Output format: text or json")
+
 	rootCmd.AddCommand(serverCmd)
+	rootCmd.AddCommand(extractCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(roundtripCmd)
+	rootCmd.AddCommand(driftCmd)
+	rootCmd.AddCommand(diffCmd)
+	// config show takes every override, to show its effect
+	addTransportFlags(configShowCmd)
+	addChatFlags(configShowCmd)
+	addSpecsFlag(configShowCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
 }
 
 func main() {
@@ -0,0 +1,318 @@
+package deepspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// Cassette modes
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// Cassette interaction kinds
+const (
+	interactionStartSession = "start_session"
+	interactionSend         = "send"
+	interactionStream       = "stream"
+	interactionListTools    = "list_tools"
+	interactionCallTool     = "call_tool"
+	interactionListPrompts  = "list_prompts"
+	interactionGetPrompt    = "get_prompt"
+)
+
+// Cassette records the traffic of the provider and MCP client layers to a
+// file, or replays a recording in place of the model and the server.
+// Replay serves the recorded responses in order and fails on any request
+// that differs from the recorded one. Tool calls run concurrently, so the
+// tool calls recorded in a row replay in any order, matched by request. A
+// nil cassette neither records nor replays.
+type Cassette struct {
+	path  string
+	mode  string
+	mutex sync.Mutex
+	// next is the index of the first interaction not replayed yet
+	next         int
+	interactions []Interaction
+	// replayed marks the interactions replayed out of order
+	replayed []bool
+}
+
+// Interaction is a request with its response, as stored in a cassette file
+type Interaction struct {
+	Kind     string          `json:"kind"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// Chunks are the pieces of streamed text, in order
+	Chunks []string `json:"chunks,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// cassetteFile is the document a cassette is saved as
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// OpenCassette opens the configured cassette: record starts an empty file,
+// replay reads an existing one. Without a mode it returns nil.
+func OpenCassette(config CassetteConfig) (*Cassette, error) {
+	switch config.Mode {
+	case "":
+		return nil, nil
+	case CassetteRecord, CassetteReplay:
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q, expected %s or %s", config.Mode, CassetteRecord, CassetteReplay)
+	}
+	if config.File == "" {
+		return nil, errors.New("cassette mode " + config.Mode + " needs a cassette file")
+	}
+
+	c := &Cassette{path: config.File, mode: config.Mode}
+	if config.Mode == CassetteRecord {
+		return c, c.save()
+	}
+
+	data, err := os.ReadFile(config.File)
+	if err != nil {
+		return nil, err
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", config.File, err)
+	}
+	c.interactions = file.Interactions
+	c.replayed = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// Replaying reports whether the cassette stands in for the model and server
+func (c *Cassette) Replaying() bool {
+	return c != nil && c.mode == CassetteReplay
+}
+
+// Recording reports whether traffic is being recorded
+func (c *Cassette) Recording() bool {
+	return c != nil && c.mode == CassetteRecord
+}
+
+// Remaining returns the number of recorded interactions not yet replayed
+func (c *Cassette) Remaining() int {
+	if !c.Replaying() {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	remaining := 0
+	for _, replayed := range c.replayed[c.next:] {
+		if !replayed {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// record appends an interaction and saves the cassette
+func (c *Cassette) record(kind string, request, response any, chunks []string, callErr error) error {
+	interaction := Interaction{Kind: kind, Chunks: chunks}
+	var err error
+	if interaction.Request, err = json.Marshal(request); err != nil {
+		return err
+	}
+	if callErr != nil {
+		interaction.Error = callErr.Error()
+	} else if interaction.Response, err = json.Marshal(response); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	return c.save()
+}
+
+// save writes the cassette file
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, append(data, '\n'), 0644)
+}
+
+// replay returns the next interaction, which must be of the given kind and
+// have an equal request. A tool call may take any unreplayed tool call of
+// the run at the next interaction with an equal request. A recorded error is
+// returned as the error.
+func (c *Cassette) replay(kind string, request any) (Interaction, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.next == len(c.interactions) {
+		return Interaction{}, fmt.Errorf("cassette %s: unexpected %s request #%d after the last recorded interaction", c.path, kind, c.next+1)
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return Interaction{}, err
+	}
+	index := c.next
+	if kind == interactionCallTool {
+		index = c.matchToolCall(data)
+	}
+	interaction := c.interactions[index]
+	if interaction.Kind != kind {
+		return Interaction{}, fmt.Errorf("cassette %s: unexpected %s request #%d, recorded %s", c.path, kind, index+1, interaction.Kind)
+	}
+	if !sameJSON(data, interaction.Request) {
+		var recorded bytes.Buffer
+		json.Compact(&recorded, interaction.Request)
+		return Interaction{}, fmt.Errorf("cassette %s: %s request #%d differs from the recording\n got: %s\nwant: %s", c.path, kind, index+1, data, recorded.Bytes())
+	}
+	c.replayed[index] = true
+	for c.next < len(c.interactions) && c.replayed[c.next] {
+		c.next++
+	}
+
+	if interaction.Error != "" {
+		return interaction, errors.New(interaction.Error)
+	}
+	return interaction, nil
+}
+
+// matchToolCall returns the index of the first unreplayed tool call with an
+// equal request in the run of tool calls at the next interaction, or the
+// next interaction when none matches
+func (c *Cassette) matchToolCall(request []byte) int {
+	for i := c.next; i < len(c.interactions) && c.interactions[i].Kind == interactionCallTool; i++ {
+		if !c.replayed[i] && sameJSON(request, c.interactions[i].Request) {
+			return i
+		}
+	}
+	return c.next
+}
+
+// sameJSON compares two JSON documents regardless of formatting
+func sameJSON(a, b []byte) bool {
+	var valueA, valueB any
+	if json.Unmarshal(a, &valueA) != nil || json.Unmarshal(b, &valueB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
+
+// cassetteCall replays a call from the cassette, or runs it and records it
+// when recording. decode reads a recorded response.
+func cassetteCall[T any](c *Cassette, kind string, request any, call func() (T, error), decode func(data json.RawMessage) (T, error)) (T, error) {
+	if c.Replaying() {
+		interaction, err := c.replay(kind, request)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		return decode(interaction.Response)
+	}
+
+	response, err := call()
+	if c.Recording() {
+		if recordErr := c.record(kind, request, response, nil, err); recordErr != nil {
+			LogToFile("Failed to record %s: %v", kind, recordErr)
+		}
+	}
+	return response, err
+}
+
+// decodeJSON reads a recorded response into a new value
+func decodeJSON[T any](data json.RawMessage) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// WrapProvider records the sessions of a provider, or replays them without
+// it. A nil cassette returns the provider unchanged.
+func (c *Cassette) WrapProvider(provider Provider) Provider {
+	if c == nil {
+		return provider
+	}
+	return &cassetteProvider{provider: provider, cassette: c}
+}
+
+// cassetteProvider records or replays the sessions of a provider, which is
+// nil when replaying
+type cassetteProvider struct {
+	provider Provider
+	cassette *Cassette
+}
+
+// cassetteSession records or replays a session
+type cassetteSession struct {
+	session  Session
+	cassette *Cassette
+}
+
+// Name returns the name of the recorded provider
+func (p *cassetteProvider) Name() string {
+	if p.provider == nil {
+		return CassetteReplay
+	}
+	return p.provider.Name()
+}
+
+// sessionRequest is the recorded start of a session
+type sessionRequest struct {
+	System string           `json:"system"`
+	Tools  []ToolDefinition `json:"tools"`
+}
+
+// StartSession records or replays the system instructions and tools a
+// session starts with
+func (p *cassetteProvider) StartSession(ctx context.Context, system string, tools []ToolDefinition) (Session, error) {
+	var session Session
+	request := sessionRequest{System: system, Tools: tools}
+	_, err := cassetteCall(p.cassette, interactionStartSession, request, func() (struct{}, error) {
+		var err error
+		session, err = p.provider.StartSession(ctx, system, tools)
+		return struct{}{}, err
+	}, decodeJSON[struct{}])
+	if err != nil {
+		return nil, err
+	}
+	return &cassetteSession{session: session, cassette: p.cassette}, nil
+}
+
+// Send records or replays a message and its response
+func (s *cassetteSession) Send(ctx context.Context, message Message) (*Response, error) {
+	return cassetteCall(s.cassette, interactionSend, message, func() (*Response, error) {
+		return s.session.Send(ctx, message)
+	}, decodeJSON[*Response])
+}
+
+// Stream records or replays a message with the chunks of its response
+func (s *cassetteSession) Stream(ctx context.Context, message Message, onText func(text string)) (*Response, error) {
+	if s.cassette.Replaying() {
+		interaction, err := s.cassette.replay(interactionStream, message)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range interaction.Chunks {
+			onText(chunk)
+		}
+		return decodeJSON[*Response](interaction.Response)
+	}
+
+	var chunks []string
+	response, err := s.session.Stream(ctx, message, func(text string) {
+		chunks = append(chunks, text)
+		onText(text)
+	})
+	if recordErr := s.cassette.record(interactionStream, message, response, chunks, err); recordErr != nil {
+		LogToFile("Failed to record %s: %v", interactionStream, recordErr)
+	}
+	return response, err
+}
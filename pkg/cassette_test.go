@@ -0,0 +1,236 @@
+package deepspec
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// parallelToolsScript calls a tool three times in one turn. The calls
+// finish in reverse order, so they are recorded in reverse order.
+const parallelToolsScript = `
+turns:
+  - tool_calls:
+      - name: sleep
+        args: {ms: 60}
+      - name: sleep
+        args: {ms: 30}
+      - name: sleep
+        args: {ms: 0}
+  - expect: slept 60ms
+    text: All done
+`
+
+// newTestMCPServer serves a sleep tool that waits the given milliseconds
+func newTestMCPServer() *server.MCPServer {
+	mcpServer := server.NewMCPServer("test", Version, server.WithToolCapabilities(true))
+	mcpServer.AddTool(mcp.NewTool("sleep", mcp.WithNumber("ms", mcp.Required())), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ms := request.GetInt("ms", 0)
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return mcp.NewToolResultText(fmt.Sprintf("slept %dms", ms)), nil
+	})
+	return mcpServer
+}
+
+// startCassetteChat starts a chat through a cassette. A recording chat plays
+// a script and calls an in-process server, a replaying one has neither.
+func startCassetteChat(t *testing.T, cassette *Cassette, script string) *Chat {
+	t.Helper()
+	ctx := context.Background()
+	mcpClient := &mcpClient{mutex: &sync.Mutex{}, cassette: cassette}
+	var provider Provider
+	if cassette.Recording() {
+		parsed, err := ParseFakeScript([]byte(script))
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider = NewFakeProvider(parsed)
+
+		c, err := client.NewInProcessClient(newTestMCPServer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		if err := c.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION}}); err != nil {
+			t.Fatal(err)
+		}
+		mcpClient.client = c
+	}
+
+	chat := NewChat(cassette.WrapProvider(provider), NewToolbox(mcpClient))
+	if err := chat.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return chat
+}
+
+func TestCassetteReplaysParallelToolCalls(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := OpenCassette(CassetteConfig{Mode: CassetteRecord, File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := startCassetteChat(t, recorder, parallelToolsScript).Send(context.Background(), "sleep")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 5 {
+		t.Run(fmt.Sprint("replay ", i+1), func(t *testing.T) {
+			player, err := OpenCassette(CassetteConfig{Mode: CassetteReplay, File: file})
+			if err != nil {
+				t.Fatal(err)
+			}
+			replayed, err := startCassetteChat(t, player, "").Send(context.Background(), "sleep")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replayed != recorded {
+				t.Errorf("replayed %q, recorded %q", replayed, recorded)
+			}
+			if remaining := player.Remaining(); remaining != 0 {
+				t.Errorf("%d interactions not replayed", remaining)
+			}
+		})
+	}
+}
+
+func TestCassetteReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := OpenCassette(CassetteConfig{Mode: CassetteRecord, File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []string
+	recorded, err := startCassetteChat(t, recorder, parallelToolsScript).Stream(context.Background(), "sleep", ChatEvents{
+		OnText: func(text string) { chunks = append(chunks, text) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// replay runs the chat against the recording
+		replay  func(chat *Chat) (string, error)
+		wantErr string
+	}{
+		{
+			name: "same requests",
+			replay: func(chat *Chat) (string, error) {
+				var replayed []string
+				text, err := chat.Stream(context.Background(), "sleep", ChatEvents{
+					OnText: func(text string) { replayed = append(replayed, text) },
+				})
+				if err == nil && !slices.Equal(replayed, chunks) {
+					t.Errorf("replayed chunks %q, recorded %q", replayed, chunks)
+				}
+				return text, err
+			},
+		},
+		{
+			name: "changed message",
+			replay: func(chat *Chat) (string, error) {
+				return chat.Stream(context.Background(), "sleep longer", ChatEvents{})
+			},
+			wantErr: "stream request #3 differs from the recording",
+		},
+		{
+			name: "other kind of request",
+			replay: func(chat *Chat) (string, error) {
+				return chat.Send(context.Background(), "sleep")
+			},
+			wantErr: "unexpected send request #3, recorded stream",
+		},
+		{
+			name: "extra request",
+			replay: func(chat *Chat) (string, error) {
+				if _, err := chat.Stream(context.Background(), "sleep", ChatEvents{}); err != nil {
+					return "", err
+				}
+				return chat.Stream(context.Background(), "sleep", ChatEvents{})
+			},
+			wantErr: "unexpected stream request #8 after the last recorded interaction",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player, err := OpenCassette(CassetteConfig{Mode: CassetteReplay, File: file})
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, err := tt.replay(startCassetteChat(t, player, ""))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != recorded {
+				t.Errorf("replayed %q, recorded %q", text, recorded)
+			}
+		})
+	}
+}
+
+func TestCassetteReplayChangedSession(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := OpenCassette(CassetteConfig{Mode: CassetteRecord, File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	startCassetteChat(t, recorder, parallelToolsScript)
+
+	player, err := OpenCassette(CassetteConfig{Mode: CassetteReplay, File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := NewChat(player.WrapProvider(nil), NewToolbox(&mcpClient{mutex: &sync.Mutex{}, cassette: player}))
+	chat.SetSystem("Other rules")
+	err = chat.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "start_session request #2 differs from the recording") {
+		t.Fatalf("error = %v, want a changed start_session request", err)
+	}
+}
+
+func TestOpenCassette(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	tests := []struct {
+		name    string
+		config  CassetteConfig
+		wantErr bool
+		wantNil bool
+	}{
+		{name: "off", config: CassetteConfig{File: missing}, wantNil: true},
+		{name: "record", config: CassetteConfig{Mode: CassetteRecord, File: filepath.Join(t.TempDir(), "new.json")}},
+		{name: "replay missing file", config: CassetteConfig{Mode: CassetteReplay, File: missing}, wantErr: true},
+		{name: "no file", config: CassetteConfig{Mode: CassetteRecord}, wantErr: true},
+		{name: "unknown mode", config: CassetteConfig{Mode: "rewind", File: missing}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cassette, err := OpenCassette(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (cassette == nil) != tt.wantNil {
+				t.Errorf("cassette = %v, want nil %v", cassette, tt.wantNil)
+			}
+		})
+	}
+}
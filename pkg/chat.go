@@ -0,0 +1,206 @@
+package deepspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Chat is a conversation with a provider whose tool calls are run by a
+// toolbox
+type Chat struct {
+	provider Provider
+	toolbox  *Toolbox
+	session  Session
+	// system is the system prompt sessions start with
+	system string
+	// maxSteps bounds the rounds of tool calls per message; zero is unlimited
+	maxSteps int
+	// toolTimeout bounds each tool call; zero is unlimited
+	toolTimeout time.Duration
+	// pending are the results of tool calls a failed or cancelled exchange
+	// did not get to the model. They are sent with the next message, as the
+	// session expects an answer to every call.
+	pending []ToolResult
+}
+
+// StepLimitError reports a model still calling tools after the maximum number
+// of steps
+type StepLimitError struct {
+	MaxSteps int
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("the model was still calling tools after %d steps; raise agent.max_steps to allow more", e.MaxSteps)
+}
+
+// NewChat creates a chat with a provider, offering the tools of a toolbox.
+// It follows SystemPrompt and is limited to DefaultMaxSteps and
+// DefaultToolTimeout. Start it before sending messages.
+func NewChat(provider Provider, toolbox *Toolbox) *Chat {
+	return &Chat{
+		provider:    provider,
+		toolbox:     toolbox,
+		system:      SystemPrompt,
+		maxSteps:    DefaultMaxSteps,
+		toolTimeout: DefaultToolTimeout,
+	}
+}
+
+// SetSystem sets the system prompt of the sessions started from now on
+func (c *Chat) SetSystem(system string) {
+	c.system = system
+}
+
+// System returns the system prompt
+func (c *Chat) System() string {
+	return c.system
+}
+
+// SetLimits sets the rounds of tool calls a message may take and the time
+// each tool call may take. Zero lifts a limit.
+func (c *Chat) SetLimits(maxSteps int, toolTimeout time.Duration) {
+	c.maxSteps = maxSteps
+	c.toolTimeout = toolTimeout
+}
+
+// Provider returns the provider of the chat
+func (c *Chat) Provider() Provider {
+	return c.provider
+}
+
+// Start starts a new session with the system prompt, declaring the tools the
+// toolbox offers now
+func (c *Chat) Start(ctx context.Context) error {
+	session, err := c.provider.StartSession(ctx, c.system, c.toolbox.Definitions(ctx))
+	if err != nil {
+		return err
+	}
+	c.session = session
+	c.pending = nil
+	return nil
+}
+
+// ChatEvents receive the progress of a streamed message. Any may be nil.
+// Steps count the rounds of tool calls from 1.
+type ChatEvents struct {
+	// OnText receives each piece of response text as it arrives
+	OnText func(text string)
+	// OnToolCall is called before a tool the model asked for runs
+	OnToolCall func(step int, call ToolCall)
+	// OnToolResult is called when a tool finished. The tools of a step run
+	// concurrently, so results arrive in any order and from any goroutine.
+	OnToolResult func(step int, result ToolResult)
+}
+
+// Send sends a message and returns the final text response
+// Tool calls are executed and their results sent back until the model answers
+// without calling tools, or the step limit is hit. Cancelling the context stops
+// the exchange: tools not run yet report the cancellation to the model with
+// the next message.
+func (c *Chat) Send(ctx context.Context, message string) (string, error) {
+	return c.exchange(ctx, message, ChatEvents{}, func(ctx context.Context, message Message) (*Response, error) {
+		return c.session.Send(ctx, message)
+	})
+}
+
+// Stream sends a message like Send, reporting the response text as it
+// arrives and every tool call before it runs
+func (c *Chat) Stream(ctx context.Context, message string, events ChatEvents) (string, error) {
+	return c.exchange(ctx, message, events, func(ctx context.Context, message Message) (*Response, error) {
+		return c.session.Stream(ctx, message, func(text string) {
+			if events.OnText != nil {
+				events.OnText(text)
+			}
+		})
+	})
+}
+
+// exchange sends a message through send and runs the tool calls of the
+// responses until the model answers with text only
+func (c *Chat) exchange(ctx context.Context, message string, events ChatEvents, send func(ctx context.Context, message Message) (*Response, error)) (string, error) {
+	if c.session == nil {
+		return "", errors.New("chat not started")
+	}
+
+	first := Message{Text: message, ToolResults: c.pending}
+	c.pending = nil
+	response, err := send(ctx, first)
+	if err != nil {
+		c.pending = first.ToolResults
+		return "", err
+	}
+	for step := 1; len(response.ToolCalls) > 0; step++ {
+		if c.maxSteps > 0 && step > c.maxSteps {
+			// Answer the calls so the session can go on with the next message
+			var results []ToolResult
+			for _, call := range response.ToolCalls {
+				results = append(results, ToolResult{ID: call.ID, Name: call.Name, Output: map[string]any{"error": "step limit reached"}})
+			}
+			c.pending = results
+			return "", &StepLimitError{MaxSteps: c.maxSteps}
+		}
+
+		// Execute the tool calls and send the results back
+		results := c.runTools(ctx, step, response.ToolCalls, events)
+		if err := ctx.Err(); err != nil {
+			c.pending = results
+			return "", err
+		}
+		response, err = send(ctx, Message{ToolResults: results})
+		if err != nil {
+			c.pending = results
+			return "", err
+		}
+	}
+	return response.Text, nil
+}
+
+// runTools runs the tool calls of a step concurrently and returns their
+// results in the order of the calls
+func (c *Chat) runTools(ctx context.Context, step int, calls []ToolCall, events ChatEvents) []ToolResult {
+	results := make([]ToolResult, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		if events.OnToolCall != nil && ctx.Err() == nil {
+			events.OnToolCall(step, call)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = ToolResult{ID: call.ID, Name: call.Name, Output: c.runTool(ctx, call)}
+			if events.OnToolResult != nil {
+				events.OnToolResult(step, results[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runTool runs a tool call within the tool timeout. A tool that ignores its
+// context is abandoned when the timeout passes.
+func (c *Chat) runTool(ctx context.Context, call ToolCall) map[string]any {
+	if c.toolTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.toolTimeout)
+		defer cancel()
+	}
+
+	done := make(chan map[string]any, 1)
+	go func() {
+		done <- c.toolbox.Execute(ctx, call)
+	}()
+	var output map[string]any
+	select {
+	case output = <-done:
+	case <-ctx.Done():
+		output = map[string]any{"error": ctx.Err().Error()}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return map[string]any{"error": fmt.Sprintf("%s timed out after %s", call.Name, c.toolTimeout)}
+	}
+	return output
+}
@@ -0,0 +1,168 @@
+package deepspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		maxSteps int
+		// cancel cancels the exchange when the first tool is called
+		cancel    bool
+		wantText  string
+		wantErr   string
+		wantSteps []int
+		// wantSent are the messages the session got: the first message, the
+		// tool results of its steps and a second message, whose answer is bye
+		wantSent string
+	}{
+		{
+			name: "tool round trip",
+			script: `
+turns:
+  - tool_calls:
+      - {name: echo, args: {text: hello}}
+      - {name: missing}
+  - expect: hello
+    text: The echo said hello
+  - text: bye
+`,
+			wantText:  "The echo said hello",
+			wantSteps: []int{1, 1},
+			wantSent: `[
+				{"text": "hi"},
+				{"tool_results": [
+					{"id": "fake_1_1", "name": "echo", "output": {"output": "hello"}},
+					{"id": "fake_1_2", "name": "missing", "output": {"error": "unknown function: missing"}}
+				]},
+				{"text": "again"}
+			]`,
+		},
+		{
+			name: "step limit",
+			script: `
+turns:
+  - tool_calls: [{name: echo, args: {text: one}}]
+  - tool_calls: [{name: echo, args: {text: two}}]
+  - expect: step limit reached
+    text: bye
+`,
+			maxSteps:  1,
+			wantErr:   "after 1 steps",
+			wantSteps: []int{1},
+			wantSent: `[
+				{"text": "hi"},
+				{"tool_results": [{"id": "fake_1_1", "name": "echo", "output": {"output": "one"}}]},
+				{"text": "again", "tool_results": [{"id": "fake_2_1", "name": "echo", "output": {"error": "step limit reached"}}]}
+			]`,
+		},
+		{
+			name: "cancelled",
+			script: `
+turns:
+  - tool_calls: [{name: echo, args: {text: hello}}]
+  - expect: context canceled
+    text: bye
+`,
+			cancel:    true,
+			wantErr:   context.Canceled.Error(),
+			wantSteps: []int{1},
+			wantSent: `[
+				{"text": "hi"},
+				{"text": "again", "tool_results": [{"id": "fake_1_1", "name": "echo", "output": {"error": "context canceled"}}]}
+			]`,
+		},
+		{
+			name: "provider error",
+			script: `
+turns:
+  - tool_calls: [{name: echo, args: {text: hello}}]
+  - error: overloaded
+  - expect: hello
+    text: bye
+`,
+			wantErr:   "overloaded",
+			wantSteps: []int{1},
+			wantSent: `[
+				{"text": "hi"},
+				{"tool_results": [{"id": "fake_1_1", "name": "echo", "output": {"output": "hello"}}]},
+				{"text": "again", "tool_results": [{"id": "fake_1_1", "name": "echo", "output": {"output": "hello"}}]}
+			]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := ParseFakeScript([]byte(tt.script))
+			if err != nil {
+				t.Fatal(err)
+			}
+			provider := NewFakeProvider(script)
+			chat := NewChat(provider, NewToolbox(nil))
+			chat.SetLimits(tt.maxSteps, time.Second)
+			if err := chat.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var streamed strings.Builder
+			var steps []int
+			events := ChatEvents{
+				OnText: func(text string) { streamed.WriteString(text) },
+				OnToolCall: func(step int, call ToolCall) {
+					steps = append(steps, step)
+					if tt.cancel {
+						cancel()
+					}
+				},
+			}
+			text, err := chat.Stream(ctx, "hi", events)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if text != tt.wantText || streamed.String() != tt.wantText {
+				t.Errorf("text = %q, streamed %q, want %q", text, streamed.String(), tt.wantText)
+			}
+			if !slices.Equal(steps, tt.wantSteps) {
+				t.Errorf("tool calls in steps %v, want %v", steps, tt.wantSteps)
+			}
+
+			if text, err := chat.Send(context.Background(), "again"); err != nil || text != "bye" {
+				t.Fatalf("second message = %q, %v, want bye", text, err)
+			}
+			sent, err := json.Marshal(provider.Sessions()[0].Messages())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameJSON(sent, []byte(tt.wantSent)) {
+				t.Errorf("sent %s, want %s", sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestChatStepLimitError(t *testing.T) {
+	script, err := ParseFakeScript([]byte("repeat: true\nturns:\n  - tool_calls: [{name: echo, args: {text: again}}]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := NewChat(NewFakeProvider(script), NewToolbox(nil))
+	chat.SetLimits(3, 0)
+	if err := chat.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = chat.Send(context.Background(), "hi")
+	var limitErr *StepLimitError
+	if !errors.As(err, &limitErr) || limitErr.MaxSteps != 3 {
+		t.Fatalf("error = %v, want a step limit of 3", err)
+	}
+}
@@ -2,12 +2,13 @@ package deepspec
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"google.golang.org/genai"
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
 // chatResponseMsg is a message containing the AI response
@@ -16,6 +17,30 @@ type chatResponseMsg struct {
 	err      error
 }
 
+// chatStreamMsg is a message containing a piece of a streamed AI response
+type chatStreamMsg struct {
+	text string
+}
+
+// chatToolCallMsg is a message containing a tool call the AI made while
+// answering
+type chatToolCallMsg struct {
+	step int
+	call ToolCall
+}
+
+// chatToolResultMsg is a message containing the result of a tool call
+type chatToolResultMsg struct {
+	step   int
+	result ToolResult
+}
+
+// promptsMsg is a message containing the prompts of the MCP server
+type promptsMsg struct {
+	prompts []mcp.Prompt
+	err     error
+}
+
 // healthCheckMsg is a message containing the health check result
 type healthCheckMsg struct {
 	healthy bool
@@ -23,50 +48,86 @@ type healthCheckMsg struct {
 
 // ChatModel represents the chat view with input and message history
 type ChatModel struct {
-	input        *InputComponent
-	viewport     *ViewportComponent
-	help         *HelpComponent
-	width        int
-	height       int
-	vertexClient *VertexClient
-	chatSession  *genai.Chat
+	input    *InputComponent
+	viewport *ViewportComponent
+	help     *HelpComponent
+	width    int
+	height   int
+	// chat is nil when the provider could not be started
+	chat         *Chat
 	waiting      bool
 	serverOnline bool
 	mcpClient    *mcpClient
+	// healthInterval is the time between health checks
+	healthInterval time.Duration
+	// stream delivers the events of the response being streamed
+	stream chan tea.Msg
+	// cancel cancels the context of the response being streamed
+	cancel    context.CancelFunc
+	cancelled bool
+	// system are the fragments of the system prompt the chat follows
+	system []SystemFragment
 }
 
-// NewChatModel creates a new chat model instance
-func NewChatModel() *ChatModel {
+// NewChatModel creates a new chat model instance from the config
+func NewChatModel(config *Config) *ChatModel {
 	ctx := context.Background()
-	vertexClient, err := NewVertexClient(ctx)
 
-	var chatSession *genai.Chat
-	if err == nil && vertexClient != nil {
-		chatSession, err = vertexClient.StartChat()
-	}
+	// A cassette records the session, or replays one without model and server
+	cassette, cassetteErr := OpenCassette(config.Cassette)
 
-	mcpClient := NewMCPClient()
+	// Start the MCP client first so the chat can declare the server's tools
+	mcpClient := NewMCPClient(config)
+	mcpClient.SetCassette(cassette)
 	if startErr := mcpClient.Start(ctx); startErr != nil {
 		LogToFile("Failed to start MCP client: %v", startErr)
 	}
 
+	// Compose the system prompt, falling back to the built-in rules
+	system, systemErr := SystemPromptFragments(config)
+	if systemErr != nil {
+		system = []SystemFragment{{Title: "Base rules", Text: SystemPrompt}}
+	}
+
+	var chat *Chat
+	var provider Provider
+	var err error
+	if cassetteErr == nil && !cassette.Replaying() {
+		provider, err = NewProvider(ctx, config)
+	}
+	if cassetteErr == nil && err == nil {
+		chat = NewChat(cassette.WrapProvider(provider), NewToolbox(mcpClient))
+		chat.SetSystem(ComposeSystemPrompt(system))
+		chat.SetLimits(config.Agent.MaxSteps, config.Agent.ToolTimeout)
+		if err = chat.Start(ctx); err != nil {
+			chat = nil
+		}
+	}
+
 	model := &ChatModel{
-		input:        NewInputComponent(),
-		viewport:     NewViewportComponent(),
-		help:         NewHelpComponent(),
-		vertexClient: vertexClient,
-		chatSession:  chatSession,
-		waiting:      false,
-		mcpClient:    mcpClient,
+		input:          NewInputComponent(),
+		viewport:       NewViewportComponent(),
+		help:           NewHelpComponent(),
+		chat:           chat,
+		waiting:        false,
+		mcpClient:      mcpClient,
+		healthInterval: config.HealthInterval,
+		system:         system,
+	}
+	if cassetteErr != nil {
+		model.viewport.AddError("Cassette Error: " + cassetteErr.Error())
+	}
+	if systemErr != nil {
+		model.viewport.AddError("System Prompt Error: " + systemErr.Error() + ". Using the built-in rules.")
 	}
 	if err != nil {
 		// Clean up the error message for better UX
 		errMsg := err.Error()
 		// Check for common Vertex AI errors and simplify the message
 		if strings.Contains(errMsg, "project/location or API key must be set when using Vertex AI backend") {
-			errMsg = "Vertex AI configuration missing. Please set GOOGLE_CLOUD_PROJECT and GCP_LOCATION environment variables."
+			errMsg = "Vertex AI configuration missing. Please set vertex.project and vertex.location in the config, or the GOOGLE_CLOUD_PROJECT and GCP_LOCATION environment variables."
 		}
-		model.viewport.AddMessage(ErrorStyle.Render("Vertex AI Error: " + errMsg))
+		model.viewport.AddError("Provider Error (" + config.Provider + "): " + errMsg)
 	}
 	return model
 }
@@ -89,7 +150,7 @@ func (c *ChatModel) performHealthCheck() tea.Cmd {
 
 // scheduleHealthCheck schedules periodic health checks
 func (c *ChatModel) scheduleHealthCheck() tea.Cmd {
-	return tea.Tick(time.Second*3, func(time.Time) tea.Msg {
+	return tea.Tick(c.healthInterval, func(time.Time) tea.Msg {
 		ctx := context.Background()
 		healthy, _ := c.mcpClient.HealthCheck(ctx)
 		return healthCheckMsg{healthy: healthy}
@@ -106,9 +167,33 @@ func (c *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		c.help.SetConnectionStatus(c.serverOnline)
 		return c, c.scheduleHealthCheck()
 
+	case promptsMsg:
+		c.showPrompts(msg.prompts, msg.err)
+		return c, nil
+
+	case chatStreamMsg:
+		c.viewport.StreamLoader(msg.text)
+		return c, c.nextStreamEvent()
+
+	case chatToolCallMsg:
+		c.viewport.AddToolCall(msg.step, msg.call)
+		return c, c.nextStreamEvent()
+
+	case chatToolResultMsg:
+		c.viewport.SetToolResult(msg.step, msg.result)
+		return c, c.nextStreamEvent()
+
 	case chatResponseMsg:
 		c.waiting = false
-		if msg.err != nil {
+		c.help.SetWaiting(false)
+		c.stream = nil
+		if c.cancel != nil {
+			c.cancel()
+			c.cancel = nil
+		}
+		if msg.err != nil && c.cancelled {
+			c.viewport.CancelLoader()
+		} else if msg.err != nil {
 			c.viewport.ErrorLoader(msg.err.Error())
 		} else {
 			c.viewport.CompleteLoader(msg.response)
@@ -126,10 +211,10 @@ func (c *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			value := c.input.Value()
 			if value != "" {
 				if c.input.IsCommandMode() {
-					c.handleCommand(value)
+					cmd := c.handleCommand(value)
 					c.input.Clear()
 					c.input.SetCommandMode(false)
-					return c, nil
+					return c, cmd
 				} else {
 					return c, c.handleChatMessage(value)
 				}
@@ -157,29 +242,82 @@ func (c *ChatModel) handleChatMessage(message string) tea.Cmd {
 	c.input.Clear()
 	c.input.SetCommandMode(false)
 
-	if c.vertexClient == nil || c.chatSession == nil {
-		c.viewport.ErrorLoader("Vertex AI client not initialized")
+	if c.chat == nil {
+		c.viewport.ErrorLoader("Chat provider not initialized")
 		return nil
 	}
 
 	c.waiting = true
 	loaderCmd := c.viewport.StartLoader()
 
-	// Async send
-	messageCmd := func() tea.Msg {
-		response, err := c.vertexClient.SendMessage(c.chatSession, message)
+	// Async send, streaming the response into the viewport
+	messageCmd := c.streamChat(func(ctx context.Context, events ChatEvents) (string, error) {
+		response, err := c.chat.Stream(ctx, message, events)
 		if err != nil && strings.Contains(err.Error(), "session") {
-			c.chatSession, _ = c.vertexClient.StartChat()
-			response, err = c.vertexClient.SendMessage(c.chatSession, message)
+			if startErr := c.chat.Start(ctx); startErr == nil {
+				response, err = c.chat.Stream(ctx, message, events)
+			}
 		}
-		return chatResponseMsg{response: response, err: err}
-	}
+		return response, err
+	})
 
 	return tea.Batch(loaderCmd, messageCmd)
 }
 
-// handleCommand processes user commands
-func (c *ChatModel) handleCommand(input string) {
+// streamChat runs a chat exchange in the background and returns the command
+// delivering its first event. The events of the exchange and finally its
+// chatResponseMsg arrive in order through the stream.
+func (c *ChatModel) streamChat(exchange func(ctx context.Context, events ChatEvents) (string, error)) tea.Cmd {
+	// Each exchange gets its own context, which Cancel cancels
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.cancelled = false
+	c.help.SetWaiting(true)
+
+	stream := make(chan tea.Msg)
+	c.stream = stream
+	go func() {
+		response, err := exchange(ctx, ChatEvents{
+			OnText: func(text string) {
+				stream <- chatStreamMsg{text: text}
+			},
+			OnToolCall: func(step int, call ToolCall) {
+				stream <- chatToolCallMsg{step: step, call: call}
+			},
+			OnToolResult: func(step int, result ToolResult) {
+				stream <- chatToolResultMsg{step: step, result: result}
+			},
+		})
+		stream <- chatResponseMsg{response: response, err: err}
+	}()
+	return c.nextStreamEvent()
+}
+
+// Cancel cancels the response being awaited. The exchange stops at the next
+// request or tool call, and the turn is shown as cancelled once it returns.
+func (c *ChatModel) Cancel() bool {
+	if !c.waiting || c.cancel == nil {
+		return false
+	}
+	c.cancel()
+	c.cancelled = true
+	return true
+}
+
+// nextStreamEvent waits for the next event of the streamed response
+func (c *ChatModel) nextStreamEvent() tea.Cmd {
+	stream := c.stream
+	if stream == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return <-stream
+	}
+}
+
+// handleCommand processes user commands. Anything but the built-in
+// commands runs the MCP server prompt of that name.
+func (c *ChatModel) handleCommand(input string) tea.Cmd {
 	// Remove leading /
 	input = strings.TrimPrefix(input, "/")
 	input = strings.TrimSpace(input)
@@ -187,9 +325,20 @@ func (c *ChatModel) handleCommand(input string) {
 	switch input {
 	case "?":
 		c.showHelp()
-	default:
+		return nil
+	case "prompts":
 		c.viewport.AddMessage("> /" + input)
+		return c.fetchPrompts()
+	case "system":
+		c.viewport.AddMessage("> /" + input)
+		c.showSystem()
+		return nil
+	case "":
+		c.viewport.AddMessage("> /")
 		c.viewport.AddMessage("Unknown command. Type '/?' for help.")
+		return nil
+	default:
+		return c.runPrompt(input)
 	}
 }
 
@@ -198,6 +347,9 @@ func (c *ChatModel) showHelp() {
 	helpText := []string{
 		"Available Commands:",
 		"  ? - Show this help message",
+		"  prompts - List the prompts of the MCP server",
+		"  system - Show the system prompt",
+		"  <prompt> [args] - Run a prompt, e.g. /implement_function pkg/tools.go Register",
 		"",
 	}
 
@@ -206,6 +358,179 @@ func (c *ChatModel) showHelp() {
 	}
 }
 
+// showSystem displays the fragments of the system prompt
+func (c *ChatModel) showSystem() {
+	for _, fragment := range c.system {
+		c.viewport.AddMessage(AssistantBoldStyle.Render(fragment.Title + ":"))
+		c.viewport.AddMessage(fragment.Text)
+		c.viewport.AddMessage("")
+	}
+}
+
+// fetchPrompts asks the MCP server for its prompts
+func (c *ChatModel) fetchPrompts() tea.Cmd {
+	return func() tea.Msg {
+		prompts, err := c.mcpClient.ListPrompts(context.Background())
+		return promptsMsg{prompts: prompts, err: err}
+	}
+}
+
+// showPrompts lists the prompts with their arguments as slash commands
+func (c *ChatModel) showPrompts(prompts []mcp.Prompt, err error) {
+	if err != nil {
+		c.viewport.AddError("Failed to list prompts: " + err.Error())
+		return
+	}
+	if len(prompts) == 0 {
+		c.viewport.AddMessage("The MCP server offers no prompts.")
+		return
+	}
+
+	c.viewport.AddMessage("Prompts:")
+	for _, prompt := range prompts {
+		usage := "  /" + prompt.Name
+		for _, arg := range prompt.Arguments {
+			if arg.Required {
+				usage += " <" + arg.Name + ">"
+			} else {
+				usage += " [" + arg.Name + "]"
+			}
+		}
+		c.viewport.AddMessage(usage + " - " + prompt.Description)
+	}
+	c.viewport.AddMessage("")
+}
+
+// runPrompt renders an MCP server prompt and sends it as a chat message.
+// Arguments are given in order or as name=value.
+func (c *ChatModel) runPrompt(input string) tea.Cmd {
+	c.viewport.AddUserMessage("/" + input)
+
+	if c.chat == nil {
+		c.viewport.ErrorLoader("Chat provider not initialized")
+		return nil
+	}
+
+	c.waiting = true
+	loaderCmd := c.viewport.StartLoader()
+
+	promptCmd := c.streamChat(func(ctx context.Context, events ChatEvents) (string, error) {
+		fields := splitCommandArgs(input)
+		prompts, err := c.mcpClient.ListPrompts(ctx)
+		if err != nil {
+			return "", fmt.Errorf("list prompts: %w", err)
+		}
+		var prompt *mcp.Prompt
+		for i := range prompts {
+			if prompts[i].Name == fields[0] {
+				prompt = &prompts[i]
+			}
+		}
+		if prompt == nil {
+			return "", fmt.Errorf("unknown command /%s. Type '/?' for help", fields[0])
+		}
+
+		args, err := promptArguments(*prompt, fields[1:])
+		if err != nil {
+			return "", err
+		}
+		result, err := c.mcpClient.GetPrompt(ctx, prompt.Name, args)
+		if err != nil {
+			return "", err
+		}
+
+		return c.chat.Stream(ctx, promptText(result), events)
+	})
+
+	return tea.Batch(loaderCmd, promptCmd)
+}
+
+// splitCommandArgs splits a command line at spaces, keeping double quoted
+// text together
+func splitCommandArgs(input string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted, started := false, false
+	for _, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case r == ' ' && !quoted:
+			if started {
+				fields = append(fields, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// promptArguments maps command fields to prompt arguments: name=value sets
+// an argument by name, other fields fill the remaining arguments in order
+func promptArguments(prompt mcp.Prompt, fields []string) (map[string]string, error) {
+	args := make(map[string]string)
+	var positional []string
+	for _, field := range fields {
+		name, value, ok := strings.Cut(field, "=")
+		if ok && promptHasArgument(prompt, name) {
+			args[name] = value
+		} else {
+			positional = append(positional, field)
+		}
+	}
+	for _, arg := range prompt.Arguments {
+		if _, ok := args[arg.Name]; ok || len(positional) == 0 {
+			continue
+		}
+		args[arg.Name] = positional[0]
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("too many arguments for /%s", prompt.Name)
+	}
+	for _, arg := range prompt.Arguments {
+		if _, ok := args[arg.Name]; arg.Required && !ok {
+			return nil, fmt.Errorf("/%s needs the %s argument", prompt.Name, arg.Name)
+		}
+	}
+	return args, nil
+}
+
+// promptHasArgument reports whether a prompt declares an argument
+func promptHasArgument(prompt mcp.Prompt, name string) bool {
+	for _, arg := range prompt.Arguments {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// promptText flattens the messages of a rendered prompt into one chat
+// message, with embedded resources as fenced blocks
+func promptText(result *mcp.GetPromptResult) string {
+	var parts []string
+	for _, message := range result.Messages {
+		switch content := message.Content.(type) {
+		case mcp.TextContent:
+			parts = append(parts, content.Text)
+		case mcp.EmbeddedResource:
+			if resource, ok := content.Resource.(mcp.TextResourceContents); ok {
+				parts = append(parts, resource.URI+":\n```\n"+strings.TrimRight(resource.Text, "\n")+"\n```")
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
 // SetSize updates the chat model dimensions
 func (c *ChatModel) SetSize(width, height int) {
 	c.width = width
@@ -0,0 +1,118 @@
+package deepspec
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newFakeChatModel creates a chat model playing a script, without a server
+func newFakeChatModel(t *testing.T, script string) *ChatModel {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := DefaultConfig()
+	config.Provider = ProviderFake
+	config.Server.Addr = "127.0.0.1:1"
+	config.Fake.Script = filepath.Join(dir, "script.yaml")
+	if err := os.WriteFile(config.Fake.Script, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	model := NewChatModel(config)
+	if model.chat == nil {
+		t.Fatalf("chat not started: %+v", model.viewport.Conversation().Messages())
+	}
+	return model
+}
+
+func TestChatModelConversation(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		// cancel cancels the exchange once the first text is shown, so its
+		// tool calls do not run
+		cancel bool
+		want   []conversationEntry
+	}{
+		{
+			name: "tool round trip",
+			script: `
+turns:
+  - text: Let me check
+    tool_calls: [{name: echo, args: {text: hello}}]
+  - text: Done
+`,
+			want: []conversationEntry{
+				{RoleUser, StatusDone, "hi", ""},
+				{RoleAssistant, StatusDone, "Let me check", ""},
+				{RoleTool, StatusDone, "", ""},
+				{RoleAssistant, StatusDone, "Done", ""},
+			},
+		},
+		{
+			name: "tool error",
+			script: `
+turns:
+  - tool_calls: [{name: missing}]
+  - text: Done
+`,
+			want: []conversationEntry{
+				{RoleUser, StatusDone, "hi", ""},
+				{RoleTool, StatusError, "", "unknown function: missing"},
+				{RoleAssistant, StatusDone, "Done", ""},
+			},
+		},
+		{
+			name: "provider error",
+			script: `
+turns:
+  - error: overloaded
+`,
+			want: []conversationEntry{
+				{RoleUser, StatusDone, "hi", ""},
+				{RoleAssistant, StatusError, "", "fake provider: overloaded"},
+			},
+		},
+		{
+			name: "cancelled",
+			script: `
+turns:
+  - text: Let me check
+    tool_calls: [{name: echo, args: {text: hello}}]
+  - text: Done
+`,
+			cancel: true,
+			want: []conversationEntry{
+				{RoleUser, StatusDone, "hi", ""},
+				{RoleAssistant, StatusCancelled, "Let me check", ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newFakeChatModel(t, tt.script)
+			model.input.input.SetValue("hi")
+			model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+			if !model.waiting {
+				t.Fatal("model is not waiting for the answer")
+			}
+
+			// Deliver the events of the exchange until its response
+			for model.waiting {
+				msg := model.nextStreamEvent()()
+				model.Update(msg)
+				if _, ok := msg.(chatStreamMsg); ok && tt.cancel && !model.cancelled && !model.Cancel() {
+					t.Fatal("Cancel() = false while waiting")
+				}
+			}
+
+			got := conversationEntries(model.viewport.Conversation().Messages())
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package deepspec
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
 	"strings"
 	"sync"
 
@@ -10,34 +15,108 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// MCPEndpoint describes how the client reaches the MCP server
+type MCPEndpoint struct {
+	// Transport is stdio, sse or http
+	Transport string
+	// Addr is the host:port or URL of the sse and http transports. A missing
+	// host means localhost.
+	Addr string
+	// Command starts the server for the stdio transport. By default this
+	// executable's server command is run.
+	Command []string
+}
+
+// url returns the server URL of the sse and http transports, whose default
+// paths are /sse and /mcp
+func (e MCPEndpoint) url(path string) string {
+	if strings.Contains(e.Addr, "://") {
+		return e.Addr
+	}
+	addr := e.Addr
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr + path
+}
+
+// transport creates the client transport of the endpoint
+func (e MCPEndpoint) transport() (transport.Interface, error) {
+	switch e.Transport {
+	case TransportStdio:
+		command := e.Command
+		if len(command) == 0 {
+			executable, err := os.Executable()
+			if err != nil {
+				return nil, err
+			}
+			command = []string{executable, "server", "--transport", TransportStdio}
+		}
+		return transport.NewStdio(command[0], nil, command[1:]...), nil
+	case TransportSSE:
+		return transport.NewSSE(e.url("/sse"))
+	case TransportHTTP:
+		// Listen continuously so list changes arrive between requests
+		return transport.NewStreamableHTTP(e.url("/mcp"), transport.WithContinuousListening())
+	default:
+		return nil, fmt.Errorf("unknown transport %q, expected %s", e.Transport, strings.Join(Transports, ", "))
+	}
+}
+
 type mcpClient struct {
-	mutex  *sync.Mutex
-	client *client.Client
+	mutex    *sync.Mutex
+	endpoint MCPEndpoint
+	client   *client.Client
+	// tools caches the server's tool list until it reports a change
+	tools      []mcp.Tool
+	toolsValid bool
+	// cassette records the server's answers, or replays them without a server
+	cassette *Cassette
 }
 
-// NewMCPClient creates a new MCP client instance
-func NewMCPClient() *mcpClient {
+// NewMCPClient creates a new MCP client instance for the configured server
+func NewMCPClient(config *Config) *mcpClient {
 	return &mcpClient{
-		mutex: &sync.Mutex{},
+		mutex:    &sync.Mutex{},
+		endpoint: config.MCPEndpoint(),
 	}
 }
 
-// Start initializes and starts the MCP client
+// SetCassette records the tool and prompt traffic to a cassette, or replays
+// it from one instead of connecting to the server
+func (f *mcpClient) SetCassette(cassette *Cassette) {
+	f.mutex.Lock()
+	f.cassette = cassette
+	f.mutex.Unlock()
+}
+
+// Start initializes and starts the MCP client. A replaying client does not
+// connect.
 func (f *mcpClient) Start(ctx context.Context) error {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
+	if f.cassette.Replaying() {
+		return nil
+	}
+
 	// Initialize the MCP client
-	tp, err := transport.NewStreamableHTTP(MCPServerAddress)
+	tp, err := f.endpoint.transport()
 	if err != nil {
 		return err
 	}
 	f.client = client.NewClient(tp)
+	f.client.OnNotification(f.handleNotification)
+	f.toolsValid = false
 
-	// Start the client
-	if err := f.client.Start(ctx); err != nil {
+	// Start the client. The SSE stream or stdio server process lives as long
+	// as the client, not as long as the request that happened to start it.
+	if err := f.client.Start(context.WithoutCancel(ctx)); err != nil {
 		return err
 	}
+	if stdio, ok := tp.(*transport.Stdio); ok {
+		go logServerOutput(stdio)
+	}
 
 	initRequest := mcp.InitializeRequest{
 		Params: mcp.InitializeParams{
@@ -53,6 +132,14 @@ func (f *mcpClient) Start(ctx context.Context) error {
 	return err
 }
 
+// logServerOutput copies the stderr of a stdio server process to the log
+func logServerOutput(stdio *transport.Stdio) {
+	scanner := bufio.NewScanner(stdio.Stderr())
+	for scanner.Scan() {
+		LogToFile("MCP server: %s", scanner.Text())
+	}
+}
+
 // reconnect attempts to reset and restart the MCP client connection
 func (f *mcpClient) reconnect(ctx context.Context) error {
 	LogToFile("Session terminated, attempting reconnect")
@@ -74,12 +161,16 @@ func (f *mcpClient) reconnect(ctx context.Context) error {
 	return nil
 }
 
-// HealthCheck pings the MCP server to check its health
+// HealthCheck pings the MCP server to check its health. The cassette of a
+// replaying client is always healthy.
 func (f *mcpClient) HealthCheck(ctx context.Context) (bool, error) {
 	f.mutex.Lock()
-	c := f.client
+	c, cassette := f.client, f.cassette
 	f.mutex.Unlock()
 
+	if cassette.Replaying() {
+		return true, nil
+	}
 	if c == nil {
 		return false, nil
 	}
@@ -117,3 +208,112 @@ func (f *mcpClient) HealthCheck(ctx context.Context) (bool, error) {
 
 	return !result.IsError, nil
 }
+
+// handleNotification invalidates cached lists the server reports as changed
+func (f *mcpClient) handleNotification(notification mcp.JSONRPCNotification) {
+	if notification.Method != mcp.MethodNotificationToolsListChanged {
+		return
+	}
+	LogToFile("Server tool list changed")
+	f.mutex.Lock()
+	f.toolsValid = false
+	f.mutex.Unlock()
+}
+
+// ListTools returns the tools offered by the MCP server. The list is cached
+// until the server sends a tool list change notification.
+func (f *mcpClient) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	f.mutex.Lock()
+	c, tools, valid, cassette := f.client, f.tools, f.toolsValid, f.cassette
+	f.mutex.Unlock()
+
+	if c == nil && !cassette.Replaying() {
+		return nil, errors.New("MCP client not started")
+	}
+	if valid {
+		return tools, nil
+	}
+
+	tools, err := cassetteCall(cassette, interactionListTools, nil, func() ([]mcp.Tool, error) {
+		result, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+		if err != nil {
+			return nil, err
+		}
+		return result.Tools, nil
+	}, decodeJSON[[]mcp.Tool])
+	if err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	if f.client == c {
+		f.tools, f.toolsValid = tools, true
+	}
+	f.mutex.Unlock()
+	return tools, nil
+}
+
+// CallTool calls a tool on the MCP server
+func (f *mcpClient) CallTool(ctx context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	f.mutex.Lock()
+	c, cassette := f.client, f.cassette
+	f.mutex.Unlock()
+
+	if c == nil && !cassette.Replaying() {
+		return nil, errors.New("MCP client not started")
+	}
+
+	request := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name:      name,
+			Arguments: args,
+		},
+	}
+	return cassetteCall(cassette, interactionCallTool, request.Params, func() (*mcp.CallToolResult, error) {
+		return c.CallTool(ctx, request)
+	}, func(data json.RawMessage) (*mcp.CallToolResult, error) {
+		return mcp.ParseCallToolResult(&data)
+	})
+}
+
+// ListPrompts returns the prompts offered by the MCP server
+func (f *mcpClient) ListPrompts(ctx context.Context) ([]mcp.Prompt, error) {
+	f.mutex.Lock()
+	c, cassette := f.client, f.cassette
+	f.mutex.Unlock()
+
+	if c == nil && !cassette.Replaying() {
+		return nil, errors.New("MCP client not started")
+	}
+
+	return cassetteCall(cassette, interactionListPrompts, nil, func() ([]mcp.Prompt, error) {
+		result, err := c.ListPrompts(ctx, mcp.ListPromptsRequest{})
+		if err != nil {
+			return nil, err
+		}
+		return result.Prompts, nil
+	}, decodeJSON[[]mcp.Prompt])
+}
+
+// GetPrompt renders a prompt of the MCP server with the given arguments
+func (f *mcpClient) GetPrompt(ctx context.Context, name string, args map[string]string) (*mcp.GetPromptResult, error) {
+	f.mutex.Lock()
+	c, cassette := f.client, f.cassette
+	f.mutex.Unlock()
+
+	if c == nil && !cassette.Replaying() {
+		return nil, errors.New("MCP client not started")
+	}
+
+	request := mcp.GetPromptRequest{
+		Params: mcp.GetPromptParams{
+			Name:      name,
+			Arguments: args,
+		},
+	}
+	return cassetteCall(cassette, interactionGetPrompt, request.Params, func() (*mcp.GetPromptResult, error) {
+		return c.GetPrompt(ctx, request)
+	}, func(data json.RawMessage) (*mcp.GetPromptResult, error) {
+		return mcp.ParseGetPromptResult(&data)
+	})
+}
@@ -0,0 +1,344 @@
+// Package codespec provides typed models for the code-spec format described
+// by schemas/code-spec.schema.json.
+//
+// Every model keeps properties it does not know about in its Extra map and
+// writes them back when marshaled, so reading and re-writing a spec never
+// drops data added by other tools or newer schema revisions. Known
+// properties whose values a field cannot tell from an absent one, such as
+// null, "" or [], are kept the same way.
+package codespec
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// Version is the spec_version this package reads and writes
+const Version = "1.0.0"
+
+// Languages supported by the schema
+const (
+	LanguageGo         = "go"
+	LanguagePython     = "python"
+	LanguageJavaScript = "javascript"
+	LanguageTypeScript = "typescript"
+	LanguageRust       = "rust"
+	LanguageJava       = "java"
+	LanguageC          = "c"
+	LanguageCPP        = "cpp"
+)
+
+// Visibility modifiers
+const (
+	VisibilityPublic     = "public"
+	VisibilityPrivate    = "private"
+	VisibilityProtected  = "protected"
+	VisibilityInternal   = "internal"
+	VisibilityExported   = "exported"
+	VisibilityUnexported = "unexported"
+)
+
+// Type definition kinds
+const (
+	KindStruct    = "struct"
+	KindClass     = "class"
+	KindInterface = "interface"
+	KindEnum      = "enum"
+	KindUnion     = "union"
+	KindTypeAlias = "type_alias"
+)
+
+// Statement types
+const (
+	StatementAssignment          = "assignment"
+	StatementDeclaration         = "declaration"
+	StatementVariableDeclaration = "variable_declaration"
+	StatementMethodCall          = "method_call"
+	StatementFunctionCall        = "function_call"
+	StatementReturn              = "return"
+	StatementIf                  = "if_statement"
+	StatementFor                 = "for_loop"
+	StatementSwitch              = "switch_statement"
+	StatementTypeAssertion       = "type_assertion"
+	StatementAppend              = "append"
+	StatementFunctionBody        = "function_body"
+)
+
+// Spec is a single code specification
+type Spec struct {
+	Schema             string              `json:"$schema,omitempty"`
+	SpecVersion        string              `json:"spec_version"`
+	Language           string              `json:"language"`
+	LanguageVersion    string              `json:"language_version,omitempty"`
+	Module             string              `json:"module"`
+	File               string              `json:"file"`
+	Description        string              `json:"description,omitempty"`
+	Imports            []Import            `json:"imports,omitempty"`
+	Types              []TypeDefinition    `json:"types,omitempty"`
+	Functions          []Function          `json:"functions,omitempty"`
+	Variables          []Variable          `json:"variables,omitempty"`
+	Constants          []Constant          `json:"constants,omitempty"`
+	ExternalReferences map[string][]string `json:"external_references,omitempty"`
+	Metadata           *Metadata           `json:"metadata,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Import is an imported package or module
+type Import struct {
+	Path  string `json:"path"`
+	Alias string `json:"alias,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// TypeDefinition is a struct, class, interface, enum, union or alias
+type TypeDefinition struct {
+	Name          string   `json:"name"`
+	Kind          string   `json:"kind"`
+	Visibility    string   `json:"visibility"`
+	Documentation string   `json:"documentation,omitempty"`
+	Fields        []Field  `json:"fields,omitempty"`
+	Methods       []string `json:"methods,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Field is a field of a type definition
+type Field struct {
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	Visibility    string         `json:"visibility"`
+	Documentation string         `json:"documentation,omitempty"`
+	Tags          map[string]any `json:"tags,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Function is a function or method
+type Function struct {
+	Name           string        `json:"name"`
+	Receiver       *Receiver     `json:"receiver,omitempty"`
+	Visibility     string        `json:"visibility"`
+	Documentation  string        `json:"documentation,omitempty"`
+	Parameters     []Parameter   `json:"parameters,omitempty"`
+	Returns        []ReturnValue `json:"returns,omitempty"`
+	BodyStatements []Statement   `json:"body_statements,omitempty"`
+	BodySummary    string        `json:"body_summary,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Receiver is the receiver of a method
+type Receiver struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Parameter is a function parameter. Default holds the JSON value of its
+// default, as written.
+type Parameter struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// ReturnValue is a function return value
+type ReturnValue struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Statement is a single statement of a function body. Control flow
+// statements nest their branches, switch statements their cases.
+type Statement struct {
+	Type        string      `json:"type"`
+	Operation   string      `json:"operation,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Code        string      `json:"code,omitempty"`
+	Value       string      `json:"value,omitempty"`
+	Condition   string      `json:"condition,omitempty"`
+	TrueBranch  []Statement `json:"true_branch,omitempty"`
+	FalseBranch []Statement `json:"false_branch,omitempty"`
+	Cases       []Case      `json:"cases,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Case is a single case of a switch statement
+type Case struct {
+	Type       string      `json:"type,omitempty"`
+	Statements []Statement `json:"statements,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Variable is a package or module level variable
+type Variable struct {
+	Name           string         `json:"name"`
+	Type           string         `json:"type"`
+	Initialization map[string]any `json:"initialization,omitempty"`
+	Exported       *bool          `json:"exported,omitempty"`
+	Doc            string         `json:"doc,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Constant is a package or module level constant. Value holds the JSON
+// value of the constant, as written.
+type Constant struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Doc      string          `json:"doc,omitempty"`
+	Exported *bool           `json:"exported,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// Metadata holds code metrics for a file
+type Metadata struct {
+	LinesOfCode          int    `json:"lines_of_code"`
+	BlankLines           int    `json:"blank_lines"`
+	CommentLines         int    `json:"comment_lines"`
+	CyclomaticComplexity int    `json:"cyclomatic_complexity"`
+	MaintainabilityNotes string `json:"maintainability_notes,omitempty"`
+
+	Extra   map[string]json.RawMessage `json:"-"`
+	dropped map[string]json.RawMessage
+}
+
+// IsExported reports whether the variable is exported
+func (v Variable) IsExported() bool {
+	return v.Exported != nil && *v.Exported
+}
+
+// IsExported reports whether the constant is exported
+func (c Constant) IsExported() bool {
+	return c.Exported != nil && *c.Exported
+}
+
+// Bool returns a pointer to b, for the Exported fields
+func Bool(b bool) *bool {
+	return &b
+}
+
+// RawValue encodes a value for the Default and Value fields, leaving HTML
+// characters unescaped. A nil value encodes as no value.
+func RawValue(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil
+	}
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+// DecodeValue decodes a Default or Value field, with numbers as
+// json.Number. No value decodes as nil.
+func DecodeValue(raw json.RawMessage) any {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// Parse decodes a spec document
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// ReadFile reads and decodes a spec file
+func ReadFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Marshal encodes the spec as indented JSON with a trailing newline,
+// leaving HTML characters in code snippets unescaped
+func (s *Spec) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile encodes the spec and writes it to path
+func (s *Spec) WriteFile(path string) error {
+	data, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// FindFunction returns the first function or method with the given name
+func (s *Spec) FindFunction(name string) *Function {
+	for i := range s.Functions {
+		if s.Functions[i].Name == name {
+			return &s.Functions[i]
+		}
+	}
+	return nil
+}
+
+// FindType returns the type definition with the given name
+func (s *Spec) FindType(name string) *TypeDefinition {
+	for i := range s.Types {
+		if s.Types[i].Name == name {
+			return &s.Types[i]
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for every statement of the list, depth first, including
+// statements nested in branches and cases
+func Walk(statements []Statement, fn func(*Statement)) {
+	for i := range statements {
+		st := &statements[i]
+		fn(st)
+		Walk(st.TrueBranch, fn)
+		Walk(st.FalseBranch, fn)
+		for j := range st.Cases {
+			Walk(st.Cases[j].Statements, fn)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package codespec
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		// edit changes the spec between decoding and encoding
+		edit func(spec *Spec)
+		// want is the encoding, when it differs from the input
+		want string
+	}{
+		{
+			name: "minimal",
+			in:   `{"spec_version":"1.0.0","language":"go","module":"m","file":"a.go"}`,
+		},
+		{
+			name: "null and empty values",
+			in: `{"spec_version":"1.0.0","language":"go","module":"m","file":"a.go","description":"","imports":[],` +
+				`"functions":[{"name":"F","receiver":null,"visibility":"public","documentation":"","parameters":[{"name":"n","type":"int","default":null}],"body_statements":[]}],` +
+				`"constants":[{"name":"C","type":"int","value":null,"doc":null}],` +
+				`"external_references":{}}`,
+		},
+		{
+			name: "values as written",
+			in: `{"spec_version":"1.0.0","language":"python","module":"m","file":"a.py",` +
+				`"functions":[{"name":"f","visibility":"public","parameters":[{"name":"x","type":"float","default":0.0},{"name":"s","type":"str","default":"\"<a>\""}]}],` +
+				`"variables":[{"name":"v","type":"int","initialization":{"code":"1e3"}}],` +
+				`"constants":[{"name":"PI","type":"float","value":3.140,"exported":true},{"name":"big","type":"int","value":123456789012345678901234567890,"exported":false}]}`,
+		},
+		{
+			name: "unknown properties sorted after known ones",
+			in: `{"spec_version":"1.0.0","language":"go","module":"m","file":"a.go",` +
+				`"types":[{"name":"T","kind":"struct","visibility":"public","fields":[{"name":"F","type":"int","visibility":"public","x-b":[1],"x-a":null}],"x-tool":{"k":"v"}}],` +
+				`"x-generated":true}`,
+			want: `{"spec_version":"1.0.0","language":"go","module":"m","file":"a.go",` +
+				`"types":[{"name":"T","kind":"struct","visibility":"public","fields":[{"name":"F","type":"int","visibility":"public","x-a":null,"x-b":[1]}],"x-tool":{"k":"v"}}],` +
+				`"x-generated":true}`,
+		},
+		{
+			name: "edited fields replace dropped values",
+			in: `{"spec_version":"1.0.0","language":"go","module":"m","file":"a.go","description":"",` +
+				`"functions":[{"name":"M","receiver":null,"visibility":"public","documentation":""}]}`,
+			edit: func(spec *Spec) {
+				spec.Description = "Package m"
+				spec.Functions[0].Receiver = &Receiver{Name: "r", Type: "R"}
+			},
+			want: `{"spec_version":"1.0.0","language":"go","module":"m","file":"a.go","description":"Package m",` +
+				`"functions":[{"name":"M","receiver":{"name":"r","type":"R"},"visibility":"public","documentation":""}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.edit != nil {
+				tt.edit(spec)
+			}
+			data, err := spec.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := json.Compact(&got, data); err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.in
+			}
+			if got.String() != want {
+				t.Errorf("encoded:\n%s\nwant:\n%s", got.String(), want)
+			}
+		})
+	}
+}
+
+func TestValues(t *testing.T) {
+	c := Constant{Name: "C", Type: "string", Value: RawValue("<a> & b"), Exported: Bool(true)}
+	if string(c.Value) != `"<a> & b"` {
+		t.Errorf("value = %s, want HTML characters unescaped", c.Value)
+	}
+	if got := DecodeValue(c.Value); got != "<a> & b" {
+		t.Errorf("decoded value = %v", got)
+	}
+	if got := DecodeValue(json.RawMessage("1.50")); got != json.Number("1.50") {
+		t.Errorf("decoded number = %#v, want json.Number", got)
+	}
+	if RawValue(nil) != nil || DecodeValue(nil) != nil {
+		t.Error("nil value does not encode as no value")
+	}
+	if !c.IsExported() || (Variable{}).IsExported() {
+		t.Error("IsExported does not follow the Exported field")
+	}
+}
@@ -0,0 +1,310 @@
+package codespec
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The plain* types share the layout of their models without the JSON
+// methods, so the helpers below can use the default encoding for them.
+type (
+	plainSpec           Spec
+	plainImport         Import
+	plainTypeDefinition TypeDefinition
+	plainField          Field
+	plainFunction       Function
+	plainReceiver       Receiver
+	plainParameter      Parameter
+	plainReturnValue    ReturnValue
+	plainStatement      Statement
+	plainCase           Case
+	plainVariable       Variable
+	plainConstant       Constant
+	plainMetadata       Metadata
+)
+
+// MarshalJSON implements json.Marshaler
+func (s Spec) MarshalJSON() ([]byte, error) { return marshalObject(plainSpec(s), s.Extra, s.dropped) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Spec) UnmarshalJSON(data []byte) (err error) {
+	s.Extra, s.dropped, err = unmarshalObject(data, (*plainSpec)(s))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (i Import) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainImport(i), i.Extra, i.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (i *Import) UnmarshalJSON(data []byte) (err error) {
+	i.Extra, i.dropped, err = unmarshalObject(data, (*plainImport)(i))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (t TypeDefinition) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainTypeDefinition(t), t.Extra, t.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *TypeDefinition) UnmarshalJSON(data []byte) (err error) {
+	t.Extra, t.dropped, err = unmarshalObject(data, (*plainTypeDefinition)(t))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (f Field) MarshalJSON() ([]byte, error) { return marshalObject(plainField(f), f.Extra, f.dropped) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *Field) UnmarshalJSON(data []byte) (err error) {
+	f.Extra, f.dropped, err = unmarshalObject(data, (*plainField)(f))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (f Function) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainFunction(f), f.Extra, f.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *Function) UnmarshalJSON(data []byte) (err error) {
+	f.Extra, f.dropped, err = unmarshalObject(data, (*plainFunction)(f))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (r Receiver) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainReceiver(r), r.Extra, r.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *Receiver) UnmarshalJSON(data []byte) (err error) {
+	r.Extra, r.dropped, err = unmarshalObject(data, (*plainReceiver)(r))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (p Parameter) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainParameter(p), p.Extra, p.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *Parameter) UnmarshalJSON(data []byte) (err error) {
+	p.Extra, p.dropped, err = unmarshalObject(data, (*plainParameter)(p))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (r ReturnValue) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainReturnValue(r), r.Extra, r.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *ReturnValue) UnmarshalJSON(data []byte) (err error) {
+	r.Extra, r.dropped, err = unmarshalObject(data, (*plainReturnValue)(r))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (s Statement) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainStatement(s), s.Extra, s.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Statement) UnmarshalJSON(data []byte) (err error) {
+	s.Extra, s.dropped, err = unmarshalObject(data, (*plainStatement)(s))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (c Case) MarshalJSON() ([]byte, error) { return marshalObject(plainCase(c), c.Extra, c.dropped) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *Case) UnmarshalJSON(data []byte) (err error) {
+	c.Extra, c.dropped, err = unmarshalObject(data, (*plainCase)(c))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (v Variable) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainVariable(v), v.Extra, v.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (v *Variable) UnmarshalJSON(data []byte) (err error) {
+	v.Extra, v.dropped, err = unmarshalObject(data, (*plainVariable)(v))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (c Constant) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainConstant(c), c.Extra, c.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *Constant) UnmarshalJSON(data []byte) (err error) {
+	c.Extra, c.dropped, err = unmarshalObject(data, (*plainConstant)(c))
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	return marshalObject(plainMetadata(m), m.Extra, m.dropped)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *Metadata) UnmarshalJSON(data []byte) (err error) {
+	m.Extra, m.dropped, err = unmarshalObject(data, (*plainMetadata)(m))
+	return err
+}
+
+// marshalObject encodes v and appends the extra properties, sorted by key,
+// after the known ones. Dropped known properties the fields leave out take
+// their place among them.
+func marshalObject(v any, extra, dropped map[string]json.RawMessage) ([]byte, error) {
+	data, err := encode(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(dropped) > 0 {
+		if data, err = restoreDropped(data, reflect.TypeOf(v), dropped); err != nil {
+			return nil, err
+		}
+	}
+	if len(extra) == 0 {
+		return data, nil
+	}
+
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := bytes.NewBuffer(data[:len(data)-1])
+	for i, key := range keys {
+		if i > 0 || len(data) > 2 {
+			out.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(extra[key])
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// restoreDropped re-encodes the object data of type t in field order, with
+// the dropped properties it lacks written back
+func restoreDropped(data []byte, t reflect.Type, dropped map[string]json.RawMessage) ([]byte, error) {
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(data, &present); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for _, name := range fieldNames(t) {
+		value, ok := present[name]
+		if !ok {
+			if value, ok = dropped[name]; !ok {
+				continue
+			}
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// encode encodes v without escaping HTML characters
+func encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// unmarshalObject decodes data into v and returns the properties that do not
+// map onto one of its fields, and the known ones the fields drop when
+// encoded again, such as null or "". Numbers in untyped fields decode as
+// json.Number so they survive a round trip unchanged.
+func unmarshalObject(data []byte, v any) (extra, dropped map[string]json.RawMessage, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return nil, nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+	var encoded map[string]json.RawMessage
+	for _, name := range fieldNames(reflect.TypeOf(v).Elem()) {
+		value, ok := raw[name]
+		if !ok {
+			continue
+		}
+		delete(raw, name)
+		if encoded == nil {
+			data, err := encode(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := json.Unmarshal(data, &encoded); err != nil {
+				return nil, nil, err
+			}
+		}
+		if _, ok := encoded[name]; !ok {
+			if dropped == nil {
+				dropped = make(map[string]json.RawMessage)
+			}
+			dropped[name] = value
+		}
+	}
+	if len(raw) == 0 {
+		raw = nil
+	}
+	return raw, dropped, nil
+}
+
+// fieldNames returns the JSON property names of a struct type
+func fieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		tag := t.Field(i).Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
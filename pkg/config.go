@@ -1,9 +1,415 @@
 package deepspec
 
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
 const (
-	// MCPServerAddress defines the address for the MCP server.
-	MCPServerAddress = "http://localhost:8080/mcp"
+	// DefaultServerAddr is the address the MCP server listens on and the TUI
+	// connects to over sse and http
+	DefaultServerAddr = ":8080"
+
+	// DefaultHealthInterval is the time between health checks of the MCP server
+	DefaultHealthInterval = 3 * time.Second
+
+	// DefaultMaxSteps is the number of tool call rounds a chat message may take
+	DefaultMaxSteps = 10
+
+	// DefaultToolTimeout is the time a tool call may take
+	DefaultToolTimeout = 30 * time.Second
+
+	// ProjectConfigFile is the config file looked up in the working directory
+	// and its parents
+	ProjectConfigFile = ".deepspec.yaml"
 
 	// Version defines the CLI version
 	Version = "v0.1.0"
 )
+
+// MCP transports of the server and client
+const (
+	TransportStdio = "stdio"
+	TransportSSE   = "sse"
+	TransportHTTP  = "http"
+)
+
+// Transports lists the supported MCP transports
+var Transports = []string{TransportStdio, TransportSSE, TransportHTTP}
+
+// Config is the effective configuration of the CLI, the server and the TUI.
+// Values are layered: defaults, the user config file, the project config
+// file, environment variables and finally flags.
+type Config struct {
+	// Specs is the directory of the committed specs
+	Specs string
+	// Source is the directory the specs were extracted from, which prompts
+	// read source files below
+	Source string
+	// LogFile receives the debug log
+	LogFile        string
+	HealthInterval time.Duration
+	// Provider names the LLM backend of the chat: vertex, openai or fake
+	Provider string
+	System   SystemConfig
+	Agent    AgentConfig
+	Server   ServerConfig
+	Vertex   VertexConfig
+	OpenAI   OpenAIConfig
+	Fake     FakeConfig
+	Cassette CassetteConfig
+
+	// sources maps setting keys to where their value came from
+	sources map[string]string
+}
+
+// SystemConfig composes the system prompt of the chat from the base rules,
+// the project context and the active spec
+type SystemConfig struct {
+	// Prompt replaces the built-in base rules
+	Prompt string
+	// PromptFile reads the base rules from a file, taking precedence over
+	// Prompt
+	PromptFile string
+	// ContextFile describes the project to the model
+	ContextFile string
+	// Spec is the spec file the chat works on
+	Spec string
+}
+
+// AgentConfig bounds the tool calls the model makes to answer a message
+type AgentConfig struct {
+	// MaxSteps is the number of rounds of tool calls; zero is unlimited
+	MaxSteps int
+	// ToolTimeout is the time a tool call may take; zero is unlimited
+	ToolTimeout time.Duration
+}
+
+// ServerConfig configures the MCP server and how the TUI reaches it
+type ServerConfig struct {
+	// Transport is stdio, sse or http
+	Transport string
+	// Addr is the listen address of the sse and http transports
+	Addr string
+	// Command starts the server for the stdio transport of the TUI
+	Command string
+}
+
+// VertexConfig configures the Vertex AI backend
+type VertexConfig struct {
+	Project  string
+	Location string
+	Model    string
+}
+
+// OpenAIConfig configures an OpenAI-compatible backend
+type OpenAIConfig struct {
+	// BaseURL is the API root, below which /chat/completions is requested
+	BaseURL string
+	APIKey  string
+	Model   string
+}
+
+// FakeConfig configures the scripted fake provider
+type FakeConfig struct {
+	// Script is a YAML or JSON script of model turns; empty plays a demo
+	Script string
+}
+
+// CassetteConfig selects a cassette recording or replaying the traffic of
+// the chat with the model and the MCP server
+type CassetteConfig struct {
+	// Mode is record, replay or empty for neither
+	Mode string
+	File string
+}
+
+// ConfigValue is a setting of the effective config
+type ConfigValue struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// configSetting maps a config key to its environment variable, flag and
+// field. Keys name the field in config files, nested with dots.
+type configSetting struct {
+	key   string
+	env   string
+	flag  string
+	field func(c *Config) any
+	// secret settings are masked when shown
+	secret bool
+	// path settings are file paths, which config files give relative to
+	// their own directory
+	path bool
+	// provider settings take their flag only while that provider is active
+	provider string
+}
+
+// configSettings lists every setting in display order
+var configSettings = []configSetting{
+	{key: "specs", env: "DEEPSPEC_SPECS", flag: "specs", field: func(c *Config) any { return &c.Specs }, path: true},
+	{key: "source", env: "DEEPSPEC_SOURCE", flag: "source", field: func(c *Config) any { return &c.Source }, path: true},
+	{key: "log_file", env: "DEEPSPEC_LOG_FILE", flag: "log-file", field: func(c *Config) any { return &c.LogFile }, path: true},
+	{key: "health_interval", env: "DEEPSPEC_HEALTH_INTERVAL", field: func(c *Config) any { return &c.HealthInterval }},
+	{key: "system.prompt", env: "DEEPSPEC_SYSTEM_PROMPT", field: func(c *Config) any { return &c.System.Prompt }},
+	{key: "system.prompt_file", env: "DEEPSPEC_SYSTEM_PROMPT_FILE", flag: "system-prompt", field: func(c *Config) any { return &c.System.PromptFile }, path: true},
+	{key: "system.context_file", env: "DEEPSPEC_CONTEXT_FILE", flag: "context", field: func(c *Config) any { return &c.System.ContextFile }, path: true},
+	{key: "system.spec", env: "DEEPSPEC_SPEC", flag: "spec", field: func(c *Config) any { return &c.System.Spec }, path: true},
+	{key: "agent.max_steps", env: "DEEPSPEC_MAX_STEPS", flag: "max-steps", field: func(c *Config) any { return &c.Agent.MaxSteps }},
+	{key: "agent.tool_timeout", env: "DEEPSPEC_TOOL_TIMEOUT", flag: "tool-timeout", field: func(c *Config) any { return &c.Agent.ToolTimeout }},
+	{key: "server.transport", env: "DEEPSPEC_TRANSPORT", flag: "transport", field: func(c *Config) any { return &c.Server.Transport }},
+	{key: "server.addr", env: "DEEPSPEC_ADDR", flag: "addr", field: func(c *Config) any { return &c.Server.Addr }},
+	{key: "server.command", env: "DEEPSPEC_SERVER_CMD", flag: "server-cmd", field: func(c *Config) any { return &c.Server.Command }},
+	{key: "provider", env: "DEEPSPEC_PROVIDER", flag: "provider", field: func(c *Config) any { return &c.Provider }},
+	{key: "vertex.project", env: "GOOGLE_CLOUD_PROJECT", field: func(c *Config) any { return &c.Vertex.Project }},
+	{key: "vertex.location", env: "GCP_LOCATION", field: func(c *Config) any { return &c.Vertex.Location }},
+	{key: "vertex.model", env: "GCP_MODEL_NAME", flag: "model", field: func(c *Config) any { return &c.Vertex.Model }, provider: ProviderVertex},
+	{key: "openai.base_url", env: "OPENAI_BASE_URL", field: func(c *Config) any { return &c.OpenAI.BaseURL }},
+	{key: "openai.api_key", env: "OPENAI_API_KEY", field: func(c *Config) any { return &c.OpenAI.APIKey }, secret: true},
+	{key: "openai.model", env: "OPENAI_MODEL", flag: "model", field: func(c *Config) any { return &c.OpenAI.Model }, provider: ProviderOpenAI},
+	{key: "fake.script", env: "DEEPSPEC_FAKE_SCRIPT", field: func(c *Config) any { return &c.Fake.Script }, path: true},
+	{key: "cassette.mode", env: "DEEPSPEC_CASSETTE_MODE", flag: "cassette-mode", field: func(c *Config) any { return &c.Cassette.Mode }},
+	{key: "cassette.file", env: "DEEPSPEC_CASSETTE", flag: "cassette", field: func(c *Config) any { return &c.Cassette.File }, path: true},
+}
+
+// DefaultConfig returns the built-in configuration
+func DefaultConfig() *Config {
+	c := &Config{
+		Specs:          DefaultSpecsDir,
+		Source:         ".",
+		LogFile:        "out.log",
+		HealthInterval: DefaultHealthInterval,
+		Provider:       ProviderVertex,
+		Agent: AgentConfig{
+			MaxSteps:    DefaultMaxSteps,
+			ToolTimeout: DefaultToolTimeout,
+		},
+		Server: ServerConfig{
+			Transport: TransportHTTP,
+			Addr:      DefaultServerAddr,
+		},
+		Vertex: VertexConfig{
+			Model: DefaultModelName,
+		},
+		OpenAI: OpenAIConfig{
+			BaseURL: DefaultOpenAIBaseURL,
+		},
+		sources: make(map[string]string),
+	}
+	for _, setting := range configSettings {
+		c.sources[setting.key] = "default"
+	}
+	return c
+}
+
+// LoadConfig merges the defaults, the user config file, the project config
+// file and the environment. Missing config files are skipped.
+func LoadConfig() (*Config, error) {
+	c := DefaultConfig()
+
+	var files []string
+	if file, err := UserConfigFile(); err == nil {
+		files = append(files, file)
+	}
+	if file, ok := findProjectConfig(); ok {
+		files = append(files, file)
+	}
+	for _, file := range files {
+		if err := c.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, setting := range configSettings {
+		if value, ok := os.LookupEnv(setting.env); ok && value != "" {
+			if err := c.set(setting, value, "env "+setting.env); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// UserConfigFile returns the path of the user config file,
+// $XDG_CONFIG_HOME/deepspec/config.yaml or ~/.config/deepspec/config.yaml
+func UserConfigFile() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "deepspec", "config.yaml"), nil
+}
+
+// findProjectConfig looks for the project config file in the working
+// directory and its parents
+func findProjectConfig() (string, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for {
+		file := filepath.Join(dir, ProjectConfigFile)
+		if fileExists(file) {
+			return file, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// loadFile applies the settings of a YAML config file. Relative paths are
+// resolved against the directory of the file.
+func (c *Config) loadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var document map[string]any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	values := make(map[string]string)
+	flattenConfig("", document, values)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		setting, ok := lookupConfigSetting(key)
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %s", file, key)
+		}
+		value := values[key]
+		if setting.path && value != "" && !filepath.IsAbs(value) {
+			value = filepath.Join(filepath.Dir(file), value)
+		}
+		if err := c.set(setting, value, file); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// flattenConfig turns nested YAML mappings into dotted keys
+func flattenConfig(prefix string, document map[string]any, values map[string]string) {
+	for name, value := range document {
+		key := prefix + name
+		switch value := value.(type) {
+		case map[string]any:
+			flattenConfig(key+".", value, values)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+// lookupConfigSetting finds a setting by key
+func lookupConfigSetting(key string) (configSetting, bool) {
+	for _, setting := range configSettings {
+		if setting.key == key {
+			return setting, true
+		}
+	}
+	return configSetting{}, false
+}
+
+// ApplyFlags overrides settings with the flags that were set on the command
+// line. Flags the command does not define are ignored, and flags shared by
+// providers, such as --model, set the active provider's setting.
+func (c *Config) ApplyFlags(flags *pflag.FlagSet) error {
+	for _, setting := range configSettings {
+		if setting.flag == "" || !flags.Changed(setting.flag) {
+			continue
+		}
+		// --provider precedes the provider settings, so this sees its value
+		if setting.provider != "" && setting.provider != c.Provider {
+			continue
+		}
+		if err := c.set(setting, flags.Lookup(setting.flag).Value.String(), "flag --"+setting.flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set parses a value into the field of a setting and records its source
+func (c *Config) set(setting configSetting, value, source string) error {
+	switch field := setting.field(c).(type) {
+	case *string:
+		*field = value
+	case *int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", setting.key, err)
+		}
+		*field = number
+	case *time.Duration:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", setting.key, err)
+		}
+		*field = duration
+	}
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[setting.key] = source
+	return nil
+}
+
+// Values lists every setting with its value and source
+func (c *Config) Values() []ConfigValue {
+	values := make([]ConfigValue, 0, len(configSettings))
+	for _, setting := range configSettings {
+		var value string
+		switch field := setting.field(c).(type) {
+		case *string:
+			value = *field
+		case *int:
+			value = strconv.Itoa(*field)
+		case *time.Duration:
+			value = field.String()
+		}
+		if setting.secret && value != "" {
+			value = "********"
+		}
+		values = append(values, ConfigValue{Key: setting.key, Value: value, Source: c.sources[setting.key]})
+	}
+	return values
+}
+
+// MCPEndpoint returns how the TUI reaches the configured MCP server
+func (c *Config) MCPEndpoint() MCPEndpoint {
+	return MCPEndpoint{
+		Transport: c.Server.Transport,
+		Addr:      c.Server.Addr,
+		Command:   strings.Fields(c.Server.Command),
+	}
+}
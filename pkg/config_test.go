@@ -0,0 +1,122 @@
+package deepspec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestLoadConfigResolvesPathsAgainstConfigFile(t *testing.T) {
+	root := t.TempDir()
+	config := `
+specs: specs
+provider: fake
+system:
+  prompt_file: prompts/rules.md
+  context_file: /etc/context.md
+  spec: ""
+fake:
+  script: ../script.yaml
+`
+	if err := os.WriteFile(filepath.Join(root, ProjectConfigFile), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "pkg", "sub")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, setting := range configSettings {
+		t.Setenv(setting.env, "")
+	}
+
+	c, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The working directory may be reached through a symlink, such as /tmp
+	// on macOS, so paths are compared by the directory of the config file
+	configDir := filepath.Dir(c.sources["specs"])
+
+	tests := []struct {
+		key  string
+		got  string
+		want string
+	}{
+		{key: "specs", got: c.Specs, want: filepath.Join(configDir, "specs")},
+		{key: "system.prompt_file", got: c.System.PromptFile, want: filepath.Join(configDir, "prompts", "rules.md")},
+		{key: "system.context_file", got: c.System.ContextFile, want: "/etc/context.md"},
+		{key: "system.spec", got: c.System.Spec, want: ""},
+		{key: "fake.script", got: c.Fake.Script, want: filepath.Join(filepath.Dir(configDir), "script.yaml")},
+		{key: "provider", got: c.Provider, want: ProviderFake},
+		{key: "log_file", got: c.LogFile, want: "out.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.key, tt.got, tt.want)
+			}
+		})
+	}
+	if same, err := sameFile(configDir, root); err != nil || !same {
+		t.Errorf("config file found in %s, want %s", configDir, root)
+	}
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
+
+func TestApplyFlagsModel(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// want are the vertex and openai models
+		want [2]string
+	}{
+		{name: "default provider", args: []string{"--model", "gemini-pro"}, want: [2]string{"gemini-pro", ""}},
+		{name: "openai", args: []string{"--provider", "openai", "--model", "llama3.2"}, want: [2]string{DefaultModelName, "llama3.2"}},
+		{name: "provider after model", args: []string{"--model", "llama3.2", "--provider", "openai"}, want: [2]string{DefaultModelName, "llama3.2"}},
+		{name: "fake", args: []string{"--provider", "fake", "--model", "m"}, want: [2]string{DefaultModelName, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.String("provider", ProviderVertex, "")
+			flags.String("model", "", "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			c := DefaultConfig()
+			if err := c.ApplyFlags(flags); err != nil {
+				t.Fatal(err)
+			}
+			if got := [2]string{c.Vertex.Model, c.OpenAI.Model}; got != tt.want {
+				t.Errorf("models = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSetWithoutDefaults(t *testing.T) {
+	setting, _ := lookupConfigSetting("specs")
+	c := &Config{}
+	if err := c.set(setting, "specs", "flag --specs"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Specs != "specs" || c.sources["specs"] != "flag --specs" {
+		t.Errorf("specs = %q from %q", c.Specs, c.sources["specs"])
+	}
+}
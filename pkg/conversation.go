@@ -0,0 +1,165 @@
+package deepspec
+
+import (
+	"fmt"
+	"time"
+)
+
+// Conversation message roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+	RoleError     = "error"
+	// RoleInfo is for anything else shown, such as help and command output
+	RoleInfo = "info"
+)
+
+// Conversation message statuses
+const (
+	StatusPending   = "pending"
+	StatusDone      = "done"
+	StatusError     = "error"
+	StatusCancelled = "cancelled"
+)
+
+// ConversationMessage is an entry of the conversation shown in the TUI
+type ConversationMessage struct {
+	Role string    `json:"role"`
+	Time time.Time `json:"time"`
+	Text string    `json:"text,omitempty"`
+	// Step and ToolCall are set on tool messages, Result once the tool ran
+	Step     int         `json:"step,omitempty"`
+	ToolCall *ToolCall   `json:"tool_call,omitempty"`
+	Result   *ToolResult `json:"result,omitempty"`
+	// Status is pending while an assistant answer or a tool call is awaited
+	Status string `json:"status"`
+	// Error is why an assistant answer or a tool call failed
+	Error string `json:"error,omitempty"`
+}
+
+// Conversation is the typed list of messages of a chat. An answer being
+// awaited is a pending assistant message, which is completed, failed or
+// cancelled when the exchange ends.
+type Conversation struct {
+	messages []ConversationMessage
+	// pending is the index of the pending assistant message, or -1
+	pending int
+}
+
+// NewConversation creates an empty conversation
+func NewConversation() *Conversation {
+	return &Conversation{pending: -1}
+}
+
+// Messages returns a copy of the messages
+func (c *Conversation) Messages() []ConversationMessage {
+	return append([]ConversationMessage(nil), c.messages...)
+}
+
+// Len returns the number of messages
+func (c *Conversation) Len() int {
+	return len(c.messages)
+}
+
+// Add appends a finished message of a role
+func (c *Conversation) Add(role, text string) {
+	c.add(ConversationMessage{Role: role, Text: text, Status: StatusDone})
+}
+
+// add appends a message, stamped with the current time
+func (c *Conversation) add(message ConversationMessage) {
+	message.Time = time.Now()
+	c.messages = append(c.messages, message)
+}
+
+// Pending reports whether an assistant answer is awaited
+func (c *Conversation) Pending() bool {
+	return c.pending >= 0
+}
+
+// Await appends a pending assistant message for the answer to come
+func (c *Conversation) Await() {
+	c.add(ConversationMessage{Role: RoleAssistant, Status: StatusPending})
+	c.pending = len(c.messages) - 1
+}
+
+// Stream appends a piece of answer text to the pending assistant message
+func (c *Conversation) Stream(text string) {
+	if c.pending >= 0 {
+		c.messages[c.pending].Text += text
+	}
+}
+
+// AddToolCall appends a pending tool message in the middle of an answer. The
+// text streamed so far stays a message of its own, and a new pending
+// assistant message follows the call.
+func (c *Conversation) AddToolCall(step int, call ToolCall) {
+	if c.pending >= 0 {
+		if c.messages[c.pending].Text == "" {
+			c.messages = append(c.messages[:c.pending], c.messages[c.pending+1:]...)
+		} else {
+			c.messages[c.pending].Status = StatusDone
+		}
+	}
+	c.add(ConversationMessage{Role: RoleTool, Step: step, ToolCall: &call, Status: StatusPending})
+	if c.pending >= 0 {
+		c.Await()
+	}
+}
+
+// SetToolResult completes the pending tool message of a call. Outputs with
+// an error fail it.
+func (c *Conversation) SetToolResult(step int, result ToolResult) {
+	for i := range c.messages {
+		message := &c.messages[i]
+		if message.Role != RoleTool || message.Status != StatusPending || message.Step != step ||
+			message.ToolCall.ID != result.ID || message.ToolCall.Name != result.Name {
+			continue
+		}
+		message.Result = &result
+		message.Status = StatusDone
+		if err, ok := result.Output["error"]; ok {
+			message.Status = StatusError
+			message.Error = fmt.Sprint(err)
+		}
+		return
+	}
+}
+
+// Complete sets the final text of the pending assistant message, or appends
+// an assistant message when none is pending
+func (c *Conversation) Complete(text string) {
+	c.messages[c.finish(StatusDone, "")].Text = text
+}
+
+// Fail marks the pending assistant message as failed, keeping the text
+// streamed so far
+func (c *Conversation) Fail(err string) {
+	c.finish(StatusError, err)
+}
+
+// Cancel marks the pending assistant message as cancelled, keeping the text
+// streamed so far
+func (c *Conversation) Cancel() {
+	c.finish(StatusCancelled, "")
+}
+
+// finish ends the pending assistant message, appending one if none is
+// pending, and returns its index
+func (c *Conversation) finish(status, err string) int {
+	if c.pending < 0 {
+		c.Await()
+	}
+	index := c.pending
+	c.messages[index].Status = status
+	c.messages[index].Error = err
+	c.pending = -1
+	return index
+}
+
+// Clear removes every message
+func (c *Conversation) Clear() {
+	c.messages = nil
+	c.pending = -1
+}
@@ -0,0 +1,139 @@
+package deepspec
+
+import (
+	"slices"
+	"testing"
+)
+
+// conversationEntry is what the tests compare of a conversation message
+type conversationEntry struct {
+	Role, Status, Text, Error string
+}
+
+// conversationEntries returns the entries of the messages of a role other
+// than info
+func conversationEntries(messages []ConversationMessage) []conversationEntry {
+	var entries []conversationEntry
+	for _, message := range messages {
+		if message.Role != RoleInfo {
+			entries = append(entries, conversationEntry{message.Role, message.Status, message.Text, message.Error})
+		}
+	}
+	return entries
+}
+
+func TestConversationStatuses(t *testing.T) {
+	call := ToolCall{ID: "1", Name: "echo", Args: map[string]any{"text": "hello"}}
+	tests := []struct {
+		name        string
+		run         func(c *Conversation)
+		want        []conversationEntry
+		wantPending bool
+	}{
+		{
+			name: "pending",
+			run: func(c *Conversation) {
+				c.Add(RoleUser, "hi")
+				c.Await()
+				c.Stream("Hel")
+			},
+			want:        []conversationEntry{{RoleUser, StatusDone, "hi", ""}, {RoleAssistant, StatusPending, "Hel", ""}},
+			wantPending: true,
+		},
+		{
+			name: "completed",
+			run: func(c *Conversation) {
+				c.Await()
+				c.Stream("Hel")
+				c.Stream("lo")
+				c.Complete("Hello")
+			},
+			want: []conversationEntry{{RoleAssistant, StatusDone, "Hello", ""}},
+		},
+		{
+			name: "completed without pending answer",
+			run: func(c *Conversation) {
+				c.Complete("Hello")
+			},
+			want: []conversationEntry{{RoleAssistant, StatusDone, "Hello", ""}},
+		},
+		{
+			name: "tool call after text",
+			run: func(c *Conversation) {
+				c.Await()
+				c.Stream("Let me check")
+				c.AddToolCall(1, call)
+				c.SetToolResult(1, ToolResult{ID: "1", Name: "echo", Output: map[string]any{"output": "hello"}})
+				c.Complete("Done")
+			},
+			want: []conversationEntry{{RoleAssistant, StatusDone, "Let me check", ""}, {RoleTool, StatusDone, "", ""}, {RoleAssistant, StatusDone, "Done", ""}},
+		},
+		{
+			name: "tool call before text",
+			run: func(c *Conversation) {
+				c.Await()
+				c.AddToolCall(1, call)
+			},
+			want:        []conversationEntry{{RoleTool, StatusPending, "", ""}, {RoleAssistant, StatusPending, "", ""}},
+			wantPending: true,
+		},
+		{
+			name: "tool error",
+			run: func(c *Conversation) {
+				c.Await()
+				c.AddToolCall(1, call)
+				c.SetToolResult(1, ToolResult{ID: "1", Name: "echo", Output: map[string]any{"error": "boom"}})
+			},
+			want:        []conversationEntry{{RoleTool, StatusError, "", "boom"}, {RoleAssistant, StatusPending, "", ""}},
+			wantPending: true,
+		},
+		{
+			name: "result of another step",
+			run: func(c *Conversation) {
+				c.Await()
+				c.AddToolCall(1, call)
+				c.SetToolResult(2, ToolResult{ID: "1", Name: "echo", Output: map[string]any{"output": "hello"}})
+			},
+			want:        []conversationEntry{{RoleTool, StatusPending, "", ""}, {RoleAssistant, StatusPending, "", ""}},
+			wantPending: true,
+		},
+		{
+			name: "failed",
+			run: func(c *Conversation) {
+				c.Await()
+				c.Stream("partial")
+				c.Fail("overloaded")
+			},
+			want: []conversationEntry{{RoleAssistant, StatusError, "partial", "overloaded"}},
+		},
+		{
+			name: "cancelled",
+			run: func(c *Conversation) {
+				c.Await()
+				c.Stream("partial")
+				c.Cancel()
+			},
+			want: []conversationEntry{{RoleAssistant, StatusCancelled, "partial", ""}},
+		},
+		{
+			name: "cleared",
+			run: func(c *Conversation) {
+				c.Add(RoleUser, "hi")
+				c.Await()
+				c.Clear()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConversation()
+			tt.run(c)
+			if got := conversationEntries(c.Messages()); !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %+v, want %+v", got, tt.want)
+			}
+			if c.Pending() != tt.wantPending {
+				t.Errorf("Pending() = %v, want %v", c.Pending(), tt.wantPending)
+			}
+		})
+	}
+}
@@ -0,0 +1,469 @@
+package deepspec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// ChangeKind classifies a semantic spec change
+type ChangeKind string
+
+// Change kinds reported by DiffSpecs
+const (
+	ChangeAdded         ChangeKind = "added"
+	ChangeRemoved       ChangeKind = "removed"
+	ChangeRenamed       ChangeKind = "renamed"
+	ChangeSignature     ChangeKind = "signature_changed"
+	ChangeVisibility    ChangeKind = "visibility_changed"
+	ChangeDocumentation ChangeKind = "documentation_changed"
+	ChangeBody          ChangeKind = "body_changed"
+)
+
+// Entities a change can apply to
+const (
+	EntityImport   = "import"
+	EntityType     = "type"
+	EntityField    = "field"
+	EntityFunction = "function"
+	EntityConstant = "constant"
+	EntityVariable = "variable"
+)
+
+// SpecChange is a single semantic difference between two specs. Signature
+// changes carry the old and new rendering, renames the previous name.
+type SpecChange struct {
+	Kind    ChangeKind `json:"kind"`
+	Entity  string     `json:"entity"`
+	Name    string     `json:"name"`
+	OldName string     `json:"old_name,omitempty"`
+	Old     string     `json:"old,omitempty"`
+	New     string     `json:"new,omitempty"`
+}
+
+// String renders the change as a single line
+func (c SpecChange) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s %s", c.Entity, c.Name)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s %s", c.Entity, c.Name)
+	case ChangeRenamed:
+		return fmt.Sprintf("> %s %s renamed to %s", c.Entity, c.OldName, c.Name)
+	case ChangeBody, ChangeDocumentation:
+		if c.Old != "" || c.New != "" {
+			break
+		}
+		return fmt.Sprintf("~ %s %s: %s", c.Entity, c.Name, strings.ReplaceAll(string(c.Kind), "_", " "))
+	}
+	return fmt.Sprintf("~ %s %s: %s: %s -> %s", c.Entity, c.Name, strings.ReplaceAll(string(c.Kind), "_", " "), c.Old, c.New)
+}
+
+// FileDiff holds the changes of a single source file between two spec sets
+type FileDiff struct {
+	File    string       `json:"file"`
+	Status  string       `json:"status"`
+	Changes []SpecChange `json:"changes,omitempty"`
+}
+
+// DiffSpecs compares two specs of the same file by identity rather than by
+// position: types and constants by name, functions by receiver and name,
+// fields by type and field name. Removed and added entities that look alike
+// are reported as renames.
+func DiffSpecs(old, current *codespec.Spec) []SpecChange {
+	var changes []SpecChange
+	changes = append(changes, diffImports(old.Imports, current.Imports)...)
+	changes = append(changes, diffTypes(old.Types, current.Types)...)
+	changes = append(changes, diffFunctions(old.Functions, current.Functions)...)
+	changes = append(changes, diffConstants(old.Constants, current.Constants)...)
+	changes = append(changes, diffVariables(old.Variables, current.Variables)...)
+	return changes
+}
+
+// DiffSpecSets pairs two sets of specs by source file and diffs each pair
+func DiffSpecSets(old, current []*codespec.Spec) []FileDiff {
+	oldByFile := make(map[string]*codespec.Spec)
+	for _, spec := range old {
+		oldByFile[spec.File] = spec
+	}
+
+	var diffs []FileDiff
+	for _, spec := range current {
+		prev, ok := oldByFile[spec.File]
+		if !ok {
+			diffs = append(diffs, FileDiff{File: spec.File, Status: "added"})
+			continue
+		}
+		delete(oldByFile, spec.File)
+		if changes := DiffSpecs(prev, spec); len(changes) > 0 {
+			diffs = append(diffs, FileDiff{File: spec.File, Status: "modified", Changes: changes})
+		}
+	}
+	for file := range oldByFile {
+		diffs = append(diffs, FileDiff{File: file, Status: "removed"})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].File < diffs[j].File })
+	return diffs
+}
+
+// LoadSpecSet reads every spec below the given files or directories
+func LoadSpecSet(paths ...string) ([]*codespec.Spec, error) {
+	files, err := FindSpecFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	var specs []*codespec.Spec
+	for _, file := range files {
+		spec, err := codespec.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// diffEntity is the comparable view of a named spec entity
+type diffEntity struct {
+	key           string
+	signature     string
+	visibility    string
+	documentation string
+	body          []string
+	// children are diffed recursively when the entity is kept or renamed
+	children func(other *diffEntity) []SpecChange
+}
+
+// diffEntities matches entities by key, pairs leftovers as renames and
+// compares everything that survived
+func diffEntities(entity string, old, current []*diffEntity) []SpecChange {
+	oldByKey := make(map[string]*diffEntity)
+	for _, e := range old {
+		oldByKey[e.key] = e
+	}
+
+	var changes, kept []SpecChange
+	var added []*diffEntity
+	for _, e := range current {
+		prev, ok := oldByKey[e.key]
+		if !ok {
+			added = append(added, e)
+			continue
+		}
+		delete(oldByKey, e.key)
+		kept = append(kept, compareEntity(entity, prev, e)...)
+	}
+
+	var removed []*diffEntity
+	for _, e := range old {
+		if _, ok := oldByKey[e.key]; ok {
+			removed = append(removed, e)
+		}
+	}
+
+	// Pair removed and added entities with the same signature, preferring the
+	// most similar body
+	var unmatched []*diffEntity
+	for _, e := range added {
+		best, bestScore := -1, 0.0
+		for i, prev := range removed {
+			if prev == nil || prev.signature != e.signature {
+				continue
+			}
+			if score := similarity(prev.body, e.body); best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 || (len(e.body) > 0 && bestScore < 0.8) {
+			unmatched = append(unmatched, e)
+			continue
+		}
+		prev := removed[best]
+		removed[best] = nil
+		changes = append(changes, SpecChange{Kind: ChangeRenamed, Entity: entity, Name: e.key, OldName: prev.key})
+		changes = append(changes, compareEntity(entity, prev, e)...)
+	}
+
+	for _, e := range unmatched {
+		changes = append(changes, SpecChange{Kind: ChangeAdded, Entity: entity, Name: e.key, New: e.signature})
+	}
+	for _, e := range removed {
+		if e != nil {
+			changes = append(changes, SpecChange{Kind: ChangeRemoved, Entity: entity, Name: e.key, Old: e.signature})
+		}
+	}
+	return append(changes, kept...)
+}
+
+// compareEntity reports the differences of two matched entities
+func compareEntity(entity string, old, current *diffEntity) []SpecChange {
+	var changes []SpecChange
+	if old.signature != current.signature {
+		changes = append(changes, SpecChange{Kind: ChangeSignature, Entity: entity, Name: current.key, Old: old.signature, New: current.signature})
+	}
+	if old.visibility != current.visibility {
+		changes = append(changes, SpecChange{Kind: ChangeVisibility, Entity: entity, Name: current.key, Old: old.visibility, New: current.visibility})
+	}
+	if old.documentation != current.documentation {
+		changes = append(changes, SpecChange{Kind: ChangeDocumentation, Entity: entity, Name: current.key})
+	}
+	if strings.Join(old.body, "\n") != strings.Join(current.body, "\n") {
+		change := SpecChange{Kind: ChangeBody, Entity: entity, Name: current.key}
+		// Values of constants and variables are short enough to show inline
+		if entity == EntityConstant || entity == EntityVariable {
+			change.Old, change.New = old.body[0], current.body[0]
+		}
+		changes = append(changes, change)
+	}
+	if current.children != nil {
+		changes = append(changes, current.children(old)...)
+	}
+	return changes
+}
+
+// similarity is the share of statement signatures two bodies have in common
+func similarity(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	counts := make(map[string]int)
+	for _, sig := range a {
+		counts[sig]++
+	}
+	common := 0
+	for _, sig := range b {
+		if counts[sig] > 0 {
+			counts[sig]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+// diffImports compares imports by path
+func diffImports(old, current []codespec.Import) []SpecChange {
+	toEntities := func(imports []codespec.Import) []*diffEntity {
+		var entities []*diffEntity
+		for _, imp := range imports {
+			// Imports are never renamed, so the path doubles as signature
+			entities = append(entities, &diffEntity{key: imp.Path, signature: imp.Path + " as " + imp.Alias})
+		}
+		return entities
+	}
+	return diffEntities(EntityImport, toEntities(old), toEntities(current))
+}
+
+// diffTypes compares type definitions and their fields
+func diffTypes(old, current []codespec.TypeDefinition) []SpecChange {
+	toEntities := func(types []codespec.TypeDefinition) []*diffEntity {
+		var entities []*diffEntity
+		for i := range types {
+			t := &types[i]
+			var shape []string
+			for _, field := range t.Fields {
+				shape = append(shape, field.Name+" "+fieldSignature(&field))
+			}
+			entity := &diffEntity{
+				key:           t.Name,
+				signature:     t.Kind,
+				visibility:    t.Visibility,
+				documentation: t.Documentation,
+				body:          append(shape, t.Methods...),
+			}
+			entity.children = func(other *diffEntity) []SpecChange {
+				return diffFields(t.Name, typeByName(old, other.key), t)
+			}
+			entities = append(entities, entity)
+		}
+		return entities
+	}
+
+	changes := diffEntities(EntityType, toEntities(old), toEntities(current))
+	// Field changes are reported individually, so drop the coarse body change
+	filtered := changes[:0]
+	for _, change := range changes {
+		if !(change.Entity == EntityType && change.Kind == ChangeBody) {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
+// typeByName finds a type definition in a list
+func typeByName(types []codespec.TypeDefinition, name string) *codespec.TypeDefinition {
+	for i := range types {
+		if types[i].Name == name {
+			return &types[i]
+		}
+	}
+	return nil
+}
+
+// diffFields compares the fields and interface methods of two versions of a type
+func diffFields(typeName string, old, current *codespec.TypeDefinition) []SpecChange {
+	if old == nil || current == nil {
+		return nil
+	}
+	toEntities := func(t *codespec.TypeDefinition) []*diffEntity {
+		var entities []*diffEntity
+		for i := range t.Fields {
+			field := &t.Fields[i]
+			entities = append(entities, &diffEntity{
+				key:           typeName + "." + field.Name,
+				signature:     fieldSignature(field),
+				visibility:    field.Visibility,
+				documentation: field.Documentation,
+			})
+		}
+		for _, method := range t.Methods {
+			name, _, _ := strings.Cut(method, "(")
+			entities = append(entities, &diffEntity{
+				key:       typeName + "." + name,
+				signature: "method " + method,
+			})
+		}
+		return entities
+	}
+	return diffEntities(EntityField, toEntities(old), toEntities(current))
+}
+
+// fieldSignature renders a field type with its tags
+func fieldSignature(field *codespec.Field) string {
+	return strings.TrimSpace(field.Type + " " + structTag(field.Tags))
+}
+
+// diffFunctions compares functions and methods
+func diffFunctions(old, current []codespec.Function) []SpecChange {
+	toEntities := func(functions []codespec.Function) []*diffEntity {
+		var entities []*diffEntity
+		for i := range functions {
+			fn := &functions[i]
+			body := statementSignatures(fn.BodyStatements)
+			if fn.BodySummary != "" {
+				body = append(body, "summary: "+fn.BodySummary)
+			}
+			entities = append(entities, &diffEntity{
+				key:           functionIdentity(fn),
+				signature:     functionShape(fn),
+				visibility:    fn.Visibility,
+				documentation: fn.Documentation,
+				body:          body,
+			})
+		}
+		return entities
+	}
+
+	changes := diffEntities(EntityFunction, toEntities(old), toEntities(current))
+	// Signatures of renamed functions are compared without their names, so
+	// render full signatures for anything reported as a signature change
+	for i, change := range changes {
+		if change.Kind == ChangeSignature {
+			changes[i].Old = functionSignature(functionByIdentity(old, change.Name))
+			changes[i].New = functionSignature(functionByIdentity(current, change.Name))
+		}
+	}
+	return changes
+}
+
+// functionIdentity identifies a function by receiver base type and name, so a
+// switch between pointer and value receivers is a signature change
+func functionIdentity(fn *codespec.Function) string {
+	if fn.Receiver == nil {
+		return fn.Name
+	}
+	recv := strings.TrimPrefix(strings.TrimSpace(fn.Receiver.Type), "*")
+	if idx := strings.Index(recv, "["); idx >= 0 {
+		recv = recv[:idx]
+	}
+	return recv + "." + fn.Name
+}
+
+// functionByIdentity finds a function in a list
+func functionByIdentity(functions []codespec.Function, key string) *codespec.Function {
+	for i := range functions {
+		if functionIdentity(&functions[i]) == key {
+			return &functions[i]
+		}
+	}
+	return &codespec.Function{Name: key}
+}
+
+// functionShape renders a signature without the function name, which is
+// what renamed functions have in common
+func functionShape(fn *codespec.Function) string {
+	shape := *fn
+	shape.Name = ""
+	return functionSignature(&shape)
+}
+
+// functionSignature renders receiver, parameters and returns of a function
+func functionSignature(fn *codespec.Function) string {
+	var params, returns []string
+	for _, p := range fn.Parameters {
+		params = append(params, strings.TrimSpace(p.Name+" "+p.Type))
+	}
+	for _, r := range fn.Returns {
+		returns = append(returns, strings.TrimSpace(r.Name+" "+r.Type))
+	}
+	signature := "func "
+	if fn.Receiver != nil {
+		signature += "(" + strings.TrimSpace(fn.Receiver.Name+" "+fn.Receiver.Type) + ") "
+	}
+	signature += fn.Name + "(" + strings.Join(params, ", ") + ")"
+	switch len(returns) {
+	case 0:
+	case 1:
+		signature += " " + returns[0]
+	default:
+		signature += " (" + strings.Join(returns, ", ") + ")"
+	}
+	return signature
+}
+
+// diffConstants compares constants, treating value changes as body changes
+func diffConstants(old, current []codespec.Constant) []SpecChange {
+	toEntities := func(constants []codespec.Constant) []*diffEntity {
+		var entities []*diffEntity
+		for _, c := range constants {
+			entities = append(entities, &diffEntity{
+				key:           c.Name,
+				signature:     c.Type,
+				visibility:    exportedVisibility(c.IsExported()),
+				documentation: c.Doc,
+				body:          []string{formatJSON(c.Value)},
+			})
+		}
+		return entities
+	}
+	return diffEntities(EntityConstant, toEntities(old), toEntities(current))
+}
+
+// diffVariables compares variables, treating initialization changes as body changes
+func diffVariables(old, current []codespec.Variable) []SpecChange {
+	toEntities := func(variables []codespec.Variable) []*diffEntity {
+		var entities []*diffEntity
+		for _, v := range variables {
+			entities = append(entities, &diffEntity{
+				key:           v.Name,
+				signature:     v.Type,
+				visibility:    exportedVisibility(v.IsExported()),
+				documentation: v.Doc,
+				body:          []string{formatJSON(v.Initialization)},
+			})
+		}
+		return entities
+	}
+	return diffEntities(EntityVariable, toEntities(old), toEntities(current))
+}
+
+// exportedVisibility maps the exported flag of constants and variables onto
+// the visibility values used for other entities
+func exportedVisibility(exported bool) string {
+	if exported {
+		return codespec.VisibilityExported
+	}
+	return codespec.VisibilityUnexported
+}
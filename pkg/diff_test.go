@@ -0,0 +1,166 @@
+package deepspec
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// testFunction builds a function taking and returning an int whose body
+// runs the given statements
+func testFunction(name, visibility string, code ...string) codespec.Function {
+	fn := codespec.Function{
+		Name:       name,
+		Visibility: visibility,
+		Parameters: []codespec.Parameter{{Name: "n", Type: "int"}},
+		Returns:    []codespec.ReturnValue{{Type: "int"}},
+	}
+	for _, c := range code {
+		fn.BodyStatements = append(fn.BodyStatements, codespec.Statement{Type: "expression", Code: c})
+	}
+	return fn
+}
+
+// withReceiver sets the receiver of a function
+func withReceiver(fn codespec.Function, recv string) codespec.Function {
+	fn.Receiver = &codespec.Receiver{Name: "s", Type: recv}
+	return fn
+}
+
+func TestDiffSpecs(t *testing.T) {
+	exported, unexported := codespec.VisibilityExported, codespec.VisibilityUnexported
+	tests := []struct {
+		name     string
+		old, new codespec.Spec
+		want     []string
+	}{
+		{
+			name: "unchanged",
+			old:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", exported, "a()", "b()")}},
+			new:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", exported, "a()", "b()")}},
+		},
+		{
+			name: "renamed with the same body",
+			old:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", exported, "a()", "b()")}},
+			new:  codespec.Spec{Functions: []codespec.Function{testFunction("Total", exported, "a()", "b()")}},
+			want: []string{"> function Sum renamed to Total"},
+		},
+		{
+			name: "renamed with a similar body",
+			old:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", exported, "a()", "b()", "c()", "d()", "e()")}},
+			new:  codespec.Spec{Functions: []codespec.Function{testFunction("Total", exported, "a()", "b()", "c()", "d()", "f()")}},
+			want: []string{"> function Sum renamed to Total", "~ function Total: body changed"},
+		},
+		{
+			name: "renamed and unexported",
+			old:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", exported, "a()")}},
+			new:  codespec.Spec{Functions: []codespec.Function{testFunction("sum", unexported, "a()")}},
+			want: []string{"> function Sum renamed to sum", "~ function sum: visibility changed: exported -> unexported"},
+		},
+		{
+			name: "different body is no rename",
+			old:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", exported, "a()", "b()")}},
+			new:  codespec.Spec{Functions: []codespec.Function{testFunction("Total", exported, "c()", "d()")}},
+			want: []string{"+ function Total", "- function Sum"},
+		},
+		{
+			name: "different signature is no rename",
+			old:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", exported, "a()")}},
+			new: codespec.Spec{Functions: []codespec.Function{func() codespec.Function {
+				fn := testFunction("Total", exported, "a()")
+				fn.Parameters[0].Type = "int64"
+				return fn
+			}()}},
+			want: []string{"+ function Total", "- function Sum"},
+		},
+		{
+			name: "rename pairs the most similar body",
+			old: codespec.Spec{Functions: []codespec.Function{
+				testFunction("Sum", exported, "a()", "b()", "c()"),
+				testFunction("Product", exported, "x()", "y()", "z()"),
+			}},
+			new:  codespec.Spec{Functions: []codespec.Function{testFunction("Multiply", exported, "x()", "y()", "z()")}},
+			want: []string{"> function Product renamed to Multiply", "- function Sum"},
+		},
+		{
+			name: "visibility changed",
+			old:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", codespec.VisibilityPublic, "a()")}},
+			new:  codespec.Spec{Functions: []codespec.Function{testFunction("Sum", codespec.VisibilityProtected, "a()")}},
+			want: []string{"~ function Sum: visibility changed: public -> protected"},
+		},
+		{
+			name: "pointer receiver is a signature change",
+			old:  codespec.Spec{Functions: []codespec.Function{withReceiver(testFunction("Len", exported, "a()"), "*List")}},
+			new:  codespec.Spec{Functions: []codespec.Function{withReceiver(testFunction("Len", exported, "a()"), "List")}},
+			want: []string{"~ function List.Len: signature changed: func (s *List) Len(n int) int -> func (s List) Len(n int) int"},
+		},
+		{
+			name: "methods of different receivers are distinct",
+			old:  codespec.Spec{Functions: []codespec.Function{withReceiver(testFunction("Len", exported, "a()"), "*List")}},
+			new: codespec.Spec{Functions: []codespec.Function{
+				withReceiver(testFunction("Len", exported, "a()"), "*List"),
+				withReceiver(testFunction("Len", exported, "b()"), "Set[T]"),
+			}},
+			want: []string{"+ function Set.Len"},
+		},
+		{
+			name: "constant unexported",
+			old:  codespec.Spec{Constants: []codespec.Constant{{Name: "Max", Type: "int", Value: json.RawMessage("3"), Exported: codespec.Bool(true)}}},
+			new:  codespec.Spec{Constants: []codespec.Constant{{Name: "Max", Type: "int", Value: json.RawMessage("4")}}},
+			want: []string{"~ constant Max: visibility changed: exported -> unexported", "~ constant Max: body changed: 3 -> 4"},
+		},
+		{
+			name: "field renamed and type documented",
+			old: codespec.Spec{Types: []codespec.TypeDefinition{{Name: "Point", Kind: "struct", Visibility: exported,
+				Fields: []codespec.Field{{Name: "X", Type: "int", Visibility: exported}, {Name: "Y", Type: "string", Visibility: exported}}}}},
+			new: codespec.Spec{Types: []codespec.TypeDefinition{{Name: "Point", Kind: "struct", Visibility: exported, Documentation: "Point is a point",
+				Fields: []codespec.Field{{Name: "Left", Type: "int", Visibility: exported}, {Name: "Y", Type: "string", Visibility: unexported}}}}},
+			want: []string{
+				"~ type Point: documentation changed",
+				"> field Point.X renamed to Point.Left",
+				"~ field Point.Y: visibility changed: exported -> unexported",
+			},
+		},
+		{
+			name: "type kind changed",
+			old:  codespec.Spec{Types: []codespec.TypeDefinition{{Name: "ID", Kind: "struct", Visibility: exported}}},
+			new:  codespec.Spec{Types: []codespec.TypeDefinition{{Name: "ID", Kind: "alias", Visibility: exported}}},
+			want: []string{"~ type ID: signature changed: struct -> alias"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, change := range DiffSpecs(&tt.old, &tt.new) {
+				got = append(got, change.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("changes:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffSpecSets(t *testing.T) {
+	old := []*codespec.Spec{
+		{File: "a.go", Functions: []codespec.Function{testFunction("A", codespec.VisibilityExported)}},
+		{File: "b.go"},
+		{File: "gone.go"},
+	}
+	current := []*codespec.Spec{
+		{File: "a.go", Functions: []codespec.Function{testFunction("A", codespec.VisibilityExported, "a()")}},
+		{File: "b.go"},
+		{File: "new.go"},
+	}
+
+	var got []string
+	for _, diff := range DiffSpecSets(old, current) {
+		got = append(got, diff.File+" "+diff.Status)
+	}
+	want := []string{"a.go modified", "gone.go removed", "new.go added"}
+	if !slices.Equal(got, want) {
+		t.Errorf("file diffs = %q, want %q", got, want)
+	}
+}
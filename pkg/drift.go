@@ -0,0 +1,165 @@
+package deepspec
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// DriftReport compares committed specs with specs re-extracted from source
+type DriftReport struct {
+	Stale    []SpecDrift `json:"stale,omitempty"`
+	Missing  []string    `json:"missing,omitempty"`
+	Orphaned []string    `json:"orphaned,omitempty"`
+	Invalid  []string    `json:"invalid,omitempty"`
+	Fixed    bool        `json:"fixed"`
+}
+
+// SpecDrift lists the semantic changes between a committed spec and its source
+type SpecDrift struct {
+	File     string       `json:"file"`
+	SpecPath string       `json:"spec_path"`
+	Changes  []SpecChange `json:"changes"`
+}
+
+// HasDrift reports whether any spec is stale, missing or orphaned
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Stale) > 0 || len(r.Missing) > 0 || len(r.Orphaned) > 0 || len(r.Invalid) > 0
+}
+
+// HasUnfixedDrift reports whether drift remains after the report's fix:
+// orphaned and invalid specs are never fixed, stale and missing ones only
+// when the specs were rewritten
+func (r *DriftReport) HasUnfixedDrift() bool {
+	if !r.Fixed {
+		return r.HasDrift()
+	}
+	return len(r.Orphaned) > 0 || len(r.Invalid) > 0
+}
+
+// String renders the report as human readable text
+func (r *DriftReport) String() string {
+	var b strings.Builder
+	for _, stale := range r.Stale {
+		fmt.Fprintf(&b, "%s (%s): stale\n", stale.File, stale.SpecPath)
+		for _, change := range stale.Changes {
+			fmt.Fprintf(&b, "  %s\n", change)
+		}
+	}
+	for _, file := range r.Missing {
+		fmt.Fprintf(&b, "%s: missing spec\n", file)
+	}
+	for _, path := range r.Orphaned {
+		fmt.Fprintf(&b, "%s: orphaned spec, source file no longer exists\n", path)
+	}
+	for _, path := range r.Invalid {
+		fmt.Fprintf(&b, "%s: invalid spec\n", path)
+	}
+	if !r.HasDrift() {
+		b.WriteString("Specs are up to date\n")
+	} else if r.Fixed {
+		b.WriteString("Stale and missing specs rewritten\n")
+	}
+	return b.String()
+}
+
+// DetectDrift re-extracts specs from sourceRoot and compares them with the
+// specs committed in specsDir. Only specs in the languages of the extractors
+// are compared, Go when none are given. A missing specs directory has no
+// specs. With fix set, stale specs are rewritten in place and missing ones
+// are written next to them, creating the specs directory if needed.
+func DetectDrift(sourceRoot, specsDir string, fix bool, extractors ...Extractor) (*DriftReport, error) {
+	if len(extractors) == 0 {
+		extractor, err := NewGoExtractor(sourceRoot)
+		if err != nil {
+			return nil, err
+		}
+		extractors = []Extractor{extractor}
+	}
+	current, err := ExtractSpecs(sourceRoot, extractors...)
+	if err != nil {
+		return nil, err
+	}
+
+	languages := make(map[string]bool)
+	for _, extractor := range extractors {
+		languages[extractor.Language()] = true
+	}
+	report := &DriftReport{}
+	committed, err := loadCommittedSpecs(specsDir, languages, report)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spec := range current {
+		old, ok := committed[spec.File]
+		if !ok {
+			report.Missing = append(report.Missing, spec.File)
+			if fix {
+				if err := WriteSpecs([]*codespec.Spec{spec}, specsDir); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		delete(committed, spec.File)
+
+		changes := DiffSpecs(old.spec, spec)
+		if len(changes) == 0 {
+			continue
+		}
+		report.Stale = append(report.Stale, SpecDrift{File: spec.File, SpecPath: old.path, Changes: changes})
+		if fix {
+			// Keep anything added to the spec by hand that extraction cannot know
+			spec.Schema = old.spec.Schema
+			spec.Extra = old.spec.Extra
+			if err := spec.WriteFile(old.path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, old := range committed {
+		report.Orphaned = append(report.Orphaned, old.path)
+	}
+	sort.Strings(report.Orphaned)
+
+	report.Fixed = fix && (len(report.Stale) > 0 || len(report.Missing) > 0)
+	return report, nil
+}
+
+// committedSpec is a spec file read from the specs directory
+type committedSpec struct {
+	path string
+	spec *codespec.Spec
+}
+
+// loadCommittedSpecs reads every spec in one of the languages below dir,
+// keyed by source file
+func loadCommittedSpecs(dir string, languages map[string]bool, report *DriftReport) (map[string]committedSpec, error) {
+	committed := make(map[string]committedSpec)
+	files, err := FindSpecFiles([]string{dir})
+	if errors.Is(err, fs.ErrNotExist) {
+		return committed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		spec, err := codespec.ReadFile(path)
+		if err != nil {
+			report.Invalid = append(report.Invalid, path)
+			continue
+		}
+		if !languages[spec.Language] {
+			continue
+		}
+		committed[spec.File] = committedSpec{path: filepath.Clean(path), spec: spec}
+	}
+	return committed, nil
+}
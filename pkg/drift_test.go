@@ -0,0 +1,138 @@
+package deepspec
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDetectDrift(t *testing.T) {
+	const source = "package shop\n\n// Total adds the prices\nfunc Total(prices []int) int {\n\treturn 0\n}\n"
+	const changed = "package shop\n\n// Total adds the prices\nfunc Total(prices []int, discount int) int {\n\treturn 0\n}\n"
+
+	tests := []struct {
+		name string
+		// prepare changes the module and its committed specs after a clean
+		// extraction, or removes the specs directory
+		prepare func(t *testing.T, root, specs string)
+		fix     bool
+		// want lists the stale, missing, orphaned and invalid entries
+		want          [4][]string
+		wantFixed     bool
+		wantUnfixed   bool
+		wantDriftLeft bool
+	}{
+		{name: "up to date", prepare: func(t *testing.T, root, specs string) {}},
+		{
+			name: "missing specs directory",
+			prepare: func(t *testing.T, root, specs string) {
+				os.RemoveAll(specs)
+			},
+			want:          [4][]string{nil, {"shop.go"}, nil, nil},
+			wantUnfixed:   true,
+			wantDriftLeft: true,
+		},
+		{
+			name: "missing specs directory fixed",
+			prepare: func(t *testing.T, root, specs string) {
+				os.RemoveAll(specs)
+			},
+			fix:       true,
+			want:      [4][]string{nil, {"shop.go"}, nil, nil},
+			wantFixed: true,
+		},
+		{
+			name: "stale spec fixed",
+			prepare: func(t *testing.T, root, specs string) {
+				writeFile(t, filepath.Join(root, "shop.go"), changed)
+			},
+			fix:       true,
+			want:      [4][]string{{"shop.go"}, nil, nil, nil},
+			wantFixed: true,
+		},
+		{
+			name: "orphaned and invalid specs outlive a fix",
+			prepare: func(t *testing.T, root, specs string) {
+				writeFile(t, filepath.Join(root, "shop.go"), changed)
+				writeFile(t, filepath.Join(specs, "gone.json"), `{"spec_version": "1.0", "language": "go", "module": "example.com/generic", "file": "gone.go"}`)
+				writeFile(t, filepath.Join(specs, "broken.json"), `{"file": `)
+			},
+			fix:           true,
+			want:          [4][]string{{"shop.go"}, nil, {"gone.json"}, {"broken.json"}},
+			wantFixed:     true,
+			wantUnfixed:   true,
+			wantDriftLeft: true,
+		},
+		{
+			name: "orphaned spec alone",
+			prepare: func(t *testing.T, root, specs string) {
+				writeFile(t, filepath.Join(specs, "gone.json"), `{"spec_version": "1.0", "language": "go", "module": "example.com/generic", "file": "gone.go"}`)
+			},
+			fix:           true,
+			want:          [4][]string{nil, nil, {"gone.json"}, nil},
+			wantUnfixed:   true,
+			wantDriftLeft: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeGoModule(t, map[string]string{"shop.go": source})
+			specs := filepath.Join(t.TempDir(), "specs")
+			if _, err := DetectDrift(root, specs, true); err != nil {
+				t.Fatal(err)
+			}
+			tt.prepare(t, root, specs)
+
+			report, err := DetectDrift(root, specs, tt.fix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stale []string
+			for _, drift := range report.Stale {
+				stale = append(stale, drift.File)
+			}
+			got := [4][]string{stale, report.Missing, baseNames(report.Orphaned), baseNames(report.Invalid)}
+			for i, kind := range []string{"stale", "missing", "orphaned", "invalid"} {
+				if !slices.Equal(got[i], tt.want[i]) {
+					t.Errorf("%s = %v, want %v", kind, got[i], tt.want[i])
+				}
+			}
+			if report.Fixed != tt.wantFixed {
+				t.Errorf("fixed = %v, want %v", report.Fixed, tt.wantFixed)
+			}
+			if report.HasUnfixedDrift() != tt.wantUnfixed {
+				t.Errorf("unfixed drift = %v, want %v", report.HasUnfixedDrift(), tt.wantUnfixed)
+			}
+
+			// A second run shows what the first one left behind
+			again, err := DetectDrift(root, specs, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.HasDrift() != tt.wantDriftLeft {
+				t.Errorf("drift after the run:\n%s", again)
+			}
+		})
+	}
+}
+
+// writeFile writes a file, creating its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// baseNames returns the file names of paths
+func baseNames(paths []string) []string {
+	var names []string
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	return names
+}
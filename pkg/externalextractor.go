@@ -0,0 +1,120 @@
+package deepspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// ExternalExtractor delegates extraction to an executable. For every file it
+// runs the command, writes an ExternalRequest as JSON to its stdin and reads
+// the spec as JSON from its stdout.
+type ExternalExtractor struct {
+	language string
+	command  []string
+	patterns []string
+	root     string
+}
+
+// ExternalRequest is the JSON an external extractor receives on stdin
+type ExternalRequest struct {
+	Root     string `json:"root"`
+	File     string `json:"file"`
+	Path     string `json:"path"`
+	Language string `json:"language"`
+}
+
+// NewExternalExtractor creates an extractor running command, split at spaces,
+// for the files below root whose base name matches one of the glob patterns
+func NewExternalExtractor(language, command string, patterns []string, root string) (*ExternalExtractor, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command for %s extractor", language)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no file patterns for %s extractor", language)
+	}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalExtractor{language: language, command: args, patterns: patterns, root: root}, nil
+}
+
+// RegisterExternalExtractor registers an executable as the extractor of a
+// language
+func RegisterExternalExtractor(language, command string, patterns []string) {
+	RegisterExtractor(language, func(root string) (Extractor, error) {
+		return NewExternalExtractor(language, command, patterns, root)
+	})
+}
+
+// Language returns the language the extractor was registered for
+func (e *ExternalExtractor) Language() string {
+	return e.language
+}
+
+// Match reports whether the base name of path matches one of the patterns
+func (e *ExternalExtractor) Match(path string) bool {
+	base := filepath.Base(path)
+	for _, pattern := range e.patterns {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract runs the command for a single file and decodes the spec it prints
+func (e *ExternalExtractor) Extract(path string) (*codespec.Spec, error) {
+	rel, err := relativeSource(e.root, path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	request, err := json.Marshal(ExternalRequest{Root: e.root, File: rel, Path: abs, Language: e.language})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(e.command[0], e.command[1:]...)
+	cmd.Dir = e.root
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", e.command[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", e.command[0], err)
+	}
+
+	spec, err := codespec.Parse(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid spec output: %w", e.command[0], err)
+	}
+	if spec.SpecVersion == "" {
+		spec.SpecVersion = codespec.Version
+	}
+	if spec.Language == "" {
+		spec.Language = e.language
+	}
+	if spec.File == "" {
+		spec.File = rel
+	}
+	return spec, nil
+}
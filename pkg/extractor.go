@@ -0,0 +1,148 @@
+package deepspec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// Extractor builds specs from the source files of one language
+type Extractor interface {
+	// Language returns the spec language, e.g. codespec.LanguageGo
+	Language() string
+	// Match reports whether the file at path is a source this extractor handles
+	Match(path string) bool
+	// Extract builds the spec of a single source file
+	Extract(path string) (*codespec.Spec, error)
+}
+
+// ExtractorFactory creates an extractor for the sources below root
+type ExtractorFactory func(root string) (Extractor, error)
+
+// extractorFactories is the registry of extractors by language
+var extractorFactories = map[string]ExtractorFactory{
+	codespec.LanguageGo: func(root string) (Extractor, error) {
+		return NewGoExtractor(root)
+	},
+	codespec.LanguagePython: func(root string) (Extractor, error) {
+		return NewPythonExtractor(root)
+	},
+	codespec.LanguageTypeScript: func(root string) (Extractor, error) {
+		return NewScriptExtractor(root, codespec.LanguageTypeScript)
+	},
+	codespec.LanguageJavaScript: func(root string) (Extractor, error) {
+		return NewScriptExtractor(root, codespec.LanguageJavaScript)
+	},
+	codespec.LanguageRust: func(root string) (Extractor, error) {
+		return NewRustExtractor(root)
+	},
+	codespec.LanguageJava: func(root string) (Extractor, error) {
+		return NewJavaExtractor(root)
+	},
+}
+
+// RegisterExtractor makes an extractor available under a language name,
+// replacing any extractor registered for it before
+func RegisterExtractor(language string, factory ExtractorFactory) {
+	extractorFactories[language] = factory
+}
+
+// NewExtractor creates the registered extractor for a language
+func NewExtractor(language, root string) (Extractor, error) {
+	factory, ok := extractorFactories[language]
+	if !ok {
+		return nil, fmt.Errorf("no extractor for language %q, expected one of %s", language, strings.Join(ExtractorLanguages(), ", "))
+	}
+	return factory(root)
+}
+
+// ExtractorLanguages returns the registered languages, sorted
+func ExtractorLanguages() []string {
+	var languages []string
+	for language := range extractorFactories {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// ExtractSpecs walks root and extracts every file matched by one of the
+// extractors, returning one spec per file sorted by file path. Files are
+// handed to the first extractor that matches them.
+func ExtractSpecs(root string, extractors ...Extractor) ([]*codespec.Spec, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []*codespec.Spec
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skipSourceDir(path, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, extractor := range extractors {
+			if !extractor.Match(path) {
+				continue
+			}
+			spec, err := extractor.Extract(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			specs = append(specs, spec)
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(specs, func(i, j int) bool { return specs[i].File < specs[j].File })
+	return specs, nil
+}
+
+// skipSourceDir reports whether a directory holds no sources of the project
+// itself: hidden directories, dependencies, caches, virtual environments and
+// build output
+func skipSourceDir(path, name string) bool {
+	switch name {
+	case "node_modules", "bower_components", "vendor", "__pycache__", "site-packages":
+		return true
+	case "target":
+		// Cargo and Maven build output
+		return fileExists(filepath.Join(filepath.Dir(path), "Cargo.toml")) || fileExists(filepath.Join(filepath.Dir(path), "pom.xml"))
+	case "build":
+		return fileExists(filepath.Join(filepath.Dir(path), "build.gradle")) || fileExists(filepath.Join(filepath.Dir(path), "build.gradle.kts"))
+	}
+	return strings.HasPrefix(name, ".") || fileExists(filepath.Join(path, "pyvenv.cfg"))
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// relativeSource returns path as a slash path relative to root, the form
+// specs name their files in
+func relativeSource(root, path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,150 @@
+package deepspec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// writeSourceTree writes files into a temporary directory and returns it
+func writeSourceTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// extractFixture extracts a file of a source tree with the extractor of a
+// language, failing unless the spec validates against the code-spec schema
+func extractFixture(t *testing.T, language string, files map[string]string, file string) *codespec.Spec {
+	t.Helper()
+	root := writeSourceTree(t, files)
+	extractor, err := NewExtractor(language, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extractor.Match(file) {
+		t.Fatalf("%s extractor does not match %s", language, file)
+	}
+	spec, err := extractor.Extract(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateFixture(t, spec)
+	return spec
+}
+
+// extractWithin extracts a single source file, failing unless the extractor
+// of a language finishes within a few seconds
+func extractWithin(t *testing.T, language, file, src string) *codespec.Spec {
+	t.Helper()
+	root := writeSourceTree(t, map[string]string{file: src})
+	extractor, err := NewExtractor(language, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		spec *codespec.Spec
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		spec, err := extractor.Extract(filepath.Join(root, file))
+		done <- result{spec, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		return r.spec
+	case <-time.After(5 * time.Second):
+		t.Fatalf("extracting %q did not finish", src)
+		return nil
+	}
+}
+
+// validateFixture fails unless a spec validates against the code-spec schema
+func validateFixture(t *testing.T, spec *codespec.Spec) {
+	t.Helper()
+	data, err := spec.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations := ValidateSpec(data); len(violations) > 0 {
+		t.Fatalf("spec of %s violates the schema: %v\n%s", spec.File, violations, data)
+	}
+}
+
+// specFact is an expected part of an extracted spec: got picks it from the
+// spec and want is its JSON encoding
+type specFact struct {
+	name string
+	got  func(spec *codespec.Spec) any
+	want string
+}
+
+// checkSpecFacts checks each fact about a spec in a subtest
+func checkSpecFacts(t *testing.T, spec *codespec.Spec, facts []specFact) {
+	t.Helper()
+	for _, fact := range facts {
+		t.Run(fact.name, func(t *testing.T) {
+			data, err := json.Marshal(fact.got(spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want any
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(fact.want), &want); err != nil {
+				t.Fatalf("bad want: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %s, want %s", data, fact.want)
+			}
+		})
+	}
+}
+
+// findType returns the type of a spec by name
+func findType(spec *codespec.Spec, name string) codespec.TypeDefinition {
+	for _, typ := range spec.Types {
+		if typ.Name == name {
+			return typ
+		}
+	}
+	return codespec.TypeDefinition{}
+}
+
+// findFunction returns the function of a spec by name
+func findFunction(spec *codespec.Spec, name string) codespec.Function {
+	for _, fn := range spec.Functions {
+		if fn.Name == name {
+			return fn
+		}
+	}
+	return codespec.Function{}
+}
+
+// functionNames returns the names of the functions of a spec
+func functionNames(spec *codespec.Spec) any {
+	names := []string{}
+	for _, fn := range spec.Functions {
+		names = append(names, fn.Name)
+	}
+	return names
+}
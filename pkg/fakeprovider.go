@@ -0,0 +1,238 @@
+package deepspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderFake replays a script instead of calling a model
+const ProviderFake = "fake"
+
+// fakeDemoScript is played when no script is configured
+const fakeDemoScript = `
+repeat: true
+turns:
+  - tool_calls:
+      - name: echo
+        args:
+          text: Hello from the fake provider
+  - text: >-
+      The echo tool answered "Hello from the fake provider". This reply is
+      scripted: set fake.script to a YAML or JSON script of model turns to
+      play your own conversation.
+`
+
+// FakeScript is a scripted conversation: the model turns a fake provider
+// plays, one per message it receives
+type FakeScript struct {
+	Turns []FakeTurn `yaml:"turns"`
+	// Repeat starts over after the last turn instead of failing
+	Repeat bool `yaml:"repeat"`
+}
+
+// FakeTurn is a model turn of a script
+type FakeTurn struct {
+	// Expect must be contained in the message the turn answers: the user
+	// text, or the JSON of the tool results. Empty matches anything.
+	Expect    string         `yaml:"expect"`
+	Text      string         `yaml:"text"`
+	ToolCalls []FakeToolCall `yaml:"tool_calls"`
+	// Error fails the message with this error instead
+	Error string `yaml:"error"`
+}
+
+// FakeToolCall is a scripted tool call
+type FakeToolCall struct {
+	// ID defaults to fake_<turn>_<call>
+	ID   string         `yaml:"id"`
+	Name string         `yaml:"name"`
+	Args map[string]any `yaml:"args"`
+}
+
+// FakeProvider plays a script, so chats run deterministically and offline.
+// Every session starts at the first turn.
+type FakeProvider struct {
+	script   *FakeScript
+	mutex    sync.Mutex
+	sessions []*FakeSession
+}
+
+// FakeSession is a conversation with a fake provider. It records what it
+// was sent, for tests to inspect.
+type FakeSession struct {
+	script   *FakeScript
+	system   string
+	tools    []ToolDefinition
+	mutex    sync.Mutex
+	next     int
+	messages []Message
+}
+
+// LoadFakeScript reads a YAML or JSON script
+func LoadFakeScript(path string) (*FakeScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	script, err := ParseFakeScript(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return script, nil
+}
+
+// ParseFakeScript parses a YAML or JSON script
+func ParseFakeScript(data []byte) (*FakeScript, error) {
+	var script FakeScript
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&script); err != nil {
+		return nil, err
+	}
+	if len(script.Turns) == 0 {
+		return nil, errors.New("script has no turns")
+	}
+	return &script, nil
+}
+
+// NewFakeProvider creates a provider playing a script
+func NewFakeProvider(script *FakeScript) *FakeProvider {
+	return &FakeProvider{script: script}
+}
+
+// newConfiguredFakeProvider plays the configured script, or a short demo
+func newConfiguredFakeProvider(config *Config) (*FakeProvider, error) {
+	if config.Fake.Script == "" {
+		script, err := ParseFakeScript([]byte(fakeDemoScript))
+		if err != nil {
+			return nil, err
+		}
+		return NewFakeProvider(script), nil
+	}
+	script, err := LoadFakeScript(config.Fake.Script)
+	if err != nil {
+		return nil, err
+	}
+	return NewFakeProvider(script), nil
+}
+
+// Name returns ProviderFake
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// StartSession starts playing the script from its first turn
+func (p *FakeProvider) StartSession(ctx context.Context, system string, tools []ToolDefinition) (Session, error) {
+	session := &FakeSession{script: p.script, system: system, tools: tools}
+	p.mutex.Lock()
+	p.sessions = append(p.sessions, session)
+	p.mutex.Unlock()
+	return session, nil
+}
+
+// Sessions returns the sessions started so far
+func (p *FakeProvider) Sessions() []*FakeSession {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]*FakeSession(nil), p.sessions...)
+}
+
+// System returns the system instructions the session started with
+func (s *FakeSession) System() string {
+	return s.system
+}
+
+// Tools returns the tools declared when the session started
+func (s *FakeSession) Tools() []ToolDefinition {
+	return s.tools
+}
+
+// Messages returns the messages the session was sent
+func (s *FakeSession) Messages() []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Send answers a message with the next turn of the script
+func (s *FakeSession) Send(ctx context.Context, message Message) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages = append(s.messages, message)
+
+	if s.next == len(s.script.Turns) {
+		if !s.script.Repeat {
+			return nil, fmt.Errorf("fake provider: script ended after %d turns", len(s.script.Turns))
+		}
+		s.next = 0
+	}
+	index := s.next
+	turn := s.script.Turns[index]
+	s.next++
+
+	if turn.Expect != "" && !strings.Contains(fakeMessageText(message), turn.Expect) {
+		return nil, fmt.Errorf("fake provider: turn %d expects a message containing %q", index+1, turn.Expect)
+	}
+	if turn.Error != "" {
+		return nil, fmt.Errorf("fake provider: %s", turn.Error)
+	}
+
+	response := &Response{Text: turn.Text}
+	for i, call := range turn.ToolCalls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("fake_%d_%d", index+1, i+1)
+		}
+		args := call.Args
+		if args == nil {
+			args = map[string]any{}
+		}
+		response.ToolCalls = append(response.ToolCalls, ToolCall{ID: id, Name: call.Name, Args: args})
+	}
+	return response, nil
+}
+
+// Stream answers a message with the next turn, streaming its text word by
+// word
+func (s *FakeSession) Stream(ctx context.Context, message Message, onText func(text string)) (*Response, error) {
+	response, err := s.Send(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	text := response.Text
+	for text != "" {
+		end := strings.IndexByte(strings.TrimLeft(text, " \n"), ' ')
+		if end < 0 {
+			onText(text)
+			break
+		}
+		end += len(text) - len(strings.TrimLeft(text, " \n"))
+		onText(text[:end])
+		text = text[end:]
+	}
+	return response, nil
+}
+
+// fakeMessageText is the text expectations are matched against
+func fakeMessageText(message Message) string {
+	if len(message.ToolResults) == 0 {
+		return message.Text
+	}
+	data, err := json.Marshal(message.ToolResults)
+	if err != nil {
+		return message.Text
+	}
+	return message.Text + string(data)
+}
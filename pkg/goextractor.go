@@ -0,0 +1,984 @@
+package deepspec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// ExtractGoSpecs walks a Go module (or any directory inside one) and returns
+// one spec per non-test source file, sorted by file path
+func ExtractGoSpecs(root string) ([]*codespec.Spec, error) {
+	extractor, err := NewGoExtractor(root)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractSpecs(root, extractor)
+}
+
+// GoExtractor extracts Go files. Packages are type-checked as a whole, so
+// the specs of all files of a package are built together and cached.
+type GoExtractor struct {
+	root      string
+	fset      *token.FileSet
+	imp       types.Importer
+	goVersion string
+	// goFiles holds the buildable files of each directory
+	goFiles map[string]map[string]bool
+	// packages holds the specs of each extracted package directory
+	packages map[string][]*codespec.Spec
+}
+
+// NewGoExtractor creates an extractor for the Go module at or above root
+func NewGoExtractor(root string) (*GoExtractor, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &GoExtractor{
+		root:      root,
+		fset:      token.NewFileSet(),
+		goVersion: goModVersion(root),
+		goFiles:   make(map[string]map[string]bool),
+		packages:  make(map[string][]*codespec.Spec),
+	}, nil
+}
+
+// Language returns codespec.LanguageGo
+func (e *GoExtractor) Language() string {
+	return codespec.LanguageGo
+}
+
+// Match reports whether path is a buildable non-test Go file outside
+// directories the go tool ignores
+func (e *GoExtractor) Match(path string) bool {
+	if !strings.HasSuffix(path, ".go") {
+		return false
+	}
+	rel, err := relativeSource(e.root, path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range strings.Split(rel, "/")[:strings.Count(rel, "/")] {
+		if strings.HasPrefix(dir, "_") || dir == "testdata" {
+			return false
+		}
+	}
+
+	dir := filepath.Join(e.root, filepath.FromSlash(filepath.Dir(rel)))
+	files, ok := e.goFiles[dir]
+	if !ok {
+		files = make(map[string]bool)
+		if bpkg, err := build.ImportDir(dir, 0); bpkg != nil {
+			for _, name := range bpkg.GoFiles {
+				files[name] = true
+			}
+		} else {
+			LogToFile("build.ImportDir %s: %v", dir, err)
+		}
+		e.goFiles[dir] = files
+	}
+	return files[filepath.Base(path)]
+}
+
+// Extract builds the spec of a Go file, extracting its whole package on first use
+func (e *GoExtractor) Extract(path string) (*codespec.Spec, error) {
+	rel, err := relativeSource(e.root, path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(e.root, filepath.FromSlash(filepath.Dir(rel)))
+
+	specs, ok := e.packages[dir]
+	if !ok {
+		if e.imp == nil {
+			e.imp = importer.ForCompiler(e.fset, "gc", goExportLookup(e.root))
+		}
+		if specs, err = extractGoPackage(e.fset, e.imp, e.root, dir); err != nil {
+			return nil, err
+		}
+		for _, spec := range specs {
+			spec.LanguageVersion = e.goVersion
+		}
+		e.packages[dir] = specs
+	}
+
+	for _, spec := range specs {
+		if spec.File == rel {
+			return spec, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not part of the package in %s", rel, dir)
+}
+
+// WriteSpecs writes each spec as indented JSON below outDir, mirroring the
+// source layout (pkg/config.go becomes <outDir>/pkg/config.json)
+func WriteSpecs(specs []*codespec.Spec, outDir string) error {
+	for _, spec := range specs {
+		path := filepath.Join(outDir, SpecFileName(spec.File))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := spec.WriteFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SpecFileName returns the relative spec file name for a source file
+func SpecFileName(sourceFile string) string {
+	return strings.TrimSuffix(filepath.FromSlash(sourceFile), filepath.Ext(sourceFile)) + ".json"
+}
+
+// goPackageDirs returns every directory below root containing Go files,
+// skipping hidden directories, vendor and testdata
+func goPackageDirs(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata" || name == "node_modules") {
+			return filepath.SkipDir
+		}
+		matches, _ := filepath.Glob(filepath.Join(path, "*.go"))
+		if len(matches) > 0 {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// goExportLookup resolves compiled export data for the dependencies of every
+// package below root, so type checking does not need to re-parse them
+func goExportLookup(root string) importer.Lookup {
+	exports := make(map[string]string)
+
+	cmd := exec.Command("go", "list", "-e", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}", "./...")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		LogToFile("go list failed in %s: %v", root, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		path, export, ok := strings.Cut(scanner.Text(), "\t")
+		if ok && export != "" {
+			exports[path] = export
+		}
+	}
+
+	return func(path string) (io.ReadCloser, error) {
+		export, ok := exports[path]
+		if !ok {
+			export, ok = exports["vendor/"+path]
+		}
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(export)
+	}
+}
+
+// goModVersion returns the go directive of the nearest go.mod above dir
+func goModVersion(dir string) string {
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if version, ok := strings.CutPrefix(strings.TrimSpace(line), "go "); ok {
+					return strings.TrimSpace(version)
+				}
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// goPackage is a parsed and type-checked package directory
+type goPackage struct {
+	dir     string
+	files   []*ast.File
+	sources map[*ast.File][]byte
+	info    *types.Info
+	types   *types.Package
+	errors  []error
+}
+
+// loadGoPackage parses and type-checks the buildable files of a directory,
+// returning nil when it holds no Go package
+func loadGoPackage(fset *token.FileSet, imp types.Importer, dir string) (*goPackage, error) {
+	bpkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		if _, ok := err.(*build.NoGoError); ok {
+			return nil, nil
+		}
+		// Keep going with whatever files were matched
+		LogToFile("build.ImportDir %s: %v", dir, err)
+	}
+
+	pkg := &goPackage{
+		dir:     dir,
+		sources: make(map[*ast.File][]byte),
+		info: &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Defs:  make(map[*ast.Ident]types.Object),
+			Uses:  make(map[*ast.Ident]types.Object),
+		},
+	}
+	for _, name := range bpkg.GoFiles {
+		path := filepath.Join(dir, name)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		pkg.files = append(pkg.files, file)
+		pkg.sources[file] = src
+	}
+	if len(pkg.files) == 0 {
+		return nil, nil
+	}
+
+	conf := types.Config{
+		Importer: imp,
+		// Extraction is best effort, so keep whatever type information is available
+		Error: func(err error) { pkg.errors = append(pkg.errors, err) },
+	}
+	pkg.types, _ = conf.Check(pkg.files[0].Name.Name, fset, pkg.files, pkg.info)
+	return pkg, nil
+}
+
+// extractGoPackage builds the specs of every file of a package directory,
+// naming files relative to root
+func extractGoPackage(fset *token.FileSet, imp types.Importer, root, dir string) ([]*codespec.Spec, error) {
+	pkg, err := loadGoPackage(fset, imp, dir)
+	if err != nil || pkg == nil {
+		return nil, err
+	}
+	return pkg.specs(fset, root)
+}
+
+// specs builds one spec per file of the package
+func (p *goPackage) specs(fset *token.FileSet, root string) ([]*codespec.Spec, error) {
+	var specs []*codespec.Spec
+	for _, file := range p.files {
+		x := &goFileExtractor{
+			fset: fset,
+			src:  p.sources[file],
+			file: file,
+			info: p.info,
+			pkg:  p.types,
+			root: root,
+		}
+		spec, err := x.extract()
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// goFileExtractor builds the spec for a single parsed Go file
+type goFileExtractor struct {
+	fset *token.FileSet
+	src  []byte
+	file *ast.File
+	info *types.Info
+	pkg  *types.Package
+	root string
+}
+
+// extract builds the spec for the file
+func (x *goFileExtractor) extract() (*codespec.Spec, error) {
+	filename := x.fset.Position(x.file.Pos()).Filename
+	rel, err := filepath.Rel(x.root, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &codespec.Spec{
+		SpecVersion: codespec.Version,
+		Language:    codespec.LanguageGo,
+		Module:      x.file.Name.Name,
+		File:        filepath.ToSlash(rel),
+		Description: docText(x.file.Doc),
+	}
+
+	for _, imp := range x.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		specImport := codespec.Import{Path: path}
+		if imp.Name != nil {
+			specImport.Alias = imp.Name.Name
+		}
+		spec.Imports = append(spec.Imports, specImport)
+	}
+
+	complexity := 0
+	for _, decl := range x.file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			x.genDecl(spec, decl)
+		case *ast.FuncDecl:
+			spec.Functions = append(spec.Functions, x.funcDecl(decl))
+			complexity += cyclomaticComplexity(decl)
+		}
+	}
+
+	spec.ExternalReferences = x.externalReferences(filename)
+	spec.Metadata = lineMetrics(x.src)
+	spec.Metadata.CyclomaticComplexity = complexity
+	return spec, nil
+}
+
+// genDecl adds the types, variables and constants of a declaration
+func (x *goFileExtractor) genDecl(spec *codespec.Spec, decl *ast.GenDecl) {
+	for _, s := range decl.Specs {
+		switch s := s.(type) {
+		case *ast.TypeSpec:
+			doc := s.Doc
+			if doc == nil && len(decl.Specs) == 1 {
+				doc = decl.Doc
+			}
+			spec.Types = append(spec.Types, x.typeSpec(s, doc))
+
+		case *ast.ValueSpec:
+			doc := s.Doc
+			if doc == nil && len(decl.Specs) == 1 {
+				doc = decl.Doc
+			}
+			for i, name := range s.Names {
+				if name.Name == "_" {
+					continue
+				}
+				if decl.Tok == token.CONST {
+					spec.Constants = append(spec.Constants, x.constant(s, name, doc))
+					continue
+				}
+				variable := codespec.Variable{
+					Name:     name.Name,
+					Type:     x.objectType(name, s.Type),
+					Exported: codespec.Bool(name.IsExported()),
+					Doc:      docText(doc),
+				}
+				if len(s.Values) == len(s.Names) {
+					variable.Initialization = map[string]any{"code": x.source(s.Values[i])}
+				}
+				spec.Variables = append(spec.Variables, variable)
+			}
+		}
+	}
+}
+
+// typeSpec converts a type declaration
+func (x *goFileExtractor) typeSpec(s *ast.TypeSpec, doc *ast.CommentGroup) codespec.TypeDefinition {
+	specType := codespec.TypeDefinition{
+		Name:          s.Name.Name,
+		Visibility:    visibility(s.Name.Name),
+		Documentation: docText(doc),
+		Extra:         x.typeParameters(s.TypeParams),
+	}
+
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		specType.Kind = codespec.KindStruct
+		for _, field := range t.Fields.List {
+			specType.Fields = append(specType.Fields, x.fields(field)...)
+		}
+	case *ast.InterfaceType:
+		specType.Kind = codespec.KindInterface
+		for _, field := range t.Methods.List {
+			if len(field.Names) == 0 {
+				// Embedded interface or type constraint
+				specType.Fields = append(specType.Fields, x.fields(field)...)
+				continue
+			}
+			for _, name := range field.Names {
+				signature := strings.TrimPrefix(x.source(field.Type), "func")
+				specType.Methods = append(specType.Methods, name.Name+signature)
+			}
+		}
+	default:
+		// Named types over other types (type ToolFunc func(...)) are
+		// represented as aliases since the schema has no closer kind, with
+		// the underlying type recorded as a single unnamed field
+		specType.Kind = codespec.KindTypeAlias
+		specType.Fields = []codespec.Field{{
+			Type:       x.source(s.Type),
+			Visibility: specType.Visibility,
+		}}
+	}
+	return specType
+}
+
+// fields converts a struct field list entry into one field per name
+func (x *goFileExtractor) fields(field *ast.Field) []codespec.Field {
+	doc := field.Doc
+	if doc == nil {
+		doc = field.Comment
+	}
+	typ := x.source(field.Type)
+
+	var tags map[string]any
+	if field.Tag != nil {
+		tag, _ := strconv.Unquote(field.Tag.Value)
+		tags = parseStructTag(tag)
+	}
+
+	if len(field.Names) == 0 {
+		// The schema has no notion of embedding, so it is recorded as an
+		// extra "embedded" property
+		name := embeddedName(field.Type)
+		return []codespec.Field{{
+			Name:          name,
+			Type:          typ,
+			Visibility:    visibility(name),
+			Documentation: docText(doc),
+			Tags:          tags,
+			Extra:         map[string]json.RawMessage{"embedded": json.RawMessage("true")},
+		}}
+	}
+
+	var fields []codespec.Field
+	for _, name := range field.Names {
+		fields = append(fields, codespec.Field{
+			Name:          name.Name,
+			Type:          typ,
+			Visibility:    visibility(name.Name),
+			Documentation: docText(doc),
+			Tags:          tags,
+		})
+	}
+	return fields
+}
+
+// goTypeParameter is a type parameter of a generic function or type
+type goTypeParameter struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint"`
+}
+
+// typeParameters records the type parameters of a generic declaration. The
+// schema has no notion of them, so they are an extra "type_parameters"
+// property, which is nil for other declarations.
+func (x *goFileExtractor) typeParameters(list *ast.FieldList) map[string]json.RawMessage {
+	if list == nil || len(list.List) == 0 {
+		return nil
+	}
+	var params []goTypeParameter
+	for _, field := range list.List {
+		constraint := x.source(field.Type)
+		for _, name := range field.Names {
+			params = append(params, goTypeParameter{Name: name.Name, Constraint: constraint})
+		}
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+	return map[string]json.RawMessage{"type_parameters": data}
+}
+
+// constant converts a single constant name of a value spec
+func (x *goFileExtractor) constant(s *ast.ValueSpec, name *ast.Ident, doc *ast.CommentGroup) codespec.Constant {
+	c := codespec.Constant{
+		Name:     name.Name,
+		Type:     x.objectType(name, s.Type),
+		Doc:      docText(doc),
+		Exported: codespec.Bool(name.IsExported()),
+	}
+	if obj, ok := x.info.Defs[name].(*types.Const); ok {
+		c.Value = codespec.RawValue(constantValue(obj.Val()))
+	}
+	return c
+}
+
+// funcDecl converts a function or method declaration
+func (x *goFileExtractor) funcDecl(decl *ast.FuncDecl) codespec.Function {
+	fn := codespec.Function{
+		Name:          decl.Name.Name,
+		Visibility:    visibility(decl.Name.Name),
+		Documentation: docText(decl.Doc),
+		Extra:         x.typeParameters(decl.Type.TypeParams),
+	}
+
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		recv := decl.Recv.List[0]
+		fn.Receiver = &codespec.Receiver{Type: x.source(recv.Type)}
+		if len(recv.Names) > 0 {
+			fn.Receiver.Name = recv.Names[0].Name
+		}
+	}
+
+	for _, field := range decl.Type.Params.List {
+		typ := x.source(field.Type)
+		if len(field.Names) == 0 {
+			fn.Parameters = append(fn.Parameters, codespec.Parameter{Type: typ})
+		}
+		for _, name := range field.Names {
+			fn.Parameters = append(fn.Parameters, codespec.Parameter{Name: name.Name, Type: typ})
+		}
+	}
+
+	if decl.Type.Results != nil {
+		for _, field := range decl.Type.Results.List {
+			typ := x.source(field.Type)
+			if len(field.Names) == 0 {
+				fn.Returns = append(fn.Returns, codespec.ReturnValue{Type: typ})
+			}
+			for _, name := range field.Names {
+				fn.Returns = append(fn.Returns, codespec.ReturnValue{Name: name.Name, Type: typ})
+			}
+		}
+	}
+
+	if decl.Body != nil {
+		fn.BodyStatements = x.statements(decl.Body.List)
+	}
+	return fn
+}
+
+// statements converts a statement list
+func (x *goFileExtractor) statements(list []ast.Stmt) []codespec.Statement {
+	var statements []codespec.Statement
+	for _, stmt := range list {
+		if _, ok := stmt.(*ast.EmptyStmt); ok {
+			continue
+		}
+		statements = append(statements, x.statement(stmt))
+	}
+	return statements
+}
+
+// statement converts a single statement, keeping nested control flow
+// structured and everything else as source code
+func (x *goFileExtractor) statement(stmt ast.Stmt) codespec.Statement {
+	code := x.source(stmt)
+
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		st := codespec.Statement{Type: codespec.StatementAssignment, Code: code}
+		if s.Tok == token.DEFINE {
+			st.Type = codespec.StatementVariableDeclaration
+		}
+		if len(s.Rhs) == 1 {
+			switch rhs := ast.Unparen(s.Rhs[0]).(type) {
+			case *ast.CallExpr:
+				st.Operation = x.source(rhs.Fun)
+				if ident, ok := rhs.Fun.(*ast.Ident); ok && ident.Name == "append" && x.isBuiltin(ident) {
+					st.Type = codespec.StatementAppend
+				}
+			case *ast.TypeAssertExpr:
+				st.Type = codespec.StatementTypeAssertion
+			}
+		}
+		return st
+
+	case *ast.IncDecStmt:
+		return codespec.Statement{Type: codespec.StatementAssignment, Operation: s.Tok.String(), Code: code}
+
+	case *ast.DeclStmt:
+		if decl, ok := s.Decl.(*ast.GenDecl); ok && decl.Tok == token.VAR {
+			return codespec.Statement{Type: codespec.StatementVariableDeclaration, Code: code}
+		}
+		return codespec.Statement{Type: codespec.StatementDeclaration, Code: code}
+
+	case *ast.ExprStmt:
+		if call, ok := ast.Unparen(s.X).(*ast.CallExpr); ok {
+			return x.call(call, code)
+		}
+
+	case *ast.DeferStmt:
+		st := x.call(s.Call, code)
+		st.Description = "deferred"
+		return st
+
+	case *ast.GoStmt:
+		st := x.call(s.Call, code)
+		st.Description = "goroutine"
+		return st
+
+	case *ast.ReturnStmt:
+		var values []string
+		for _, result := range s.Results {
+			values = append(values, x.source(result))
+		}
+		return codespec.Statement{Type: codespec.StatementReturn, Code: code, Value: strings.Join(values, ", ")}
+
+	case *ast.IfStmt:
+		st := codespec.Statement{
+			Type:       codespec.StatementIf,
+			Condition:  x.header(s.Init, s.Cond),
+			TrueBranch: x.statements(s.Body.List),
+		}
+		switch els := s.Else.(type) {
+		case *ast.BlockStmt:
+			st.FalseBranch = x.statements(els.List)
+		case *ast.IfStmt:
+			st.FalseBranch = []codespec.Statement{x.statement(els)}
+		}
+		return st
+
+	case *ast.ForStmt:
+		// Loop bodies live in true_branch as the schema has no dedicated field
+		return codespec.Statement{
+			Type:       codespec.StatementFor,
+			Condition:  strings.TrimSpace(string(x.src[x.offset(s.For)+len("for") : x.offset(s.Body.Lbrace)])),
+			TrueBranch: x.statements(s.Body.List),
+		}
+
+	case *ast.RangeStmt:
+		return codespec.Statement{
+			Type:       codespec.StatementFor,
+			Operation:  "range",
+			Condition:  strings.TrimSpace(string(x.src[x.offset(s.For)+len("for") : x.offset(s.Body.Lbrace)])),
+			TrueBranch: x.statements(s.Body.List),
+		}
+
+	case *ast.SwitchStmt:
+		return codespec.Statement{
+			Type:      codespec.StatementSwitch,
+			Condition: x.header(s.Init, s.Tag),
+			Cases:     x.cases(s.Body),
+		}
+
+	case *ast.TypeSwitchStmt:
+		return codespec.Statement{
+			Type:      codespec.StatementSwitch,
+			Operation: "type_switch",
+			Condition: x.header(s.Init, s.Assign),
+			Cases:     x.cases(s.Body),
+		}
+	}
+
+	// Statements without a schema equivalent (select, labels, branches, ...)
+	// are kept verbatim
+	return codespec.Statement{Type: codespec.StatementFunctionBody, Code: code}
+}
+
+// call converts a call statement into a function or method call
+func (x *goFileExtractor) call(call *ast.CallExpr, code string) codespec.Statement {
+	st := codespec.Statement{Type: codespec.StatementFunctionCall, Operation: x.source(call.Fun), Code: code}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		// pkg.Func is a function call, value.Method a method call
+		if ident, ok := sel.X.(*ast.Ident); !ok || !x.isPackageName(ident) {
+			st.Type = codespec.StatementMethodCall
+		}
+	}
+	return st
+}
+
+// cases converts the clauses of a switch or type switch
+func (x *goFileExtractor) cases(body *ast.BlockStmt) []codespec.Case {
+	var cases []codespec.Case
+	for _, stmt := range body.List {
+		clause, ok := stmt.(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		label := "default"
+		if clause.List != nil {
+			var exprs []string
+			for _, expr := range clause.List {
+				exprs = append(exprs, x.source(expr))
+			}
+			label = "case " + strings.Join(exprs, ", ")
+		}
+		cases = append(cases, codespec.Case{Type: label, Statements: x.statements(clause.Body)})
+	}
+	return cases
+}
+
+// header renders an optional init statement followed by an expression or statement
+func (x *goFileExtractor) header(init ast.Stmt, node ast.Node) string {
+	var parts []string
+	if init != nil {
+		parts = append(parts, x.source(init))
+	}
+	if node != nil {
+		parts = append(parts, x.source(node))
+	}
+	if init != nil && len(parts) == 1 {
+		return parts[0] + ";"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// isPackageName reports whether ident refers to an imported package
+func (x *goFileExtractor) isPackageName(ident *ast.Ident) bool {
+	if obj, ok := x.info.Uses[ident]; ok {
+		_, isPkg := obj.(*types.PkgName)
+		return isPkg
+	}
+	// Fall back to the file imports when type information is missing
+	for _, imp := range x.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := filepath.Base(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == ident.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// isBuiltin reports whether ident refers to a universe scope builtin
+func (x *goFileExtractor) isBuiltin(ident *ast.Ident) bool {
+	obj, ok := x.info.Uses[ident]
+	if !ok {
+		return true
+	}
+	_, isBuiltin := obj.(*types.Builtin)
+	return isBuiltin
+}
+
+// objectType returns the declared type expression, or the inferred type
+// qualified the way this file refers to it
+func (x *goFileExtractor) objectType(name *ast.Ident, typeExpr ast.Expr) string {
+	if typeExpr != nil {
+		return x.source(typeExpr)
+	}
+	obj := x.info.Defs[name]
+	if obj == nil || obj.Type() == nil {
+		return "unknown"
+	}
+	return types.TypeString(types.Default(obj.Type()), x.qualifier)
+}
+
+// qualifier qualifies package-level types by the local import name
+func (x *goFileExtractor) qualifier(pkg *types.Package) string {
+	if pkg == x.pkg {
+		return ""
+	}
+	for _, imp := range x.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if path == pkg.Path() && imp.Name != nil {
+			return imp.Name.Name
+		}
+	}
+	return pkg.Name()
+}
+
+// externalReferences collects package-level identifiers this file uses that
+// are declared in other files below the extraction root, keyed by file
+func (x *goFileExtractor) externalReferences(filename string) map[string][]string {
+	seen := make(map[string]map[string]bool)
+	ast.Inspect(x.file, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := x.info.Uses[ident]
+		if obj == nil || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+			return true
+		}
+		declFile := x.fset.Position(obj.Pos()).Filename
+		if declFile == "" || declFile == filename {
+			return true
+		}
+		rel, err := filepath.Rel(x.root, declFile)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return true
+		}
+		rel = filepath.ToSlash(rel)
+		if seen[rel] == nil {
+			seen[rel] = make(map[string]bool)
+		}
+		seen[rel][obj.Name()] = true
+		return true
+	})
+
+	if len(seen) == 0 {
+		return nil
+	}
+	refs := make(map[string][]string, len(seen))
+	for file, names := range seen {
+		for name := range names {
+			refs[file] = append(refs[file], name)
+		}
+		sort.Strings(refs[file])
+	}
+	return refs
+}
+
+// source returns the original source text of a node
+func (x *goFileExtractor) source(node ast.Node) string {
+	return string(x.src[x.offset(node.Pos()):x.offset(node.End())])
+}
+
+// offset converts a position into a byte offset of the file source
+func (x *goFileExtractor) offset(pos token.Pos) int {
+	return x.fset.Position(pos).Offset
+}
+
+// constantValue converts a constant into a JSON friendly value
+func constantValue(val constant.Value) any {
+	switch val.Kind() {
+	case constant.String:
+		return constant.StringVal(val)
+	case constant.Bool:
+		return constant.BoolVal(val)
+	case constant.Int:
+		if i, ok := constant.Int64Val(val); ok {
+			return i
+		}
+		return json.Number(val.ExactString())
+	case constant.Float:
+		if f, ok := constant.Float64Val(val); ok {
+			return f
+		}
+	}
+	return val.ExactString()
+}
+
+// parseStructTag splits a struct tag into its key/value pairs
+func parseStructTag(tag string) map[string]any {
+	tags := make(map[string]any)
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		key, rest, ok := strings.Cut(tag, ":\"")
+		if !ok || key == "" || strings.ContainsAny(key, " \"") {
+			break
+		}
+		// Find the closing quote, honouring escapes
+		end := 0
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			break
+		}
+		value, err := strconv.Unquote("\"" + rest[:end] + "\"")
+		if err != nil {
+			break
+		}
+		tags[key] = value
+		tag = rest[end+1:]
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// embeddedName returns the field name of an embedded type
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	}
+	return ""
+}
+
+// visibility maps Go identifier case onto the schema visibility values
+func visibility(name string) string {
+	if ast.IsExported(name) {
+		return codespec.VisibilityExported
+	}
+	return codespec.VisibilityUnexported
+}
+
+// docText returns a trimmed doc comment
+func docText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	return strings.TrimSpace(doc.Text())
+}
+
+// cyclomaticComplexity counts decision points of a function plus one
+func cyclomaticComplexity(decl *ast.FuncDecl) int {
+	complexity := 1
+	ast.Inspect(decl, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			complexity++
+		case *ast.CaseClause:
+			if n.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
+
+// lineMetrics counts code, blank and comment lines of a source file
+func lineMetrics(src []byte) *codespec.Metadata {
+	metrics := &codespec.Metadata{}
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimSuffix(string(src), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inBlock:
+			metrics.CommentLines++
+			if strings.Contains(trimmed, "*/") {
+				inBlock = false
+			}
+		case trimmed == "":
+			metrics.BlankLines++
+		case strings.HasPrefix(trimmed, "//"):
+			metrics.CommentLines++
+		case strings.HasPrefix(trimmed, "/*"):
+			metrics.CommentLines++
+			inBlock = !strings.Contains(trimmed[2:], "*/")
+		default:
+			metrics.LinesOfCode++
+		}
+	}
+	return metrics
+}
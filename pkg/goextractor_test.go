@@ -0,0 +1,108 @@
+package deepspec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const genericSource = `package generic
+
+import "fmt"
+
+// Box holds a value
+type Box[T comparable] struct {
+	Value T
+}
+
+// Get returns the value
+func (b *Box[T]) Get() T {
+	return b.Value
+}
+
+// Map applies f to every element
+func Map[T any, U fmt.Stringer](items []T, f func(T) U) []U {
+	var out []U
+	for _, item := range items {
+		out = append(out, f(item))
+	}
+	return out
+}
+`
+
+// writeGoModule writes a single-package module into a temporary directory
+func writeGoModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/generic\n\ngo 1.24\n"
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGoExtractorTypeParameters(t *testing.T) {
+	dir := writeGoModule(t, map[string]string{"generic.go": genericSource})
+
+	specs, err := ExtractGoSpecs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 {
+		t.Fatalf("got %d specs, want 1", len(specs))
+	}
+	spec := specs[0]
+
+	tests := []struct {
+		name  string
+		extra map[string]json.RawMessage
+		want  string
+	}{
+		{name: "Box", extra: findType(spec, "Box").Extra, want: `[{"name":"T","constraint":"comparable"}]`},
+		{name: "Map", extra: findFunction(spec, "Map").Extra, want: `[{"name":"T","constraint":"any"},{"name":"U","constraint":"fmt.Stringer"}]`},
+		{name: "Get", extra: findFunction(spec, "Get").Extra, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.extra["type_parameters"]); got != tt.want {
+				t.Errorf("type_parameters = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	src, err := GenerateGo(spec)
+	if err != nil {
+		t.Fatalf("GenerateGo: %v\n%s", err, src)
+	}
+	for _, want := range []string{"type Box[T comparable] struct", "func (b *Box[T]) Get() T", "func Map[T any, U fmt.Stringer](items []T, f func(T) U) []U"} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source lacks %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestRoundtripTypeChecksGenerics(t *testing.T) {
+	dir := writeGoModule(t, map[string]string{"generic.go": genericSource})
+
+	report, err := Roundtrip(dir, 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Packages) != 1 {
+		t.Fatalf("got %d packages, want 1", len(report.Packages))
+	}
+	if errs := report.Packages[0].CompileErrors; len(errs) > 0 {
+		t.Errorf("compile errors: %v", errs)
+	}
+	if !report.Passed {
+		t.Errorf("roundtrip failed with fidelity %v", report.Fidelity)
+	}
+}
@@ -0,0 +1,480 @@
+package deepspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// GenerateGo renders Go source for a spec. Function bodies are rebuilt from
+// body_statements, falling back to each statement's code; functions that only
+// carry a body_summary get a panic("TODO") stub, as do functions with results
+// whose body is missing or ends in a statement without code. When the result
+// cannot be formatted the unformatted source is returned together with the
+// error.
+func GenerateGo(spec *codespec.Spec) ([]byte, error) {
+	if spec.Language != codespec.LanguageGo {
+		return nil, fmt.Errorf("cannot generate Go source from a %s spec", spec.Language)
+	}
+
+	g := &goGenerator{}
+	g.file(spec)
+
+	src, err := pruneImports(g.buf.Bytes())
+	if err != nil {
+		return g.buf.Bytes(), err
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		return src, err
+	}
+	return formatted, nil
+}
+
+// goGenerator accumulates generated source
+type goGenerator struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+// line writes a single indented line
+func (g *goGenerator) line(format string, args ...any) {
+	g.buf.WriteString(strings.Repeat("\t", g.indent))
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// doc writes text as a line comment block
+func (g *goGenerator) doc(text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		g.line("%s", strings.TrimRight("// "+line, " "))
+	}
+}
+
+// file writes the whole source file
+func (g *goGenerator) file(spec *codespec.Spec) {
+	g.doc(spec.Description)
+	g.line("package %s", spec.Module)
+
+	if len(spec.Imports) > 0 {
+		g.line("")
+		g.line("import (")
+		g.indent++
+		for _, imp := range spec.Imports {
+			if imp.Alias != "" {
+				g.line("%s %s", imp.Alias, strconv.Quote(imp.Path))
+			} else {
+				g.line("%s", strconv.Quote(imp.Path))
+			}
+		}
+		g.indent--
+		g.line(")")
+	}
+
+	for _, c := range spec.Constants {
+		g.line("")
+		g.doc(c.Doc)
+		g.line("const %s", constantDecl(c))
+	}
+
+	for _, v := range spec.Variables {
+		g.line("")
+		g.doc(v.Doc)
+		g.line("var %s", variableDecl(v))
+	}
+
+	for _, t := range spec.Types {
+		g.line("")
+		g.typeDefinition(t)
+	}
+
+	for _, fn := range spec.Functions {
+		g.line("")
+		g.function(fn)
+	}
+}
+
+// typeDefinition writes a type declaration
+func (g *goGenerator) typeDefinition(t codespec.TypeDefinition) {
+	g.doc(t.Documentation)
+
+	name := t.Name + typeParameterList(t.Extra)
+	switch t.Kind {
+	case codespec.KindInterface:
+		g.line("type %s interface {", name)
+		g.indent++
+		for _, field := range t.Fields {
+			g.doc(field.Documentation)
+			g.line("%s", field.Type)
+		}
+		for _, method := range t.Methods {
+			g.line("%s", method)
+		}
+		g.indent--
+		g.line("}")
+
+	case codespec.KindTypeAlias:
+		underlying := "any"
+		if len(t.Fields) > 0 && t.Fields[0].Type != "" {
+			underlying = t.Fields[0].Type
+		}
+		g.line("type %s %s", name, underlying)
+
+	default:
+		// Structs, and the closest Go equivalent for classes, enums and unions
+		g.line("type %s struct {", name)
+		g.indent++
+		for _, field := range t.Fields {
+			g.doc(field.Documentation)
+			decl := field.Name + " " + field.Type
+			if field.Name == "" || string(field.Extra["embedded"]) == "true" {
+				decl = field.Type
+			}
+			if tag := structTag(field.Tags); tag != "" {
+				decl += " " + tag
+			}
+			g.line("%s", decl)
+		}
+		g.indent--
+		g.line("}")
+	}
+}
+
+// function writes a function or method declaration
+func (g *goGenerator) function(fn codespec.Function) {
+	g.doc(fn.Documentation)
+
+	var recv string
+	if fn.Receiver != nil {
+		recv = "(" + strings.TrimSpace(fn.Receiver.Name+" "+fn.Receiver.Type) + ") "
+	}
+
+	var params []string
+	named := false
+	for _, p := range fn.Parameters {
+		named = named || p.Name != ""
+	}
+	for _, p := range fn.Parameters {
+		params = append(params, signatureEntry(p.Name, p.Type, named))
+	}
+
+	var results []string
+	named = false
+	for _, r := range fn.Returns {
+		named = named || r.Name != ""
+	}
+	for _, r := range fn.Returns {
+		results = append(results, signatureEntry(r.Name, r.Type, named))
+	}
+
+	signature := fmt.Sprintf("func %s%s%s(%s)", recv, fn.Name, typeParameterList(fn.Extra), strings.Join(params, ", "))
+	switch {
+	case len(results) == 1 && !named:
+		signature += " " + results[0]
+	case len(results) > 0:
+		signature += " (" + strings.Join(results, ", ") + ")"
+	}
+
+	g.line("%s {", signature)
+	g.indent++
+	switch {
+	case len(fn.BodyStatements) > 0:
+		g.statements(fn.BodyStatements)
+		// A TODO comment cannot end a function with results
+		if len(results) > 0 && isPlaceholder(fn.BodyStatements[len(fn.BodyStatements)-1]) {
+			g.line("panic(\"TODO\")")
+		}
+	case fn.BodySummary != "":
+		g.doc(fn.BodySummary)
+		g.line("panic(\"TODO\")")
+	case len(results) > 0:
+		g.line("panic(\"TODO\")")
+	}
+	g.indent--
+	g.line("}")
+}
+
+// statements writes a list of statements
+func (g *goGenerator) statements(statements []codespec.Statement) {
+	for _, st := range statements {
+		g.statement(st)
+	}
+}
+
+// statement writes a single statement, rebuilding control flow from its
+// structure and emitting everything else from code
+func (g *goGenerator) statement(st codespec.Statement) {
+	switch st.Type {
+	case codespec.StatementIf:
+		if st.Condition != "" {
+			g.ifStatement(st, "if")
+			g.line("}")
+			return
+		}
+
+	case codespec.StatementFor:
+		if st.Condition != "" || len(st.TrueBranch) > 0 || st.Code == "" {
+			g.line("%s {", strings.TrimSpace("for "+st.Condition))
+			g.indent++
+			g.statements(st.TrueBranch)
+			g.indent--
+			g.line("}")
+			return
+		}
+
+	case codespec.StatementSwitch:
+		if st.Condition != "" || len(st.Cases) > 0 || st.Code == "" {
+			g.line("%s {", strings.TrimSpace("switch "+st.Condition))
+			for _, c := range st.Cases {
+				g.line("%s:", caseLabel(c.Type))
+				g.indent++
+				g.statements(c.Statements)
+				g.indent--
+			}
+			g.line("}")
+			return
+		}
+
+	case codespec.StatementReturn:
+		if st.Code == "" {
+			g.line("%s", strings.TrimSpace("return "+st.Value))
+			return
+		}
+	}
+
+	switch {
+	case st.Code != "":
+		g.code(st.Code)
+	case st.Description != "":
+		g.line("// TODO(%s): %s", st.Type, st.Description)
+	default:
+		g.line("// TODO(%s)", st.Type)
+	}
+}
+
+// isPlaceholder reports whether statement writes st as a TODO comment, for
+// lack of both code and structure
+func isPlaceholder(st codespec.Statement) bool {
+	switch st.Type {
+	case codespec.StatementIf:
+		return st.Condition == "" && st.Code == ""
+	case codespec.StatementFor, codespec.StatementSwitch, codespec.StatementReturn:
+		return false
+	}
+	return st.Code == ""
+}
+
+// ifStatement writes an if statement and its else-if chain, leaving the
+// final closing brace to the caller
+func (g *goGenerator) ifStatement(st codespec.Statement, keyword string) {
+	g.line("%s %s {", keyword, st.Condition)
+	g.indent++
+	g.statements(st.TrueBranch)
+	g.indent--
+
+	if len(st.FalseBranch) == 0 {
+		return
+	}
+	if len(st.FalseBranch) == 1 && st.FalseBranch[0].Type == codespec.StatementIf && st.FalseBranch[0].Condition != "" {
+		g.ifStatement(st.FalseBranch[0], "} else if")
+		return
+	}
+	g.line("} else {")
+	g.indent++
+	g.statements(st.FalseBranch)
+	g.indent--
+}
+
+// code writes verbatim source. Continuation lines are written untouched so
+// multi-line raw strings keep their content; gofmt fixes the indentation.
+func (g *goGenerator) code(code string) {
+	g.line("%s", code)
+}
+
+// constantDecl renders the body of a const declaration
+func constantDecl(c codespec.Constant) string {
+	var value string
+	switch v := codespec.DecodeValue(c.Value).(type) {
+	case nil:
+		value = zeroValue(c.Type)
+	case string:
+		value = strconv.Quote(v)
+		if c.Type == "string" {
+			return c.Name + " = " + value
+		}
+	case bool:
+		value = strconv.FormatBool(v)
+		if c.Type == "bool" {
+			return c.Name + " = " + value
+		}
+	default:
+		// Keep untyped constants untyped when the type is the literal's default
+		value = fmt.Sprint(v)
+		isFloat := strings.ContainsAny(value, ".eE")
+		if (c.Type == "int" && !isFloat) || (c.Type == "float64" && isFloat) {
+			return c.Name + " = " + value
+		}
+	}
+	return c.Name + " " + c.Type + " = " + value
+}
+
+// variableDecl renders the body of a var declaration
+func variableDecl(v codespec.Variable) string {
+	for _, key := range []string{"code", "value"} {
+		if code, ok := v.Initialization[key].(string); ok && code != "" {
+			return v.Name + " = " + code
+		}
+	}
+	return v.Name + " " + v.Type
+}
+
+// typeParameterList writes the "type_parameters" extra property of a generic
+// declaration as a type parameter list, or nothing for other declarations
+func typeParameterList(extra map[string]json.RawMessage) string {
+	var params []goTypeParameter
+	if json.Unmarshal(extra["type_parameters"], &params) != nil || len(params) == 0 {
+		return ""
+	}
+	var entries []string
+	for _, param := range params {
+		constraint := param.Constraint
+		if constraint == "" {
+			constraint = "any"
+		}
+		entries = append(entries, param.Name+" "+constraint)
+	}
+	return "[" + strings.Join(entries, ", ") + "]"
+}
+
+// signatureEntry renders a parameter or result, naming every entry with _
+// when the list mixes named and unnamed entries
+func signatureEntry(name, typ string, named bool) string {
+	if !named {
+		return typ
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name + " " + typ
+}
+
+// caseLabel renders a switch case label
+func caseLabel(label string) string {
+	label = strings.TrimSuffix(strings.TrimSpace(label), ":")
+	if label == "" || label == "default" || strings.HasPrefix(label, "case ") {
+		if label == "" {
+			return "default"
+		}
+		return label
+	}
+	return "case " + label
+}
+
+// structTag renders tags as a raw struct tag, sorted by key
+func structTag(tags map[string]any) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, key+":"+strconv.Quote(fmt.Sprint(tags[key])))
+	}
+	tag := strings.Join(parts, " ")
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
+
+// zeroValue returns a constant expression usable for a missing value
+func zeroValue(typ string) string {
+	switch typ {
+	case "string":
+		return `""`
+	case "bool":
+		return "false"
+	}
+	return "0"
+}
+
+// pruneImports removes imports the generated source never references, which
+// happens whenever bodies are reduced to stubs
+func pruneImports(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	var unused []*ast.ImportSpec
+	for _, imp := range file.Imports {
+		importPath, _ := strconv.Unquote(imp.Path.Value)
+		name := guessPackageName(importPath)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		if !used[name] && !used[path.Base(importPath)] {
+			unused = append(unused, imp)
+		}
+	}
+	if len(unused) == 0 {
+		return src, nil
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, imp := range unused {
+		start := fset.Position(imp.Pos()).Offset
+		end := fset.Position(imp.End()).Offset
+		out.Write(src[last:start])
+		last = end
+	}
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
+
+// guessPackageName derives the usual package name from an import path,
+// skipping major version suffixes and gopkg.in style versions
+func guessPackageName(importPath string) string {
+	name := path.Base(importPath)
+	if strings.HasPrefix(name, "v") && len(name) > 1 && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	if idx := strings.Index(name, ".v"); idx > 0 {
+		name = name[:idx]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "")
+}
@@ -0,0 +1,138 @@
+package deepspec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+func TestGenerateGo(t *testing.T) {
+	tests := []struct {
+		name string
+		// spec holds the declarations of a spec of package p
+		spec string
+		want string
+	}{
+		{
+			name: "empty bodies",
+			spec: `{"functions": [
+				{"name": "Count", "visibility": "public", "returns": [{"type": "int"}]},
+				{"name": "reset", "visibility": "private"}]}`,
+			want: `
+func Count() int {
+	panic("TODO")
+}
+
+func reset() {
+}
+`,
+		},
+		{
+			name: "body summary",
+			spec: `{"functions": [{"name": "Load", "visibility": "public", "parameters": [{"name": "path", "type": "string"}],
+				"returns": [{"type": "[]byte"}, {"type": "error"}], "body_summary": "Reads the file."}]}`,
+			want: `
+func Load(path string) ([]byte, error) {
+	// Reads the file.
+	panic("TODO")
+}
+`,
+		},
+		{
+			name: "switch cases",
+			spec: `{"functions": [{"name": "Kind", "visibility": "public", "parameters": [{"name": "n", "type": "int"}], "returns": [{"type": "string"}],
+				"body_statements": [
+					{"type": "switch_statement", "condition": "n", "cases": [
+						{"type": "0", "statements": [{"type": "return", "value": "\"zero\""}]},
+						{"type": "case 1, 2", "statements": [{"type": "return", "value": "\"few\""}]},
+						{"type": "", "statements": [{"type": "function_call", "code": "log(n)"}]}]},
+					{"type": "return", "value": "\"many\""}]}]}`,
+			want: `
+func Kind(n int) string {
+	switch n {
+	case 0:
+		return "zero"
+	case 1, 2:
+		return "few"
+	default:
+		log(n)
+	}
+	return "many"
+}
+`,
+		},
+		{
+			name: "statements without code",
+			spec: `{"functions": [{"name": "Sum", "visibility": "public", "parameters": [{"name": "xs", "type": "[]int"}], "returns": [{"name": "total", "type": "int"}],
+				"body_statements": [
+					{"type": "for_loop", "condition": "_, x := range xs", "true_branch": [{"type": "assignment", "description": "add x to total"}]},
+					{"type": "method_call"}]}]}`,
+			want: `
+func Sum(xs []int) (total int) {
+	for _, x := range xs {
+		// TODO(assignment): add x to total
+	}
+	// TODO(method_call)
+	panic("TODO")
+}
+`,
+		},
+		{
+			name: "if chain",
+			spec: `{"functions": [{"name": "Sign", "visibility": "public", "parameters": [{"name": "n", "type": "int"}], "returns": [{"type": "int"}],
+				"body_statements": [
+					{"type": "if_statement", "condition": "n < 0",
+						"true_branch": [{"type": "return", "value": "-1"}],
+						"false_branch": [{"type": "if_statement", "condition": "n > 0", "true_branch": [{"type": "return", "value": "1"}]}]},
+					{"type": "return", "code": "return 0"}]}]}`,
+			want: `
+func Sign(n int) int {
+	if n < 0 {
+		return -1
+	} else if n > 0 {
+		return 1
+	}
+	return 0
+}
+`,
+		},
+		{
+			name: "constants",
+			spec: `{"constants": [
+				{"name": "Name", "type": "string", "value": "p"},
+				{"name": "Limit", "type": "int", "value": 10, "doc": "Limit caps the results"},
+				{"name": "Unset", "type": "bool"}]}`,
+			want: `
+const Name = "p"
+
+// Limit caps the results
+const Limit = 10
+
+const Unset bool = false
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := codespec.Parse([]byte(`{"spec_version": "1.0", "language": "go", "module": "p", "file": "p.go", ` + strings.TrimPrefix(tt.spec, "{")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := GenerateGo(spec)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, src)
+			}
+			if got, want := string(src), "package p\n"+tt.want; got != want {
+				t.Errorf("generated:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestGenerateGoRejectsOtherLanguages(t *testing.T) {
+	spec := &codespec.Spec{SpecVersion: "1.0", Language: codespec.LanguagePython, Module: "p", File: "p.py"}
+	if _, err := GenerateGo(spec); err == nil {
+		t.Error("generated Go from a python spec")
+	}
+}
@@ -8,6 +8,8 @@ import (
 type HelpComponent struct {
 	width     int
 	connected bool
+	// waiting is set while a response is awaited, which Esc cancels
+	waiting bool
 }
 
 // NewHelpComponent creates a new help component
@@ -25,6 +27,11 @@ func (h *HelpComponent) SetConnectionStatus(isConnected bool) {
 	h.connected = isConnected
 }
 
+// SetWaiting updates whether a response is awaited
+func (h *HelpComponent) SetWaiting(waiting bool) {
+	h.waiting = waiting
+}
+
 // View renders the help component
 func (h *HelpComponent) View() string {
 	helpStyle := lipgloss.NewStyle().
@@ -40,6 +47,9 @@ func (h *HelpComponent) View() string {
 	}
 
 	helpText := helpStyle.Render("Press Ctrl+C or Esc to quit • Enter to send message")
+	if h.waiting {
+		helpText = helpStyle.Render("Press Esc to cancel • Ctrl+C to quit")
+	}
 
 	// Calculate remaining width for server status
 	helpTextWidth := lipgloss.Width(helpText)
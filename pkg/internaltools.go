@@ -2,6 +2,7 @@ package deepspec
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 )
 
@@ -10,7 +11,26 @@ var internalTools = NewToolRegistry()
 
 func init() {
 	// Register all internal tools
-	internalTools.Register("echo", EchoTool)
+	mustRegisterInternalTool(ToolDefinition{
+		Name:        "echo",
+		Description: "Echo back the exact text provided. Used for testing.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"text": {"type": "string", "description": "The text to echo back"}
+			},
+			"required": ["text"],
+			"additionalProperties": false
+		}`),
+	}, EchoTool)
+}
+
+// mustRegisterInternalTool registers an internal tool, panicking on an
+// invalid definition
+func mustRegisterInternalTool(definition ToolDefinition, fn ToolFunc) {
+	if err := internalTools.Register(definition, fn); err != nil {
+		panic(err)
+	}
 }
 
 // GetInternalTool retrieves an internal tool by name
@@ -18,6 +38,11 @@ func GetInternalTool(name string) (ToolFunc, error) {
 	return internalTools.Get(name)
 }
 
+// ExecuteInternalTool validates the arguments and runs an internal tool
+func ExecuteInternalTool(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	return internalTools.Execute(ctx, name, args)
+}
+
 // EchoTool echoes back the exact text provided
 func EchoTool(ctx context.Context, args map[string]interface{}) (string, error) {
 	text, ok := args["text"].(string)
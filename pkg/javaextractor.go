@@ -0,0 +1,534 @@
+package deepspec
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// JavaExtractor extracts Java files
+type JavaExtractor struct {
+	root    string
+	version string
+}
+
+// NewJavaExtractor creates an extractor for the Java project at root. The
+// language version comes from its Maven or Gradle build.
+func NewJavaExtractor(root string) (*JavaExtractor, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &JavaExtractor{root: root, version: javaVersion(root)}, nil
+}
+
+// javaVersionPatterns find the Java release in pom.xml and Gradle builds
+var javaVersionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`<maven\.compiler\.release>\s*([\d.]+)`),
+	regexp.MustCompile(`<maven\.compiler\.source>\s*([\d.]+)`),
+	regexp.MustCompile(`<java\.version>\s*([\d.]+)`),
+	regexp.MustCompile(`JavaLanguageVersion\.of\(\s*(\d+)`),
+	regexp.MustCompile(`sourceCompatibility\s*=\s*(?:JavaVersion\.VERSION_)?['"]?([\d._]+)`),
+}
+
+// javaVersion returns the Java release a project builds for
+func javaVersion(root string) string {
+	for _, name := range []string{"pom.xml", "build.gradle", "build.gradle.kts"} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		for _, pattern := range javaVersionPatterns {
+			if m := pattern.FindSubmatch(data); m != nil {
+				return strings.ReplaceAll(string(m[1]), "_", ".")
+			}
+		}
+	}
+	return ""
+}
+
+// Language returns codespec.LanguageJava
+func (e *JavaExtractor) Language() string {
+	return codespec.LanguageJava
+}
+
+// Match reports whether path is a .java file
+func (e *JavaExtractor) Match(path string) bool {
+	return strings.HasSuffix(path, ".java")
+}
+
+// Extract builds the spec of a Java file
+func (e *JavaExtractor) Extract(path string) (*codespec.Spec, error) {
+	rel, err := relativeSource(e.root, path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := extractJavaFile(rel, src)
+	spec.LanguageVersion = e.version
+	return spec, nil
+}
+
+// javaParser turns the tokens of a Java file into a spec. Method bodies are
+// converted by the script parser, whose statement syntax Java shares.
+type javaParser struct {
+	scriptParser
+}
+
+// extractJavaFile builds the spec for a single Java file
+func extractJavaFile(file string, src []byte) *codespec.Spec {
+	tokens, _ := tokenize(src, dialectJava)
+	p := &javaParser{scriptParser{
+		tokenStream: tokenStream{src: src, tokens: tokens},
+		spec: &codespec.Spec{
+			SpecVersion: codespec.Version,
+			Language:    codespec.LanguageJava,
+			File:        file,
+		},
+		exported:  make(map[string]bool),
+		modules:   make(map[string]bool),
+		overloads: make(map[string]int),
+		java:      true,
+	}}
+
+	for i := 0; i < len(p.tokens); {
+		switch {
+		case p.is(i, "package"):
+			end := p.statementEnd(i)
+			p.spec.Module = strings.Join(strings.Fields(p.source(i+1, p.withoutSemicolon(i, end))), "")
+			i = end + 1
+		case p.is(i, "import"):
+			end := p.statementEnd(i)
+			j := i + 1
+			imp := codespec.Import{}
+			if p.is(j, "static") {
+				setExtra(&imp.Extra, "static", true)
+				j++
+			}
+			imp.Path = strings.Join(strings.Fields(p.source(j, p.withoutSemicolon(i, end))), "")
+			p.spec.Imports = append(p.spec.Imports, imp)
+			i = end + 1
+		case p.is(i, ";"):
+			i++
+		default:
+			i = p.declaration(i, "", false)
+		}
+	}
+
+	p.spec.Metadata = lineMetrics(src)
+	p.spec.Metadata.CyclomaticComplexity = p.complexity
+	return p.spec
+}
+
+// javaModifiers are the keywords that may precede a declaration
+var javaModifiers = map[string]bool{
+	"public": true, "protected": true, "private": true, "static": true, "final": true, "abstract": true,
+	"sealed": true, "strictfp": true, "synchronized": true, "native": true, "transient": true,
+	"volatile": true, "default": true,
+}
+
+// javaHead holds what precedes a declaration: its doc comment, annotations
+// and modifiers
+type javaHead struct {
+	doc         string
+	annotations []string
+	modifiers   map[string]bool
+	next        int
+}
+
+// head parses the doc comment, annotations and modifiers at i
+func (p *javaParser) head(i int) javaHead {
+	h := javaHead{doc: p.tok(i).doc, modifiers: make(map[string]bool)}
+	for {
+		switch {
+		case p.is(i, "@") && !p.is(i+1, "interface"):
+			end := p.decoratorEnd(i)
+			h.annotations = append(h.annotations, p.source(i+1, end))
+			i = end + 1
+		case p.isIdent(i) && javaModifiers[p.tok(i).text] && !p.is(i+1, ":") && !p.is(i+1, "->"):
+			h.modifiers[p.tok(i).text] = true
+			i++
+		case p.is(i, "non") && p.is(i+1, "-") && p.is(i+2, "sealed"):
+			h.modifiers["non-sealed"] = true
+			i += 3
+		default:
+			h.next = i
+			return h
+		}
+		if h.doc == "" {
+			h.doc = p.tok(i).doc
+		}
+	}
+}
+
+// visibility maps the access modifiers of a declaration. Members without
+// one are package-private, except in interfaces where they are public.
+func (h javaHead) visibility(inInterface bool) string {
+	switch {
+	case h.modifiers["public"] || (inInterface && !h.modifiers["private"]):
+		return codespec.VisibilityPublic
+	case h.modifiers["protected"]:
+		return codespec.VisibilityProtected
+	case h.modifiers["private"]:
+		return codespec.VisibilityPrivate
+	}
+	return codespec.VisibilityInternal
+}
+
+// flags lists the non-access modifiers of a declaration
+func (h javaHead) flags() []string {
+	var flags []string
+	for _, modifier := range []string{"static", "final", "abstract", "sealed", "non-sealed", "default", "synchronized", "native", "transient", "volatile", "strictfp"} {
+		if h.modifiers[modifier] {
+			flags = append(flags, modifier)
+		}
+	}
+	return flags
+}
+
+// isTypeKeyword reports whether a type declaration starts at i
+func (p *javaParser) isTypeKeyword(i int) bool {
+	return p.is(i, "class") || p.is(i, "interface") || p.is(i, "enum") ||
+		(p.is(i, "record") && p.isIdent(i+1)) || (p.is(i, "@") && p.is(i+1, "interface"))
+}
+
+// declaration parses the type declaration at i, nested in outer when not
+// empty, and returns the index after it
+func (p *javaParser) declaration(i int, outer string, inInterface bool) int {
+	h := p.head(i)
+	i = h.next
+	if !p.isTypeKeyword(i) {
+		return p.statementEnd(i) + 1
+	}
+
+	keyword := p.tok(i).text
+	if keyword == "@" {
+		keyword = "@interface"
+		i++
+	}
+	name := p.tok(i + 1).text
+	if outer != "" {
+		name = outer + "." + name
+	}
+	typ := codespec.TypeDefinition{
+		Name:          name,
+		Kind:          codespec.KindClass,
+		Visibility:    h.visibility(inInterface),
+		Documentation: h.doc,
+	}
+	switch keyword {
+	case "interface", "@interface":
+		typ.Kind = codespec.KindInterface
+	case "enum":
+		typ.Kind = codespec.KindEnum
+	case "record":
+		setExtra(&typ.Extra, "record", true)
+	}
+	if keyword == "@interface" {
+		setExtra(&typ.Extra, "annotation", true)
+	}
+	setExtra(&typ.Extra, "modifiers", h.flags())
+	setExtra(&typ.Extra, "annotations", h.annotations)
+
+	j := i + 2
+	if p.is(j, "<") {
+		j = p.matchAngle(j) + 1
+	}
+	if keyword == "record" && p.is(j, "(") {
+		close := p.match(j)
+		for _, param := range p.javaParameters(j+1, close-1) {
+			field := codespec.Field{Name: param.Name, Type: param.Type, Visibility: codespec.VisibilityPrivate}
+			setExtra(&field.Extra, "final", true)
+			typ.Fields = append(typ.Fields, field)
+		}
+		j = close + 1
+	}
+	for !p.is(j, "{") && j < len(p.tokens) {
+		clause := p.tok(j).text
+		end := j + 1
+		for end < len(p.tokens) && !p.is(end, "{") && !p.is(end, "implements") && !p.is(end, "permits") && !(p.is(end, "extends") && end > j) {
+			if p.is(end, "<") {
+				end = p.matchAngle(end)
+			}
+			end++
+		}
+		var names []string
+		for _, part := range p.split(j+1, end-1, ",") {
+			names = append(names, p.source(part[0], part[1]))
+		}
+		switch {
+		case clause == "extends" && typ.Kind == codespec.KindInterface:
+			setExtra(&typ.Extra, "extends", names)
+		case clause == "extends" && len(names) > 0:
+			setExtra(&typ.Extra, "extends", names[0])
+		case clause == "implements" || clause == "permits":
+			setExtra(&typ.Extra, clause, names)
+		}
+		j = end
+	}
+
+	close := p.match(j)
+	// Nested types come after their enclosing type
+	types := len(p.spec.Types)
+	p.spec.Types = append(p.spec.Types, typ)
+	p.members(types, j+1, close-1)
+	return close + 1
+}
+
+// members parses the body of the type at index idx of the spec's types
+func (p *javaParser) members(idx, from, to int) {
+	typ := &p.spec.Types[idx]
+	name := typ.Name
+	simpleName := name[strings.LastIndexByte(name, '.')+1:]
+	inInterface := typ.Kind == codespec.KindInterface
+
+	j := from
+	if typ.Kind == codespec.KindEnum {
+		end := from
+		for end <= to && !p.is(end, ";") {
+			if p.is(end, "(") || p.is(end, "{") {
+				end = p.match(end)
+			}
+			end++
+		}
+		for _, part := range p.split(from, end-1, ",") {
+			if part[0] > part[1] {
+				continue
+			}
+			h := p.head(part[0])
+			field := codespec.Field{Name: p.tok(h.next).text, Type: simpleName, Visibility: codespec.VisibilityPublic, Documentation: h.doc}
+			if p.is(h.next+1, "(") {
+				setExtra(&field.Extra, "default", p.source(h.next+2, p.match(h.next+1)-1))
+			}
+			typ.Fields = append(typ.Fields, field)
+		}
+		j = end + 1
+	}
+
+	for j <= to {
+		if p.is(j, ";") {
+			j++
+			continue
+		}
+		h := p.head(j)
+		k := h.next
+		switch {
+		case p.is(k, "{"):
+			// Initializer block
+			j = p.match(k) + 1
+			continue
+		case p.isTypeKeyword(k):
+			j = p.declaration(j, name, inInterface)
+			// Nested declarations may have grown the slice
+			typ = &p.spec.Types[idx]
+			continue
+		}
+
+		if p.is(k, "<") {
+			k = p.matchAngle(k) + 1
+		}
+		fn := codespec.Function{
+			Receiver:      &codespec.Receiver{Name: "this", Type: name},
+			Visibility:    h.visibility(inInterface),
+			Documentation: h.doc,
+		}
+		if h.modifiers["static"] {
+			fn.Receiver.Name = ""
+		}
+		setExtra(&fn.Extra, "modifiers", h.flags())
+		setExtra(&fn.Extra, "annotations", h.annotations)
+
+		returns := ""
+		switch {
+		case p.is(k, simpleName) && p.is(k+1, "("):
+			// Constructor
+			fn.Name = simpleName
+			k++
+		case p.is(k, simpleName) && p.is(k+1, "{") && typ.Extra["record"] != nil:
+			// Compact record constructor
+			fn.Name = simpleName
+			k++
+		default:
+			typeEnd := p.javaTypeEnd(k)
+			returns = p.source(k, typeEnd)
+			k = typeEnd + 1
+			if !p.is(k+1, "(") {
+				j = p.fields(typ, h, returns, k, inInterface)
+				continue
+			}
+			fn.Name = p.tok(k).text
+			k++
+		}
+
+		if fn.Name == simpleName && typ.Kind == codespec.KindEnum {
+			// Enum constructors are always private
+			fn.Visibility = codespec.VisibilityPrivate
+		}
+		if p.is(k, "(") {
+			close := p.match(k)
+			fn.Parameters = p.javaParameters(k+1, close-1)
+			k = close + 1
+		}
+		if returns != "" && returns != "void" {
+			fn.Returns = []codespec.ReturnValue{{Type: returns}}
+		}
+		for p.is(k, "[") && p.is(k+1, "]") {
+			k += 2
+		}
+		if p.is(k, "throws") {
+			var throws []string
+			end := k + 1
+			for end <= to && !p.is(end, "{") && !p.is(end, ";") && !p.is(end, "default") {
+				end++
+			}
+			for _, part := range p.split(k+1, end-1, ",") {
+				throws = append(throws, p.source(part[0], part[1]))
+			}
+			setExtra(&fn.Extra, "throws", throws)
+			k = end
+		}
+		if p.is(k, "default") {
+			// Annotation element default
+			k = p.statementEnd(k)
+		}
+		if p.is(k, "{") {
+			close := p.match(k)
+			fn.BodyStatements = p.statements(k+1, close-1)
+			p.complexity += p.decisions(k+1, close-1)
+			k = close
+		}
+		j = k + 1
+
+		typ.Methods = append(typ.Methods, javaMethodSignature(fn, returns))
+		p.spec.Functions = append(p.spec.Functions, fn)
+	}
+}
+
+// fields parses the declarators of a field declaration starting at the
+// first name i and returns the index after it
+func (p *javaParser) fields(typ *codespec.TypeDefinition, h javaHead, fieldType string, i int, inInterface bool) int {
+	end := p.statementEnd(i)
+	for _, part := range p.split(i, p.withoutSemicolon(i, end), ",") {
+		if part[0] > part[1] {
+			continue
+		}
+		field := codespec.Field{
+			Name:          p.tok(part[0]).text,
+			Type:          fieldType,
+			Visibility:    h.visibility(false),
+			Documentation: h.doc,
+		}
+		if inInterface {
+			// Interface fields are implicitly public static final
+			field.Visibility = codespec.VisibilityPublic
+		}
+		if eq := p.indexOf(part[0], "="); eq <= part[1] {
+			setExtra(&field.Extra, "default", p.source(eq+1, part[1]))
+		}
+		for _, modifier := range []string{"static", "final", "transient", "volatile"} {
+			if h.modifiers[modifier] {
+				setExtra(&field.Extra, modifier, true)
+			}
+		}
+		setExtra(&field.Extra, "annotations", h.annotations)
+		typ.Fields = append(typ.Fields, field)
+	}
+	return end + 1
+}
+
+// javaTypeEnd returns the index of the last token of the type starting at i:
+// a possibly qualified and annotated name with type arguments and array
+// dimensions
+func (p *scriptParser) javaTypeEnd(i int) int {
+	j := i
+	for {
+		for p.is(j, "@") {
+			j = p.decoratorEnd(j) + 1
+		}
+		if !p.isIdent(j) && !p.is(j, "?") {
+			break
+		}
+		j++
+		if p.is(j, "<") {
+			j = p.matchAngle(j) + 1
+		}
+		if !p.is(j, ".") || !p.isIdent(j+1) {
+			break
+		}
+		j++
+	}
+	for p.is(j, "[") && p.is(j+1, "]") {
+		j += 2
+	}
+	return j - 1
+}
+
+// localDeclaration reports whether tokens from through to declare local
+// variables, as in final List<String> names = List.of()
+func (p *scriptParser) localDeclaration(from, to int) bool {
+	i := from
+	for p.is(i, "final") || p.is(i, "@") {
+		if p.is(i, "@") {
+			i = p.decoratorEnd(i)
+		}
+		i++
+	}
+	switch p.tok(i).text {
+	case "return", "throw", "new", "yield", "assert", "break", "continue", "else", "case", "do", "this", "super":
+		return false
+	}
+	if !p.isIdent(i) {
+		return false
+	}
+	typeEnd := p.javaTypeEnd(i)
+	name := typeEnd + 1
+	return p.isIdent(name) && (name == to || p.is(name+1, "=") || p.is(name+1, ",") || p.is(name+1, "["))
+}
+
+// javaParameters parses a formal parameter list
+func (p *scriptParser) javaParameters(from, to int) []codespec.Parameter {
+	var params []codespec.Parameter
+	for _, part := range p.split(from, to, ",") {
+		i, end := part[0], part[1]
+		if i > end {
+			continue
+		}
+		for p.is(i, "final") || p.is(i, "@") {
+			if p.is(i, "@") {
+				i = p.decoratorEnd(i)
+			}
+			i++
+		}
+		param := codespec.Parameter{Name: p.tok(end).text}
+		typ := p.source(i, end-1)
+		if p.is(end-1, "...") {
+			typ = p.source(i, end-2) + "..."
+		}
+		if typ == "" || param.Name == "this" {
+			// Receiver parameter
+			continue
+		}
+		param.Type = typ
+		params = append(params, param)
+	}
+	return params
+}
+
+// javaMethodSignature renders a method the way it is listed on its type
+func javaMethodSignature(fn codespec.Function, returns string) string {
+	var params []string
+	for _, param := range fn.Parameters {
+		params = append(params, param.Type+" "+param.Name)
+	}
+	signature := fn.Name + "(" + strings.Join(params, ", ") + ")"
+	if returns != "" {
+		signature = returns + " " + signature
+	}
+	return signature
+}
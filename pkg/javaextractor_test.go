@@ -0,0 +1,118 @@
+package deepspec
+
+import (
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+const cartClass = `package shop;
+
+import java.util.ArrayList;
+import java.util.List;
+import static java.util.Objects.requireNonNull;
+
+/** A cart of items. */
+public class Cart implements Iterable<Item> {
+    /** Maximum items per cart. */
+    public static final int MAX_ITEMS = 50;
+    private final List<Item> items = new ArrayList<>();
+    protected String owner;
+    int count;
+
+    public Cart(String owner) {
+        this.owner = requireNonNull(owner);
+    }
+
+    /** Adds an item. */
+    public Item add(String sku, int quantity) throws IllegalArgumentException {
+        if (quantity <= 0) {
+            throw new IllegalArgumentException("quantity must be positive");
+        }
+        Item item = new Item(sku, quantity);
+        items.add(item);
+        return item;
+    }
+
+    private static boolean validate(String sku) {
+        return !sku.isEmpty();
+    }
+
+    protected <T extends Comparable<T>> T max(List<T> values) {
+        return values.get(0);
+    }
+
+    @Override
+    public java.util.Iterator<Item> iterator() {
+        return items.iterator();
+    }
+}
+
+interface Priced {
+    long price();
+
+    default long discounted(double rate) {
+        return (long) (price() * (1 - rate));
+    }
+}
+`
+
+func TestJavaExtractor(t *testing.T) {
+	files := map[string]string{"src/main/java/shop/Cart.java": cartClass}
+	spec := extractFixture(t, codespec.LanguageJava, files, "src/main/java/shop/Cart.java")
+
+	checkSpecFacts(t, spec, []specFact{
+		{name: "package", got: func(s *codespec.Spec) any { return s.Module }, want: `"shop"`},
+		{name: "imports", got: func(s *codespec.Spec) any { return s.Imports }, want: `[
+			{"path": "java.util.ArrayList"}, {"path": "java.util.List"}, {"path": "java.util.Objects.requireNonNull", "static": true}]`},
+		{name: "class", got: func(s *codespec.Spec) any { return findType(s, "Cart") }, want: `{
+			"name": "Cart", "kind": "class", "visibility": "public", "documentation": "A cart of items.", "implements": ["Iterable<Item>"],
+			"fields": [
+				{"name": "MAX_ITEMS", "type": "int", "visibility": "public", "documentation": "Maximum items per cart.", "default": "50", "final": true, "static": true},
+				{"name": "items", "type": "List<Item>", "visibility": "private", "default": "new ArrayList<>()", "final": true},
+				{"name": "owner", "type": "String", "visibility": "protected"},
+				{"name": "count", "type": "int", "visibility": "internal"}
+			],
+			"methods": ["Cart(String owner)", "Item add(String sku, int quantity)", "boolean validate(String sku)", "T max(List<T> values)", "java.util.Iterator<Item> iterator()"]}`},
+		{name: "interface", got: func(s *codespec.Spec) any { t := findType(s, "Priced"); return []any{t.Kind, t.Visibility, t.Methods} }, want: `["interface", "internal", ["long price()", "long discounted(double rate)"]]`},
+		{name: "constructor", got: func(s *codespec.Spec) any { fn := findFunction(s, "Cart"); return []any{fn.Receiver, fn.Returns} }, want: `[{"name": "this", "type": "Cart"}, null]`},
+		{name: "method", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "add")
+			return []any{fn.Documentation, fn.Parameters, fn.Returns, fn.Extra}
+		}, want: `["Adds an item.", [{"name": "sku", "type": "String"}, {"name": "quantity", "type": "int"}], [{"type": "Item"}], {"throws": ["IllegalArgumentException"]}]`},
+		{name: "static private method", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "validate")
+			return []any{fn.Visibility, fn.Receiver, fn.Extra}
+		}, want: `["private", {"name": "", "type": "Cart"}, {"modifiers": ["static"]}]`},
+		{name: "protected generic method", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "max")
+			return []any{fn.Visibility, fn.Parameters, fn.Returns}
+		}, want: `["protected", [{"name": "values", "type": "List<T>"}], [{"type": "T"}]]`},
+		{name: "annotations", got: func(s *codespec.Spec) any { return findFunction(s, "iterator").Extra }, want: `{"annotations": ["Override"]}`},
+		{name: "interface methods", got: func(s *codespec.Spec) any {
+			price, discounted := findFunction(s, "price"), findFunction(s, "discounted")
+			return []any{price.Visibility, price.Receiver, discounted.Visibility, discounted.Extra}
+		}, want: `["public", {"name": "this", "type": "Priced"}, "public", {"modifiers": ["default"]}]`},
+	})
+}
+
+func TestJavaExtractorStrayClosers(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// want are the names of the functions declared
+		want string
+	}{
+		{name: "stray brace", src: "}", want: `[]`},
+		{name: "stray brace after a class", src: "class A { void f() { } } }", want: `["f"]`},
+		{name: "stray brace in a class", src: "class A { void f() { } } void g() {} }\nclass B { void h() {} }", want: `["f", "h"]`},
+		{name: "stray parenthesis", src: "class A { } ) }", want: `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSpecFacts(t, extractWithin(t, codespec.LanguageJava, "A.java", tt.src), []specFact{
+				{name: "functions", got: functionNames, want: tt.want},
+			})
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package deepspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"google.golang.org/genai"
+)
+
+// maxSchemaDepth bounds the nesting of converted schemas, so recursive
+// $ref definitions terminate
+const maxSchemaDepth = 16
+
+// mcpToolDefinition converts an MCP tool into a tool definition whose name
+// is restricted to the characters function declarations accept
+func mcpToolDefinition(tool mcp.Tool) (ToolDefinition, error) {
+	data := tool.RawInputSchema
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(tool.InputSchema); err != nil {
+			return ToolDefinition{}, fmt.Errorf("tool %s: %w", tool.Name, err)
+		}
+	}
+	return ToolDefinition{Name: functionName(tool.Name), Description: tool.Description, Parameters: data}, nil
+}
+
+// functionDeclaration builds a Gemini function declaration from a tool name,
+// description and JSON parameters schema. The declaration name is the tool
+// name restricted to the characters Gemini accepts.
+func functionDeclaration(name, description string, parameters []byte) (*genai.FunctionDeclaration, error) {
+	var schema map[string]any
+	if err := json.Unmarshal(parameters, &schema); err != nil {
+		return nil, fmt.Errorf("tool %s: invalid input schema: %w", name, err)
+	}
+
+	decl := &genai.FunctionDeclaration{
+		Name:        functionName(name),
+		Description: description,
+	}
+	// Gemini rejects object parameters without properties
+	if params := genaiSchema(schema, schemaDefinitions(schema), 0); params != nil && len(params.Properties) > 0 {
+		decl.Parameters = params
+	}
+	return decl, nil
+}
+
+// functionName maps a tool name to a valid function declaration name: at most
+// 64 letters, digits, underscores, dots and dashes, not starting with a digit
+func functionName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9', r == '.', r == '-':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()[:min(b.Len(), 64)]
+}
+
+// schemaDefinitions returns the $defs, or the older definitions, of a schema
+func schemaDefinitions(schema map[string]any) map[string]any {
+	if defs, ok := schema["$defs"].(map[string]any); ok {
+		return defs
+	}
+	defs, _ := schema["definitions"].(map[string]any)
+	return defs
+}
+
+// genaiSchema converts a JSON schema into the OpenAPI subset Gemini accepts.
+// Local $ref pointers are inlined from defs; keywords without an equivalent
+// are dropped.
+func genaiSchema(schema map[string]any, defs map[string]any, depth int) *genai.Schema {
+	if schema == nil || depth > maxSchemaDepth {
+		return nil
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		name := ref[strings.LastIndexByte(ref, '/')+1:]
+		target, _ := defs[name].(map[string]any)
+		return genaiSchema(target, defs, depth+1)
+	}
+
+	s := &genai.Schema{}
+	s.Description, _ = schema["description"].(string)
+	s.Title, _ = schema["title"].(string)
+	s.Format, _ = schema["format"].(string)
+	s.Pattern, _ = schema["pattern"].(string)
+	s.Default = schema["default"]
+
+	switch typ := schema["type"].(type) {
+	case string:
+		s.Type = genaiType(typ)
+	case []any:
+		// ["string", "null"] is a nullable string
+		for _, t := range typ {
+			if name, _ := t.(string); name == "null" {
+				nullable := true
+				s.Nullable = &nullable
+			} else if s.Type == "" {
+				s.Type = genaiType(name)
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		for _, value := range enum {
+			s.Enum = append(s.Enum, fmt.Sprint(value))
+		}
+		if s.Type == "" {
+			s.Type = genai.TypeString
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		s.Items = genaiSchema(items, defs, depth+1)
+		s.Type = genai.TypeArray
+	}
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		s.Properties = make(map[string]*genai.Schema, len(properties))
+		for name, property := range properties {
+			propertySchema, _ := property.(map[string]any)
+			if converted := genaiSchema(propertySchema, defs, depth+1); converted != nil {
+				s.Properties[name] = converted
+			}
+		}
+		s.Type = genai.TypeObject
+	}
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok && s.Properties[name] != nil {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		alternatives, _ := schema[keyword].([]any)
+		for _, alternative := range alternatives {
+			alternativeSchema, _ := alternative.(map[string]any)
+			if converted := genaiSchema(alternativeSchema, defs, depth+1); converted != nil {
+				s.AnyOf = append(s.AnyOf, converted)
+			}
+		}
+	}
+
+	s.Minimum = schemaFloat(schema, "minimum")
+	s.Maximum = schemaFloat(schema, "maximum")
+	s.MinLength = schemaInt(schema, "minLength")
+	s.MaxLength = schemaInt(schema, "maxLength")
+	s.MinItems = schemaInt(schema, "minItems")
+	s.MaxItems = schemaInt(schema, "maxItems")
+	s.MinProperties = schemaInt(schema, "minProperties")
+	s.MaxProperties = schemaInt(schema, "maxProperties")
+	return s
+}
+
+// genaiType maps a JSON schema type name
+func genaiType(name string) genai.Type {
+	switch name {
+	case "string":
+		return genai.TypeString
+	case "number":
+		return genai.TypeNumber
+	case "integer":
+		return genai.TypeInteger
+	case "boolean":
+		return genai.TypeBoolean
+	case "array":
+		return genai.TypeArray
+	case "object":
+		return genai.TypeObject
+	case "null":
+		return genai.TypeNULL
+	}
+	return ""
+}
+
+// schemaFloat returns a numeric schema keyword
+func schemaFloat(schema map[string]any, key string) *float64 {
+	if value, ok := schema[key].(float64); ok {
+		return &value
+	}
+	return nil
+}
+
+// schemaInt returns an integer schema keyword
+func schemaInt(schema map[string]any, key string) *int64 {
+	if value, ok := schema[key].(float64); ok {
+		n := int64(value)
+		return &n
+	}
+	return nil
+}
+
+// toolResultOutput flattens the content of an MCP tool result into the
+// response map sent back to the model
+func toolResultOutput(result *mcp.CallToolResult) map[string]any {
+	var parts []string
+	for _, content := range result.Content {
+		switch content := content.(type) {
+		case mcp.TextContent:
+			parts = append(parts, content.Text)
+		case mcp.EmbeddedResource:
+			if text, ok := content.Resource.(mcp.TextResourceContents); ok {
+				parts = append(parts, text.Text)
+			}
+		case mcp.ImageContent:
+			parts = append(parts, "[image "+content.MIMEType+"]")
+		case mcp.AudioContent:
+			parts = append(parts, "[audio "+content.MIMEType+"]")
+		}
+	}
+	text := strings.Join(parts, "\n")
+
+	if result.IsError {
+		return map[string]any{"error": text}
+	}
+	response := map[string]any{"output": text}
+	if result.StructuredContent != nil {
+		response["structured"] = result.StructuredContent
+	}
+	return response
+}
@@ -0,0 +1,294 @@
+package deepspec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultOpenAIBaseURL is the OpenAI-compatible API of a local Ollama server
+const DefaultOpenAIBaseURL = "http://localhost:11434/v1"
+
+// OpenAIProvider chats through an OpenAI-compatible chat completions API,
+// such as OpenAI itself or a local llama.cpp or Ollama server
+type OpenAIProvider struct {
+	baseURL    string
+	apiKey     string
+	modelName  string
+	httpClient *http.Client
+}
+
+// openaiSession is a conversation kept as the full message history, which
+// every request sends again
+type openaiSession struct {
+	provider *OpenAIProvider
+	tools    []openaiTool
+	messages []openaiMessage
+}
+
+// openaiMessage is a chat completions message
+type openaiMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openaiToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openaiToolCall is a function call of the model. Arguments are JSON text.
+type openaiToolCall struct {
+	// Index orders the fragments of streamed calls
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// openaiTool declares a function to the model
+type openaiTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// openaiRequest is a chat completions request
+type openaiRequest struct {
+	Model    string          `json:"model,omitempty"`
+	Messages []openaiMessage `json:"messages"`
+	Tools    []openaiTool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream,omitempty"`
+}
+
+// openaiResponse is a chat completions response, or a chunk of a streamed
+// one whose choices carry a delta instead of a message
+type openaiResponse struct {
+	Choices []struct {
+		Message openaiMessage `json:"message"`
+		Delta   openaiMessage `json:"delta"`
+	} `json:"choices"`
+	Error *openaiError `json:"error"`
+}
+
+// openaiError is the error body of a failed request
+type openaiError struct {
+	Message string `json:"message"`
+}
+
+// NewOpenAIProvider creates a provider for the configured OpenAI-compatible
+// endpoint. The base URL defaults to DefaultOpenAIBaseURL.
+func NewOpenAIProvider(config *Config) (*OpenAIProvider, error) {
+	baseURL := config.OpenAI.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	return &OpenAIProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     config.OpenAI.APIKey,
+		modelName:  config.OpenAI.Model,
+		httpClient: http.DefaultClient,
+	}, nil
+}
+
+// Name returns ProviderOpenAI
+func (p *OpenAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+// StartSession starts a new conversation with a system message that declares
+// the tools as functions
+func (p *OpenAIProvider) StartSession(ctx context.Context, system string, tools []ToolDefinition) (Session, error) {
+	session := &openaiSession{provider: p}
+	if system != "" {
+		session.messages = []openaiMessage{{Role: "system", Content: system}}
+	}
+	for _, definition := range tools {
+		tool := openaiTool{Type: "function"}
+		tool.Function.Name = definition.Name
+		tool.Function.Description = definition.Description
+		tool.Function.Parameters = definition.Parameters
+		session.tools = append(session.tools, tool)
+	}
+	return session, nil
+}
+
+// Send sends a message and waits for the complete response
+func (s *openaiSession) Send(ctx context.Context, message Message) (*Response, error) {
+	request := s.request(message, false)
+	body, err := s.provider.post(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var result openaiResponse
+	if err := json.NewDecoder(body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("openai: decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, errors.New("openai: response without choices")
+	}
+	return s.reply(request, result.Choices[0].Message)
+}
+
+// Stream sends a message and reads the response as server-sent events
+func (s *openaiSession) Stream(ctx context.Context, message Message, onText func(text string)) (*Response, error) {
+	request := s.request(message, true)
+	body, err := s.provider.post(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Streamed tool calls arrive in fragments keyed by index
+	reply := openaiMessage{Role: "assistant"}
+	calls := make(map[int]*openaiToolCall)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openaiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("openai: decode chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("openai: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			onText(delta.Content)
+			reply.Content += delta.Content
+		}
+		for i, fragment := range delta.ToolCalls {
+			index := i
+			if fragment.Index != nil {
+				index = *fragment.Index
+			}
+			call, ok := calls[index]
+			if !ok {
+				call = &openaiToolCall{Type: "function"}
+				calls[index] = call
+			}
+			if fragment.ID != "" {
+				call.ID = fragment.ID
+			}
+			call.Function.Name += fragment.Function.Name
+			call.Function.Arguments += fragment.Function.Arguments
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		reply.ToolCalls = append(reply.ToolCalls, *calls[index])
+	}
+	return s.reply(request, reply)
+}
+
+// request builds the request of the history followed by a message. The
+// history only takes the message together with the reply.
+func (s *openaiSession) request(message Message, stream bool) openaiRequest {
+	messages := s.messages[:len(s.messages):len(s.messages)]
+	// Tool results must directly follow the reply that made the calls
+	for _, result := range message.ToolResults {
+		output, err := json.Marshal(result.Output)
+		if err != nil {
+			output = []byte(fmt.Sprintf(`{"error": %q}`, err.Error()))
+		}
+		messages = append(messages, openaiMessage{Role: "tool", Content: string(output), ToolCallID: result.ID})
+	}
+	if message.Text != "" {
+		messages = append(messages, openaiMessage{Role: "user", Content: message.Text})
+	}
+	return openaiRequest{
+		Model:    s.provider.modelName,
+		Messages: messages,
+		Tools:    s.tools,
+		Stream:   stream,
+	}
+}
+
+// reply records the request's messages and the model's reply in the
+// history and converts the reply
+func (s *openaiSession) reply(request openaiRequest, message openaiMessage) (*Response, error) {
+	message.Role = "assistant"
+	response := &Response{Text: message.Content}
+	for i, call := range message.ToolCalls {
+		// Some servers leave out IDs, which results must still match
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d_%d", len(request.Messages), i)
+		}
+		call.Index = nil
+		message.ToolCalls[i] = call
+
+		args := make(map[string]any)
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("openai: arguments of %s: %w", call.Function.Name, err)
+			}
+		}
+		response.ToolCalls = append(response.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Args: args})
+	}
+	s.messages = append(request.Messages, message)
+	return response, nil
+}
+
+// post sends a chat completions request and returns the body of a
+// successful response
+func (p *OpenAIProvider) post(ctx context.Context, request openaiRequest) (io.ReadCloser, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	response, err := p.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+		var failure openaiResponse
+		if json.Unmarshal(body, &failure) == nil && failure.Error != nil {
+			return nil, fmt.Errorf("openai: %s: %s", response.Status, failure.Error.Message)
+		}
+		return nil, fmt.Errorf("openai: %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return response.Body, nil
+}
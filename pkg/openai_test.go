@@ -0,0 +1,192 @@
+package deepspec
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// openaiServer is a chat completions endpoint answering with canned bodies
+// and recording the requests it receives
+type openaiServer struct {
+	*httptest.Server
+	requests []string
+	// auth holds the Authorization header of every request
+	auth []string
+}
+
+// newOpenAIServer starts a server answering requests with replies in turn.
+// Replies starting with "data:" are streamed as server-sent events.
+func newOpenAIServer(t *testing.T, status int, replies ...string) *openaiServer {
+	t.Helper()
+	s := &openaiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.requests = append(s.requests, string(body))
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		reply := replies[min(len(s.requests), len(replies))-1]
+		if strings.HasPrefix(reply, "data:") {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// startOpenAISession starts a session against a server with one tool
+func startOpenAISession(t *testing.T, server *openaiServer) Session {
+	t.Helper()
+	config := DefaultConfig()
+	config.OpenAI.BaseURL = server.URL + "/v1/"
+	config.OpenAI.APIKey = "secret"
+	config.OpenAI.Model = "llama3.2"
+	provider, err := NewOpenAIProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := []ToolDefinition{{Name: "get_spec", Description: "Get a spec", Parameters: json.RawMessage(`{"type":"object","properties":{"file":{"type":"string"}}}`)}}
+	session, err := provider.StartSession(context.Background(), "Be brief.", tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestOpenAISend(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusOK,
+		`{"choices": [{"message": {"role": "assistant", "content": "", "tool_calls": [
+			{"id": "call_a", "type": "function", "function": {"name": "get_spec", "arguments": "{\"file\": \"a.go\"}"}},
+			{"type": "function", "function": {"name": "get_spec", "arguments": ""}}]}}]}`,
+		`{"choices": [{"message": {"role": "assistant", "content": "a.go has one function."}}]}`,
+	)
+	session := startOpenAISession(t, server)
+	ctx := context.Background()
+
+	response, err := session.Send(ctx, Message{Text: "What is in a.go?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCalls := []ToolCall{
+		{ID: "call_a", Name: "get_spec", Args: map[string]any{"file": "a.go"}},
+		// Calls without an ID get one, so their results can refer to them
+		{ID: "call_2_1", Name: "get_spec", Args: map[string]any{}},
+	}
+	if !reflect.DeepEqual(response.ToolCalls, wantCalls) {
+		t.Errorf("tool calls = %+v, want %+v", response.ToolCalls, wantCalls)
+	}
+
+	response, err = session.Send(ctx, Message{ToolResults: []ToolResult{
+		{ID: "call_a", Name: "get_spec", Output: map[string]any{"functions": 1}},
+		{ID: "call_2_1", Name: "get_spec", Output: map[string]any{"error": "no file"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "a.go has one function." || len(response.ToolCalls) != 0 {
+		t.Errorf("response = %+v", response)
+	}
+
+	tools := `[{"type": "function", "function": {"name": "get_spec", "description": "Get a spec", "parameters": {"type": "object", "properties": {"file": {"type": "string"}}}}}]`
+	wantRequests := []string{
+		`{"model": "llama3.2", "tools": ` + tools + `, "messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "What is in a.go?"}]}`,
+		`{"model": "llama3.2", "tools": ` + tools + `, "messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "What is in a.go?"},
+			{"role": "assistant", "content": "", "tool_calls": [
+				{"id": "call_a", "type": "function", "function": {"name": "get_spec", "arguments": "{\"file\": \"a.go\"}"}},
+				{"id": "call_2_1", "type": "function", "function": {"name": "get_spec"}}]},
+			{"role": "tool", "content": "{\"functions\":1}", "tool_call_id": "call_a"},
+			{"role": "tool", "content": "{\"error\":\"no file\"}", "tool_call_id": "call_2_1"}]}`,
+	}
+	for i, want := range wantRequests {
+		if i >= len(server.requests) || !sameJSON([]byte(server.requests[i]), []byte(want)) {
+			t.Errorf("request %d = %s, want %s", i, server.requests[min(i, len(server.requests)-1)], want)
+		}
+	}
+	if !slices.Equal(server.auth, []string{"Bearer secret", "Bearer secret"}) {
+		t.Errorf("authorization = %q", server.auth)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusOK, strings.Join([]string{
+		`data: {"choices": [{"delta": {"role": "assistant", "content": "Let me "}}]}`,
+		`: keep-alive`,
+		`data: {"choices": [{"delta": {"content": "look."}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 1, "id": "call_b", "function": {"name": "find_type", "arguments": "{\"name\":"}}]}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "id": "call_a", "function": {"name": "get_", "arguments": "{}"}}]}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"name": "spec"}}, {"index": 1, "function": {"arguments": " \"T\"}"}}]}}]}`,
+		`data: {"choices": []}`,
+		`data: [DONE]`,
+		`data: {"choices": [{"delta": {"content": "ignored"}}]}`,
+	}, "\n\n"))
+	session := startOpenAISession(t, server)
+
+	var pieces []string
+	response, err := session.Stream(context.Background(), Message{Text: "Find T"}, func(text string) {
+		pieces = append(pieces, text)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pieces, []string{"Let me ", "look."}) {
+		t.Errorf("streamed text = %q", pieces)
+	}
+	want := &Response{Text: "Let me look.", ToolCalls: []ToolCall{
+		{ID: "call_a", Name: "get_spec", Args: map[string]any{}},
+		{ID: "call_b", Name: "find_type", Args: map[string]any{"name": "T"}},
+	}}
+	if !reflect.DeepEqual(response, want) {
+		t.Errorf("response = %+v, want %+v", response, want)
+	}
+	var request openaiRequest
+	if err := json.Unmarshal([]byte(server.requests[0]), &request); err != nil || !request.Stream {
+		t.Errorf("request = %s, want a streamed one", server.requests[0])
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		reply   string
+		stream  bool
+		wantErr string
+	}{
+		{name: "error body", status: http.StatusUnauthorized, reply: `{"error": {"message": "invalid api key"}}`, wantErr: "openai: 401 Unauthorized: invalid api key"},
+		{name: "plain error", status: http.StatusBadGateway, reply: "upstream down\n", wantErr: "openai: 502 Bad Gateway: upstream down"},
+		{name: "no choices", status: http.StatusOK, reply: `{"choices": []}`, wantErr: "response without choices"},
+		{name: "bad arguments", status: http.StatusOK, reply: `{"choices": [{"message": {"tool_calls": [{"function": {"name": "get_spec", "arguments": "{"}}]}}]}`, wantErr: "arguments of get_spec"},
+		{name: "stream error", status: http.StatusOK, reply: `data: {"error": {"message": "overloaded"}}`, stream: true, wantErr: "openai: overloaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := startOpenAISession(t, newOpenAIServer(t, tt.status, tt.reply))
+			var err error
+			if tt.stream {
+				_, err = session.Stream(context.Background(), Message{Text: "Hi"}, func(string) {})
+			} else {
+				_, err = session.Send(context.Background(), Message{Text: "Hi"})
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
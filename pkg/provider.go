@@ -0,0 +1,102 @@
+package deepspec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Provider names
+const (
+	ProviderVertex = "vertex"
+	ProviderOpenAI = "openai"
+)
+
+// Provider is an LLM backend that chats can be held with
+type Provider interface {
+	// Name returns the provider name, e.g. ProviderVertex
+	Name() string
+	// StartSession starts a conversation in which the model follows the
+	// system instructions and may call tools
+	StartSession(ctx context.Context, system string, tools []ToolDefinition) (Session, error)
+}
+
+// Session is a conversation with a provider. It keeps the history, so each
+// message only carries the new turn.
+type Session interface {
+	// Send sends a message and returns the model's response
+	Send(ctx context.Context, message Message) (*Response, error)
+	// Stream sends a message and calls onText with each piece of response
+	// text as it arrives. The returned response is complete.
+	Stream(ctx context.Context, message Message, onText func(text string)) (*Response, error)
+}
+
+// Message is a turn sent to the model: user text, the results of the tool
+// calls in the previous response, or both
+type Message struct {
+	Text        string       `json:"text,omitempty"`
+	ToolResults []ToolResult `json:"tool_results,omitempty"`
+}
+
+// Response is a turn of the model: text, tool calls or both
+type Response struct {
+	Text      string     `json:"text,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall is a request of the model to run a tool
+type ToolCall struct {
+	// ID correlates the call with its result; not every provider sets it
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+// ToolResult is the output of a tool call, reported back to the model
+type ToolResult struct {
+	ID     string         `json:"id,omitempty"`
+	Name   string         `json:"name"`
+	Output map[string]any `json:"output"`
+}
+
+// ProviderFactory creates a provider from the config
+type ProviderFactory func(ctx context.Context, config *Config) (Provider, error)
+
+// providerFactories is the registry of providers by name
+var providerFactories = map[string]ProviderFactory{
+	ProviderVertex: func(ctx context.Context, config *Config) (Provider, error) {
+		return NewVertexProvider(ctx, config)
+	},
+	ProviderOpenAI: func(ctx context.Context, config *Config) (Provider, error) {
+		return NewOpenAIProvider(config)
+	},
+	ProviderFake: func(ctx context.Context, config *Config) (Provider, error) {
+		return newConfiguredFakeProvider(config)
+	},
+}
+
+// RegisterProvider makes a provider available under a name, replacing any
+// provider registered for it before
+func RegisterProvider(name string, factory ProviderFactory) {
+	providerFactories[name] = factory
+}
+
+// NewProvider creates the configured provider
+func NewProvider(ctx context.Context, config *Config) (Provider, error) {
+	factory, ok := providerFactories[config.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, expected one of %s", config.Provider, strings.Join(ProviderNames(), ", "))
+	}
+	return factory(ctx, config)
+}
+
+// ProviderNames returns the registered provider names, sorted
+func ProviderNames() []string {
+	var names []string
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
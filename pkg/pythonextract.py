@@ -0,0 +1,450 @@
+"""Extract code-spec documents from Python files using the ast module.
+
+Reads {"root": ..., "files": [...]} from stdin and writes
+{"specs": [...], "errors": [...]} to stdout. The layout matches the built-in
+Go parser of deepspec; module names and line metrics are filled in by the
+caller.
+"""
+
+import ast
+import json
+import os
+import re
+import sys
+
+CONSTANT_NAME = re.compile(r"^_*[A-Z][A-Z0-9_]*$")
+ENUM_BASES = {"Enum", "IntEnum", "StrEnum", "Flag", "IntFlag"}
+
+
+def visibility(name):
+    if name.startswith("_") and not (name.startswith("__") and name.endswith("__")):
+        return "private"
+    return "public"
+
+
+def literal_type(node):
+    if isinstance(node, ast.Constant):
+        value = node.value
+        if isinstance(value, bool):
+            return "bool"
+        if isinstance(value, (int, float, str, bytes)):
+            return type(value).__name__
+        return ""
+    for kind, name in ((ast.List, "list"), (ast.Dict, "dict"), (ast.Set, "set"), (ast.Tuple, "tuple")):
+        if isinstance(node, kind):
+            return name
+    return ""
+
+
+class FileExtractor:
+    def __init__(self, src):
+        self.src = src
+        self.modules = set()
+
+    def segment(self, node):
+        return ast.get_source_segment(self.src, node) or ast.unparse(node)
+
+    def code(self, node):
+        text = self.segment(node)
+        lines = text.split("\n")
+        indent = node.col_offset
+        for i in range(1, len(lines)):
+            line = lines[i]
+            strip = len(line) - len(line.lstrip(" \t"))
+            lines[i] = line[min(strip, indent):].rstrip()
+        return "\n".join(line for line in lines if line)
+
+    def extract(self, tree):
+        spec = {"spec_version": "1.0.0", "language": "python"}
+        body = tree.body
+        doc = ast.get_docstring(tree)
+        if doc is not None:
+            spec["description"] = doc
+            body = body[1:]
+        imports = self.imports(tree.body)
+        if imports:
+            spec["imports"] = imports
+
+        types, functions, variables, constants = [], [], [], []
+        complexity = 0
+        for node in body:
+            if isinstance(node, ast.ClassDef):
+                typ, methods = self.class_def(node)
+                types.append(typ)
+                functions.extend(methods)
+                complexity += sum(self.complexity(m) for m in methods)
+            elif isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef)):
+                fn = self.function(node, None)
+                functions.append(fn)
+                complexity += self.complexity(fn)
+            elif isinstance(node, (ast.Assign, ast.AnnAssign)):
+                self.module_assignment(node, variables, constants)
+        for key, value in (("types", types), ("functions", functions), ("variables", variables), ("constants", constants)):
+            if value:
+                spec[key] = value
+        spec["metadata"] = {"cyclomatic_complexity": complexity}
+        return spec
+
+    def imports(self, nodes):
+        imports = []
+        for node in nodes:
+            if isinstance(node, (ast.Try, ast.If)):
+                imports.extend(self.imports(node.body))
+                for handler in getattr(node, "handlers", []):
+                    imports.extend(self.imports(handler.body))
+                imports.extend(self.imports(node.orelse))
+                imports.extend(self.imports(getattr(node, "finalbody", [])))
+            elif isinstance(node, ast.Import):
+                for alias in node.names:
+                    imports.append(self.import_entry(alias.name, alias.asname))
+                    self.modules.add(alias.asname or alias.name.split(".")[0])
+            elif isinstance(node, ast.ImportFrom):
+                module = "." * node.level + (node.module or "")
+                for alias in node.names:
+                    path = module + alias.name if module.endswith(".") or not module else module + "." + alias.name
+                    imports.append(self.import_entry(path, alias.asname))
+                    self.modules.add(alias.asname or alias.name)
+        return imports
+
+    @staticmethod
+    def import_entry(path, alias):
+        entry = {"path": path}
+        if alias:
+            entry["alias"] = alias
+        return entry
+
+    def class_def(self, node):
+        typ = {"name": node.name, "kind": "class", "visibility": visibility(node.name)}
+        bases = [self.segment(base) for base in node.bases]
+        for base in bases:
+            last = base.split("[")[0].rsplit(".", 1)[-1]
+            if last in ENUM_BASES:
+                typ["kind"] = "enum"
+            elif last == "Protocol":
+                typ["kind"] = "interface"
+        doc = ast.get_docstring(node)
+        if doc is not None:
+            typ["documentation"] = doc
+
+        fields, methods, signatures, seen = [], [], [], set()
+        for child in node.body:
+            if isinstance(child, (ast.FunctionDef, ast.AsyncFunctionDef)):
+                fn = self.function(child, node.name)
+                methods.append(fn)
+                signatures.append(method_signature(fn))
+                if child.name == "__init__":
+                    for field in self.instance_fields(child, fn):
+                        if field["name"] not in seen:
+                            seen.add(field["name"])
+                            fields.append(field)
+            elif isinstance(child, (ast.Assign, ast.AnnAssign)):
+                field = self.class_field(child)
+                if field and field["name"] not in seen:
+                    seen.add(field["name"])
+                    fields.append(field)
+        if fields:
+            typ["fields"] = fields
+        if signatures:
+            typ["methods"] = signatures
+        if bases:
+            typ["bases"] = bases
+        decorators = [self.segment(d) for d in node.decorator_list]
+        if decorators:
+            typ["decorators"] = decorators
+        return typ, methods
+
+    def class_field(self, node):
+        if isinstance(node, ast.AnnAssign):
+            target, annotation, value = node.target, self.segment(node.annotation), node.value
+        elif len(node.targets) == 1:
+            target, annotation, value = node.targets[0], "", node.value
+        else:
+            return None
+        if not isinstance(target, ast.Name):
+            return None
+        field = {
+            "name": target.id,
+            "type": annotation or (literal_type(value) if value is not None else ""),
+            "visibility": visibility(target.id),
+        }
+        if value is not None:
+            field["default"] = self.segment(value)
+        return field
+
+    def instance_fields(self, node, init):
+        receiver = (init.get("receiver") or {}).get("name")
+        if not receiver:
+            return []
+        params = {p["name"]: p["type"] for p in init.get("parameters", [])}
+        fields = []
+        for child in ast.walk(node):
+            if isinstance(child, ast.AnnAssign):
+                target, annotation, value = child.target, self.segment(child.annotation), child.value
+            elif isinstance(child, ast.Assign) and len(child.targets) == 1:
+                target, annotation, value = child.targets[0], "", child.value
+            else:
+                continue
+            if not (isinstance(target, ast.Attribute) and isinstance(target.value, ast.Name) and target.value.id == receiver):
+                continue
+            if not annotation and value is not None:
+                annotation = params.get(self.segment(value), "") or literal_type(value)
+            fields.append({"name": target.attr, "type": annotation, "visibility": visibility(target.attr)})
+        return fields
+
+    def function(self, node, class_name):
+        fn = {"name": node.name, "visibility": visibility(node.name)}
+        decorators = [self.segment(d) for d in node.decorator_list]
+        static = "staticmethod" in decorators
+
+        args = node.args
+        positional = args.posonlyargs + args.args
+        defaults = [None] * (len(positional) - len(args.defaults)) + list(args.defaults)
+        entries = list(zip(positional, defaults))
+        if args.vararg:
+            entries.append((args.vararg, None))
+        entries.extend(zip(args.kwonlyargs, args.kw_defaults))
+        if args.kwarg:
+            entries.append((args.kwarg, None))
+
+        params = []
+        for arg, default in entries:
+            name = arg.arg
+            if arg is args.vararg:
+                name = "*" + name
+            elif arg is args.kwarg:
+                name = "**" + name
+            param = {"name": name, "type": self.segment(arg.annotation) if arg.annotation else ""}
+            if default is not None:
+                param["default"] = self.segment(default)
+            if class_name and not static and "receiver" not in fn and not name.startswith("*"):
+                fn["receiver"] = {"name": name, "type": class_name}
+                continue
+            params.append(param)
+        if class_name and "receiver" not in fn:
+            fn["receiver"] = {"name": "", "type": class_name}
+
+        doc = ast.get_docstring(node)
+        if doc is not None:
+            fn["documentation"] = doc
+        if params:
+            fn["parameters"] = params
+        if node.returns is not None:
+            returns = self.segment(node.returns)
+            if returns != "None":
+                fn["returns"] = [{"type": returns}]
+
+        body = node.body
+        if doc is not None:
+            body = body[1:]
+        statements = self.statements(body)
+        if statements:
+            fn["body_statements"] = statements
+        if decorators:
+            fn["decorators"] = decorators
+        if isinstance(node, ast.AsyncFunctionDef):
+            fn["async"] = True
+        return fn
+
+    def constant_value(self, node):
+        if isinstance(node, ast.Constant) and isinstance(node.value, (bool, int, float)) and node.value is not None:
+            return node.value
+        if isinstance(node, ast.UnaryOp) and isinstance(node.op, ast.USub) and isinstance(node.operand, ast.Constant) \
+                and isinstance(node.operand.value, (int, float)) and not isinstance(node.operand.value, bool):
+            return -node.operand.value
+        return self.segment(node)
+
+    def statements(self, nodes):
+        result = []
+        for node in nodes:
+            if isinstance(node, ast.Pass) or (isinstance(node, ast.Expr) and isinstance(node.value, ast.Constant) and node.value.value is Ellipsis):
+                continue
+            result.append(self.statement(node))
+        return result
+
+    def statement(self, node):
+        code = self.code(node)
+        if isinstance(node, ast.Return):
+            st = {"type": "return", "code": code}
+            if node.value is not None:
+                st["value"] = self.segment(node.value)
+            return st
+        if isinstance(node, ast.If):
+            st = {"type": "if_statement", "condition": self.segment(node.test)}
+            self.branch(st, "true_branch", node.body)
+            self.branch(st, "false_branch", node.orelse)
+            return st
+        if isinstance(node, (ast.For, ast.AsyncFor)):
+            st = {"type": "for_loop", "operation": "range",
+                  "condition": self.segment(node.target) + " in " + self.segment(node.iter)}
+            if isinstance(node, ast.AsyncFor):
+                st["description"] = "await"
+            self.branch(st, "true_branch", node.body)
+            self.branch(st, "false_branch", node.orelse)
+            return st
+        if isinstance(node, ast.While):
+            st = {"type": "for_loop", "operation": "while", "condition": self.segment(node.test)}
+            self.branch(st, "true_branch", node.body)
+            self.branch(st, "false_branch", node.orelse)
+            return st
+        if sys.version_info >= (3, 10) and isinstance(node, ast.Match):
+            cases = []
+            for case in node.cases:
+                label = "case " + self.segment(case.pattern)
+                if case.guard is not None:
+                    label += " if " + self.segment(case.guard)
+                entry = {"type": label}
+                statements = self.statements(case.body)
+                if statements:
+                    entry["statements"] = statements
+                cases.append(entry)
+            return {"type": "switch_statement", "condition": self.segment(node.subject), "cases": cases}
+        if isinstance(node, (ast.Assign, ast.AnnAssign, ast.AugAssign)):
+            st = {"type": "assignment", "code": code}
+            if isinstance(node, ast.AnnAssign):
+                st["type"] = "variable_declaration"
+            if isinstance(node, ast.AugAssign):
+                st["operation"] = self.segment(node).split("=", 1)[0].split()[-1] + "="
+                return st
+            value = node.value.value if isinstance(node.value, ast.Await) else node.value
+            if isinstance(value, ast.Call):
+                callee = self.callee(value)
+                if callee:
+                    st["operation"] = callee
+                    if callee in ("cast", "typing.cast"):
+                        st["type"] = "type_assertion"
+            return st
+        if isinstance(node, ast.Expr):
+            value, awaited = node.value, False
+            if isinstance(value, ast.Await):
+                value, awaited = value.value, True
+            if isinstance(value, ast.Call) and self.callee(value):
+                callee = self.callee(value)
+                st = {"type": "function_call", "operation": callee, "code": code}
+                if "." in callee:
+                    if callee.endswith(".append") or callee.endswith(".extend"):
+                        st["type"] = "append"
+                    elif callee.split(".")[0] not in self.modules:
+                        st["type"] = "method_call"
+                if awaited:
+                    st["description"] = "await"
+                return st
+        return {"type": "function_body", "code": code}
+
+    def branch(self, st, key, nodes):
+        statements = self.statements(nodes)
+        if statements:
+            st[key] = statements
+
+    def callee(self, call):
+        parts, node = [], call.func
+        while isinstance(node, ast.Attribute):
+            parts.append(node.attr)
+            node = node.value
+        if not isinstance(node, ast.Name):
+            return ""
+        parts.append(node.id)
+        return ".".join(reversed(parts))
+
+    def module_assignment(self, node, variables, constants):
+        if isinstance(node, ast.AnnAssign):
+            pairs = [(node.target, node.value)]
+            annotation = self.segment(node.annotation)
+        else:
+            annotation = ""
+            pairs = []
+            for target in node.targets:
+                if isinstance(target, ast.Tuple) and isinstance(node.value, ast.Tuple) and len(target.elts) == len(node.value.elts):
+                    pairs.extend(zip(target.elts, node.value.elts))
+                elif isinstance(target, ast.Tuple):
+                    pairs.extend((elt, None) for elt in target.elts)
+                else:
+                    pairs.append((target, node.value))
+
+        for target, value in pairs:
+            if not isinstance(target, ast.Name):
+                continue
+            if value is None and not annotation:
+                continue
+            name = target.id
+            typ = annotation or (literal_type(value) if value is not None else "")
+            exported = not name.startswith("_")
+            final = re.match(r"^(typing\.)?Final(\[|$)", typ) is not None
+            if (CONSTANT_NAME.match(name) or final) and value is not None:
+                if final:
+                    typ = re.sub(r"^(typing\.)?Final\[?", "", typ)
+                    typ = typ[:-1] if typ.endswith("]") else typ
+                    typ = typ or literal_type(value)
+                constant = {"name": name, "type": typ, "exported": exported}
+                if isinstance(value, ast.Constant) and value.value is not None and not isinstance(value.value, bytes):
+                    constant["value"] = value.value
+                elif isinstance(value, ast.Constant) and value.value is None:
+                    constant["value"] = "None"
+                else:
+                    constant["value"] = self.constant_value(value)
+                constants.append(constant)
+                continue
+            variable = {"name": name, "type": typ, "exported": exported}
+            if value is not None:
+                variable["initialization"] = {"code": self.segment(value)}
+            variables.append(variable)
+
+    def complexity(self, fn):
+        count = 1
+
+        def walk(statements):
+            nonlocal count
+            for st in statements:
+                if st["type"] in ("if_statement", "for_loop"):
+                    count += 1
+                for case in st.get("cases", []):
+                    if case["type"] != "case _":
+                        count += 1
+                for text in (st.get("condition", ""), st.get("code", "")):
+                    count += sum(1 for word in text.split() if word in ("and", "or", "except"))
+                walk(st.get("true_branch", []))
+                walk(st.get("false_branch", []))
+                for case in st.get("cases", []):
+                    walk(case.get("statements", []))
+
+        walk(fn.get("body_statements", []))
+        return count
+
+
+def method_signature(fn):
+    params = []
+    for p in fn.get("parameters", []):
+        text = p["name"]
+        if p["type"]:
+            text += ": " + p["type"]
+        if "default" in p:
+            text += (" = " if p["type"] else "=") + p["default"]
+        params.append(text)
+    signature = fn["name"] + "(" + ", ".join(params) + ")"
+    if fn.get("returns"):
+        signature += " -> " + fn["returns"][0]["type"]
+    return signature
+
+
+def main():
+    request = json.load(sys.stdin)
+    specs, errors = [], []
+    for file in request["files"]:
+        path = os.path.join(request["root"], file)
+        try:
+            with open(path, encoding="utf-8") as f:
+                src = f.read()
+            tree = ast.parse(src, filename=file)
+        except SyntaxError as e:
+            errors.append("%s:%s: %s" % (file, e.lineno, e.msg))
+            continue
+        except (OSError, UnicodeDecodeError) as e:
+            errors.append("%s: %s" % (file, e))
+            continue
+        spec = FileExtractor(src).extract(tree)
+        spec["file"] = file
+        specs.append(spec)
+    json.dump({"specs": specs, "errors": errors}, sys.stdout)
+
+
+if __name__ == "__main__":
+    main()
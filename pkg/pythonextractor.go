@@ -0,0 +1,1381 @@
+package deepspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// ExtractPythonSpecs walks a directory of Python sources and returns one spec
+// per .py file, sorted by file path. Files are parsed by the built-in parser
+// unless interpreter names a local Python executable, in which case its ast
+// module does the parsing.
+func ExtractPythonSpecs(root, interpreter string) ([]*codespec.Spec, error) {
+	extractor, err := NewPythonExtractor(root)
+	if err != nil {
+		return nil, err
+	}
+	extractor.Interpreter = interpreter
+	return ExtractSpecs(root, extractor)
+}
+
+// PythonExtractor extracts Python files
+type PythonExtractor struct {
+	// Interpreter names a local Python executable whose ast module parses
+	// files instead of the built-in parser
+	Interpreter string
+
+	root    string
+	version string
+}
+
+// NewPythonExtractor creates an extractor for the Python project at root
+func NewPythonExtractor(root string) (*PythonExtractor, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &PythonExtractor{root: root, version: pythonRequires(root)}, nil
+}
+
+// Language returns codespec.LanguagePython
+func (e *PythonExtractor) Language() string {
+	return codespec.LanguagePython
+}
+
+// Match reports whether path is a .py file
+func (e *PythonExtractor) Match(path string) bool {
+	return strings.HasSuffix(path, ".py")
+}
+
+// Extract builds the spec of a Python file
+func (e *PythonExtractor) Extract(path string) (*codespec.Spec, error) {
+	rel, err := relativeSource(e.root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec *codespec.Spec
+	if e.Interpreter != "" {
+		specs, err := extractPythonWithInterpreter(e.Interpreter, e.root, []string{rel})
+		if err != nil {
+			return nil, err
+		}
+		if len(specs) != 1 {
+			return nil, fmt.Errorf("%s returned %d specs for %s", e.Interpreter, len(specs), rel)
+		}
+		spec = specs[0]
+	} else {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		spec = extractPythonFile(rel, src)
+	}
+	spec.LanguageVersion = e.version
+	return spec, nil
+}
+
+// pythonRequires returns the requires-python constraint of pyproject.toml
+func pythonRequires(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "pyproject.toml"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "requires-python" {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// pythonModuleName derives the dotted module name of a file, treating a
+// leading src directory as a source root
+func pythonModuleName(file string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(file, "src/"), ".py")
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "__init__"), "/")
+	if name == "" {
+		return "__init__"
+	}
+	return strings.ReplaceAll(name, "/", ".")
+}
+
+// pyLine is a logical line of Python source: physical lines joined by open
+// brackets, backslashes or multi-line strings
+type pyLine struct {
+	indent int
+	line   int
+	start  int
+	end    int
+}
+
+// pyNode is a logical line together with the block it opens, if any
+type pyNode struct {
+	pyLine
+	// keyword is the compound statement keyword (if, def, class, ...)
+	keyword string
+	// header is the text between the keyword and the block colon
+	header string
+	// inline holds the statement following the colon on the same line
+	inline string
+	body   []*pyNode
+}
+
+// pyCompoundKeywords start statements that own an indented block
+var pyCompoundKeywords = map[string]bool{
+	"if": true, "elif": true, "else": true, "for": true, "while": true,
+	"try": true, "except": true, "finally": true, "with": true, "def": true,
+	"class": true, "match": true, "case": true,
+}
+
+// pythonSource is a parsed Python file. clean is the source with comments
+// blanked out, so offsets into it match offsets into the original.
+type pythonSource struct {
+	clean []byte
+	nodes []*pyNode
+}
+
+// parsePython splits src into logical lines and nests them by indentation
+func parsePython(src []byte) *pythonSource {
+	p := &pythonSource{clean: make([]byte, len(src))}
+	copy(p.clean, src)
+	lines := p.logicalLines()
+	// Stray indentation at module level starts a new top level block
+	for i := 0; i < len(lines); {
+		var nodes []*pyNode
+		nodes, i = p.block(lines, i, -1)
+		p.nodes = append(p.nodes, nodes...)
+	}
+	return p
+}
+
+// logicalLines scans the source, blanking comments and splitting it into
+// logical lines at newlines outside brackets and at top level semicolons
+func (p *pythonSource) logicalLines() []pyLine {
+	src := p.clean
+	var lines []pyLine
+	current := pyLine{start: -1}
+	depth, lineNo := 0, 1
+
+	flush := func(end int) {
+		if current.start >= 0 {
+			for end > current.start && (src[end-1] == ' ' || src[end-1] == '\t' || src[end-1] == '\r') {
+				end--
+			}
+			if end > current.start {
+				current.end = end
+				lines = append(lines, current)
+			}
+		}
+		current = pyLine{start: -1}
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		if current.start < 0 {
+			// Measure the indentation of a new logical line
+			col, j := 0, i
+			for ; j < len(src) && (src[j] == ' ' || src[j] == '\t' || src[j] == '\f'); j++ {
+				if src[j] == '\t' {
+					col = col/8*8 + 8
+				} else {
+					col++
+				}
+			}
+			switch {
+			case j >= len(src):
+				i = j
+			case src[j] == '\n':
+				lineNo++
+				i = j + 1
+			case src[j] == '\r':
+				i = j + 1
+			default:
+				current = pyLine{indent: col, line: lineNo, start: j}
+				i = j
+			}
+			continue
+		}
+
+		switch {
+		case c == '#':
+			for ; i < len(src) && src[i] != '\n'; i++ {
+				src[i] = ' '
+			}
+		case c == '\\' && i+1 < len(src) && (src[i+1] == '\n' || src[i+1] == '\r'):
+			i++
+			if src[i] == '\r' && i+1 < len(src) && src[i+1] == '\n' {
+				i++
+			}
+			lineNo++
+			i++
+		case c == '\n':
+			lineNo++
+			if depth == 0 {
+				flush(i)
+			}
+			i++
+		case c == ';' && depth == 0:
+			flush(i)
+			i++
+			for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+				i++
+			}
+			if i < len(src) && src[i] != '\n' && src[i] != '#' {
+				indent := 0
+				if len(lines) > 0 {
+					indent = lines[len(lines)-1].indent
+				}
+				current = pyLine{indent: indent, line: lineNo, start: i}
+			}
+		case c == '(' || c == '[' || c == '{':
+			depth++
+			i++
+		case c == ')' || c == ']' || c == '}':
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case c == '"' || c == '\'':
+			end := pyStringEnd(src, i)
+			lineNo += strings.Count(string(src[i:end]), "\n")
+			i = end
+		default:
+			i++
+		}
+	}
+	flush(len(src))
+	return lines
+}
+
+// pyStringEnd returns the offset just past the string literal starting at i
+func pyStringEnd(src []byte, i int) int {
+	quote := src[i]
+	triple := i+2 < len(src) && src[i+1] == quote && src[i+2] == quote
+	if triple {
+		for j := i + 3; j+2 < len(src); j++ {
+			if src[j] == '\\' {
+				j++
+				continue
+			}
+			if src[j] == quote && src[j+1] == quote && src[j+2] == quote {
+				return j + 3
+			}
+		}
+		return len(src)
+	}
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '\n':
+			return j
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// block collects the logical lines indented deeper than parent into nodes,
+// returning the nodes and the index of the first line that does not belong
+func (p *pythonSource) block(lines []pyLine, i, parent int) ([]*pyNode, int) {
+	var nodes []*pyNode
+	indent := -1
+	for i < len(lines) {
+		line := lines[i]
+		if line.indent <= parent || (indent >= 0 && line.indent < indent) {
+			break
+		}
+		if indent < 0 {
+			indent = line.indent
+		}
+		node := &pyNode{pyLine: line}
+		i++
+
+		text := string(p.clean[line.start:line.end])
+		keyword := pyFirstWord(strings.TrimPrefix(text, "async "))
+		if pyCompoundKeywords[keyword] {
+			if colon := pyBlockColon(text); colon >= 0 {
+				node.keyword = keyword
+				node.header = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text[:colon]), keyword))
+				if strings.HasPrefix(text, "async ") {
+					node.header = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(text[:colon], "async ")), keyword))
+					node.keyword = "async " + keyword
+				}
+				node.inline = strings.TrimSpace(text[colon+1:])
+				if node.inline == "" {
+					node.body, i = p.block(lines, i, line.indent)
+					if len(node.body) > 0 {
+						node.end = node.body[len(node.body)-1].end
+					}
+				}
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, i
+}
+
+// pyFirstWord returns the leading identifier of a line
+func pyFirstWord(text string) string {
+	end := strings.IndexFunc(text, func(r rune) bool { return !pyIdentRune(r) })
+	if end < 0 {
+		return text
+	}
+	return text[:end]
+}
+
+// pyIdentRune reports whether r can be part of an identifier
+func pyIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// pyBlockColon finds the colon ending a compound statement header, skipping
+// brackets, strings, lambdas and walrus operators
+func pyBlockColon(text string) int {
+	depth, lambdas := 0, 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '"' || c == '\'':
+			i = pyStringEnd([]byte(text), i) - 1
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case depth == 0 && c == ':' && (i+1 >= len(text) || text[i+1] != '='):
+			if lambdas > 0 {
+				lambdas--
+				continue
+			}
+			return i
+		case depth == 0 && strings.HasPrefix(text[i:], "lambda") && (i == 0 || !pyIdentRune(rune(text[i-1]))) &&
+			(i+6 >= len(text) || !pyIdentRune(rune(text[i+6]))):
+			lambdas++
+			i += 5
+		}
+	}
+	return -1
+}
+
+// pySplit splits text at top level occurrences of sep
+func pySplit(text string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '"' || c == '\'':
+			i = pyStringEnd([]byte(text), i) - 1
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" || len(parts) > 0 {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// pyAssignment finds the top level assignment operator of a statement and
+// returns its offset and the operator, or -1 when there is none
+func pyAssignment(text string) (int, string) {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '"' || c == '\'':
+			i = pyStringEnd([]byte(text), i) - 1
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case depth == 0 && c == '=':
+			if i+1 < len(text) && text[i+1] == '=' {
+				i++
+				continue
+			}
+			if i > 0 && strings.IndexByte("=!<>:", text[i-1]) >= 0 && !(text[i-1] == '>' && i > 1 && text[i-2] == '>') &&
+				!(text[i-1] == '<' && i > 1 && text[i-2] == '<') {
+				continue
+			}
+			// Augmented assignments such as += or //=
+			start := i
+			for start > 0 && strings.IndexByte("+-*/%@&|^<>", text[start-1]) >= 0 {
+				start--
+			}
+			return start, text[start : i+1]
+		case depth == 0 && (strings.HasPrefix(text[i:], "lambda") || strings.HasPrefix(text[i:], "def ")):
+			return -1, ""
+		}
+	}
+	return -1, ""
+}
+
+// pyAnnotation splits an assignment target into name and annotation
+func pyAnnotation(target string) (string, string) {
+	parts := pySplit(target, ':')
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return strings.TrimSpace(target), ""
+}
+
+// pythonFileExtractor converts a parsed Python file into a spec
+type pythonFileExtractor struct {
+	src     *pythonSource
+	modules map[string]bool
+}
+
+// extractPythonFile builds the spec for a single Python file
+func extractPythonFile(file string, src []byte) *codespec.Spec {
+	x := &pythonFileExtractor{src: parsePython(src), modules: make(map[string]bool)}
+	spec := &codespec.Spec{
+		SpecVersion: codespec.Version,
+		Language:    codespec.LanguagePython,
+		Module:      pythonModuleName(file),
+		File:        file,
+	}
+
+	nodes := x.src.nodes
+	if doc, ok := x.docstring(nodes); ok {
+		spec.Description = doc
+		nodes = nodes[1:]
+	}
+	x.imports(spec, x.src.nodes)
+
+	complexity := 0
+	var decorators []string
+	for _, node := range nodes {
+		text := x.text(node)
+		switch {
+		case strings.HasPrefix(text, "@"):
+			decorators = append(decorators, strings.TrimSpace(text[1:]))
+			continue
+		case node.keyword == "class":
+			typ, methods := x.class(node, decorators)
+			spec.Types = append(spec.Types, typ)
+			spec.Functions = append(spec.Functions, methods...)
+			for _, method := range methods {
+				complexity += x.complexity(method)
+			}
+		case node.keyword == "def" || node.keyword == "async def":
+			fn := x.function(node, decorators, "")
+			spec.Functions = append(spec.Functions, fn)
+			complexity += x.complexity(fn)
+		case node.keyword == "":
+			x.moduleAssignment(spec, text)
+		}
+		decorators = nil
+	}
+
+	spec.Metadata = pythonLineMetrics(src)
+	spec.Metadata.CyclomaticComplexity = complexity
+	return spec
+}
+
+// text returns the comment-free source of a node's first logical line
+func (x *pythonFileExtractor) text(node *pyNode) string {
+	return string(x.src.clean[node.start:node.pyLine.lineEnd(x.src.clean)])
+}
+
+// lineEnd returns the end of the logical line itself, which for compound
+// statements is where the header ends rather than the whole block
+func (l pyLine) lineEnd(src []byte) int {
+	end := l.start
+	depth := 0
+	for end < l.end {
+		switch c := src[end]; {
+		case c == '"' || c == '\'':
+			end = pyStringEnd(src, end)
+			continue
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == '\\' && end+1 < len(src) && src[end+1] == '\n':
+			end += 2
+			continue
+		case c == '\n' && depth == 0:
+			return trimRightSpace(src, l.start, end)
+		}
+		end++
+	}
+	return trimRightSpace(src, l.start, end)
+}
+
+// trimRightSpace moves end back over trailing whitespace
+func trimRightSpace(src []byte, start, end int) int {
+	for end > start && (src[end-1] == ' ' || src[end-1] == '\t' || src[end-1] == '\r') {
+		end--
+	}
+	return end
+}
+
+// code returns the comment-free source of a node including its block,
+// dedented to the node's own indentation
+func (x *pythonFileExtractor) code(node *pyNode) string {
+	return x.codeRange(node.start, node.end, node.indent)
+}
+
+// codeRange returns a dedented, comment-free slice of the source
+func (x *pythonFileExtractor) codeRange(start, end, indent int) string {
+	lines := strings.Split(string(x.src.clean[start:end]), "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if i > 0 {
+			trim := 0
+			for trim < indent && trim < len(line) && (line[trim] == ' ' || line[trim] == '\t') {
+				trim++
+			}
+			line = line[trim:]
+		}
+		lines[i] = line
+	}
+	// Lines that only held comments are left empty
+	var kept []string
+	for _, line := range lines {
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// docstring returns the docstring of a module, class or function body
+func (x *pythonFileExtractor) docstring(body []*pyNode) (string, bool) {
+	if len(body) == 0 || body[0].keyword != "" {
+		return "", false
+	}
+	value, ok := pyStringValue(x.text(body[0]))
+	if !ok {
+		return "", false
+	}
+	return pyCleanDoc(value), true
+}
+
+// imports collects import statements at module level, including those
+// guarded by try or if blocks
+func (x *pythonFileExtractor) imports(spec *codespec.Spec, nodes []*pyNode) {
+	for _, node := range nodes {
+		switch node.keyword {
+		case "try", "except", "else", "finally", "if", "elif":
+			x.imports(spec, node.body)
+			continue
+		case "":
+		default:
+			continue
+		}
+
+		text := x.text(node)
+		switch pyFirstWord(text) {
+		case "import":
+			for _, part := range pySplit(strings.TrimSpace(strings.TrimPrefix(text, "import")), ',') {
+				path, alias := pyImportAlias(part)
+				spec.Imports = append(spec.Imports, codespec.Import{Path: path, Alias: alias})
+				if alias != "" {
+					x.modules[alias] = true
+				} else {
+					x.modules[strings.Split(path, ".")[0]] = true
+				}
+			}
+		case "from":
+			module, names, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(text, "from")), " import ")
+			if !ok {
+				continue
+			}
+			module = strings.TrimSpace(module)
+			names = strings.Trim(strings.TrimSpace(names), "()")
+			for _, part := range pySplit(names, ',') {
+				if part == "" {
+					continue
+				}
+				name, alias := pyImportAlias(part)
+				path := module + "." + name
+				if strings.HasSuffix(module, ".") {
+					path = module + name
+				}
+				spec.Imports = append(spec.Imports, codespec.Import{Path: path, Alias: alias})
+				// Imported names may be submodules, so calls through them
+				// count as function calls
+				if alias != "" {
+					x.modules[alias] = true
+				} else {
+					x.modules[name] = true
+				}
+			}
+		}
+	}
+}
+
+// pyImportAlias splits "path as alias"
+func pyImportAlias(part string) (string, string) {
+	fields := strings.Fields(part)
+	if len(fields) == 3 && fields[1] == "as" {
+		return fields[0], fields[2]
+	}
+	return strings.Join(fields, ""), ""
+}
+
+// class converts a class definition into a type and its methods
+func (x *pythonFileExtractor) class(node *pyNode, decorators []string) (codespec.TypeDefinition, []codespec.Function) {
+	name, bases := node.header, ""
+	if open := strings.IndexByte(name, '('); open >= 0 {
+		bases = strings.TrimSuffix(strings.TrimSpace(name[open+1:]), ")")
+		name = strings.TrimSpace(name[:open])
+	}
+	if open := strings.IndexByte(name, '['); open >= 0 {
+		name = strings.TrimSpace(name[:open])
+	}
+
+	typ := codespec.TypeDefinition{Name: name, Kind: codespec.KindClass, Visibility: pythonVisibility(name)}
+	var baseList []string
+	for _, base := range pySplit(bases, ',') {
+		if base == "" || strings.HasPrefix(base, "metaclass=") {
+			continue
+		}
+		baseList = append(baseList, base)
+		switch base[strings.LastIndexByte(base, '.')+1:] {
+		case "Enum", "IntEnum", "StrEnum", "Flag", "IntFlag":
+			typ.Kind = codespec.KindEnum
+		case "Protocol":
+			typ.Kind = codespec.KindInterface
+		}
+		if strings.HasPrefix(base, "Protocol[") || strings.HasPrefix(base, "typing.Protocol[") {
+			typ.Kind = codespec.KindInterface
+		}
+	}
+	setExtra(&typ.Extra, "bases", baseList)
+	setExtra(&typ.Extra, "decorators", decorators)
+
+	body := node.body
+	if doc, ok := x.docstring(body); ok {
+		typ.Documentation = doc
+		body = body[1:]
+	}
+
+	var methods []codespec.Function
+	seen := make(map[string]bool)
+	var methodDecorators []string
+	for _, child := range body {
+		text := x.text(child)
+		switch {
+		case strings.HasPrefix(text, "@"):
+			methodDecorators = append(methodDecorators, strings.TrimSpace(text[1:]))
+			continue
+		case child.keyword == "def" || child.keyword == "async def":
+			fn := x.function(child, methodDecorators, name)
+			methods = append(methods, fn)
+			typ.Methods = append(typ.Methods, pythonMethodSignature(fn))
+			if fn.Name == "__init__" {
+				for _, field := range x.instanceFields(child, fn) {
+					if !seen[field.Name] {
+						seen[field.Name] = true
+						typ.Fields = append(typ.Fields, field)
+					}
+				}
+			}
+		case child.keyword == "":
+			if field, ok := x.classField(text); ok && !seen[field.Name] {
+				seen[field.Name] = true
+				typ.Fields = append(typ.Fields, field)
+			}
+		}
+		methodDecorators = nil
+	}
+	return typ, methods
+}
+
+// classField converts a class level assignment or annotation into a field
+func (x *pythonFileExtractor) classField(text string) (codespec.Field, bool) {
+	target, value := text, ""
+	if idx, op := pyAssignment(text); idx >= 0 {
+		if op != "=" {
+			return codespec.Field{}, false
+		}
+		target, value = text[:idx], strings.TrimSpace(text[idx+1:])
+	}
+	name, annotation := pyAnnotation(target)
+	if !pyIdentifier(name) || (annotation == "" && value == "") {
+		return codespec.Field{}, false
+	}
+	field := codespec.Field{Name: name, Type: annotation, Visibility: pythonVisibility(name)}
+	if field.Type == "" {
+		field.Type = pyLiteralType(value)
+	}
+	if value != "" {
+		setExtra(&field.Extra, "default", value)
+	}
+	return field, true
+}
+
+// instanceFields collects the self attributes assigned in __init__
+func (x *pythonFileExtractor) instanceFields(node *pyNode, init codespec.Function) []codespec.Field {
+	if init.Receiver == nil || init.Receiver.Name == "" {
+		return nil
+	}
+	params := make(map[string]string)
+	for _, p := range init.Parameters {
+		params[p.Name] = p.Type
+	}
+
+	var fields []codespec.Field
+	var walk func(nodes []*pyNode)
+	walk = func(nodes []*pyNode) {
+		for _, child := range nodes {
+			if child.keyword == "def" || child.keyword == "async def" || child.keyword == "class" {
+				continue
+			}
+			if child.keyword != "" {
+				walk(child.body)
+				continue
+			}
+			text := x.text(child)
+			idx, op := pyAssignment(text)
+			if idx < 0 || op != "=" {
+				continue
+			}
+			name, annotation := pyAnnotation(text[:idx])
+			attr, ok := strings.CutPrefix(name, init.Receiver.Name+".")
+			if !ok || !pyIdentifier(attr) {
+				continue
+			}
+			value := strings.TrimSpace(text[idx+1:])
+			if annotation == "" {
+				annotation = params[value]
+			}
+			if annotation == "" {
+				annotation = pyLiteralType(value)
+			}
+			fields = append(fields, codespec.Field{Name: attr, Type: annotation, Visibility: pythonVisibility(attr)})
+		}
+	}
+	walk(node.body)
+	return fields
+}
+
+// function converts a def statement. Methods of className get the first
+// parameter as receiver unless they are static methods.
+func (x *pythonFileExtractor) function(node *pyNode, decorators []string, className string) codespec.Function {
+	header := node.header
+	open := strings.IndexByte(header, '(')
+	if open < 0 {
+		open = len(header)
+	}
+	name := strings.TrimSpace(header[:open])
+	if idx := strings.IndexByte(name, '['); idx >= 0 {
+		name = strings.TrimSpace(name[:idx])
+	}
+
+	params, returns := "", ""
+	if open < len(header) {
+		close := pyMatchingParen(header, open)
+		params = header[open+1 : close]
+		if arrow := strings.Index(header[close:], "->"); arrow >= 0 {
+			returns = strings.TrimSpace(header[close+arrow+2:])
+		}
+	}
+
+	fn := codespec.Function{Name: name, Visibility: pythonVisibility(name)}
+	setExtra(&fn.Extra, "decorators", decorators)
+	if strings.HasPrefix(node.keyword, "async ") {
+		setExtra(&fn.Extra, "async", true)
+	}
+
+	static := false
+	for _, decorator := range decorators {
+		static = static || decorator == "staticmethod"
+	}
+	for _, param := range pySplit(params, ',') {
+		if param == "" || param == "/" || param == "*" {
+			continue
+		}
+		p := codespec.Parameter{}
+		target := param
+		if idx, op := pyAssignment(param); idx >= 0 && op == "=" {
+			target = param[:idx]
+			p.Default = codespec.RawValue(strings.TrimSpace(param[idx+1:]))
+		}
+		p.Name, p.Type = pyAnnotation(target)
+		if className != "" && !static && fn.Receiver == nil && !strings.HasPrefix(p.Name, "*") {
+			fn.Receiver = &codespec.Receiver{Name: p.Name, Type: className}
+			continue
+		}
+		fn.Parameters = append(fn.Parameters, p)
+	}
+	if className != "" && fn.Receiver == nil {
+		fn.Receiver = &codespec.Receiver{Type: className}
+	}
+	if returns != "" && returns != "None" {
+		fn.Returns = []codespec.ReturnValue{{Type: returns}}
+	}
+
+	body := node.body
+	if doc, ok := x.docstring(body); ok {
+		fn.Documentation = doc
+		body = body[1:]
+	}
+	if node.inline != "" {
+		body = nil
+		if node.inline != "..." && node.inline != "pass" {
+			fn.BodyStatements = []codespec.Statement{x.simpleStatement(node.inline)}
+		}
+	}
+	fn.BodyStatements = append(fn.BodyStatements, x.statements(body)...)
+	return fn
+}
+
+// pyMatchingParen returns the offset of the bracket closing the one at open
+func pyMatchingParen(text string, open int) int {
+	depth := 0
+	for i := open; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '"' || c == '\'':
+			i = pyStringEnd([]byte(text), i) - 1
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(text)
+}
+
+// statements converts a block of statements, attaching elif, else, except
+// and finally clauses to the statement they continue
+func (x *pythonFileExtractor) statements(nodes []*pyNode) []codespec.Statement {
+	var statements []codespec.Statement
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		// Clauses continuing this statement
+		j := i + 1
+		for j < len(nodes) && pyContinuesStatement(node.keyword, nodes[j].keyword) {
+			j++
+		}
+		clauses := nodes[i+1 : j]
+		i = j - 1
+
+		switch node.keyword {
+		case "":
+			text := x.text(node)
+			if text == "pass" || text == "..." {
+				continue
+			}
+			statements = append(statements, x.simpleStatement(x.code(node)))
+		case "if":
+			statements = append(statements, x.ifStatement(node, clauses))
+		case "for", "async for":
+			st := codespec.Statement{
+				Type:       codespec.StatementFor,
+				Operation:  "range",
+				Condition:  node.header,
+				TrueBranch: x.suite(node),
+			}
+			if node.keyword == "async for" {
+				st.Description = "await"
+			}
+			if len(clauses) > 0 {
+				st.FalseBranch = x.suite(clauses[0])
+			}
+			statements = append(statements, st)
+		case "while":
+			st := codespec.Statement{
+				Type:       codespec.StatementFor,
+				Operation:  "while",
+				Condition:  node.header,
+				TrueBranch: x.suite(node),
+			}
+			if len(clauses) > 0 {
+				st.FalseBranch = x.suite(clauses[0])
+			}
+			statements = append(statements, st)
+		case "match":
+			st := codespec.Statement{Type: codespec.StatementSwitch, Condition: node.header}
+			for _, c := range node.body {
+				if c.keyword == "case" {
+					st.Cases = append(st.Cases, codespec.Case{Type: "case " + c.header, Statements: x.suite(c)})
+				}
+			}
+			statements = append(statements, st)
+		default:
+			// try, with, nested definitions and the like are kept verbatim
+			end := node.end
+			if len(clauses) > 0 {
+				end = clauses[len(clauses)-1].end
+			}
+			statements = append(statements, codespec.Statement{
+				Type: codespec.StatementFunctionBody,
+				Code: x.codeRange(node.start, end, node.indent),
+			})
+		}
+	}
+	return statements
+}
+
+// pyContinuesStatement reports whether a clause keyword continues a statement
+func pyContinuesStatement(keyword, next string) bool {
+	switch keyword {
+	case "if", "elif":
+		return next == "elif" || next == "else"
+	case "for", "async for", "while":
+		return next == "else"
+	case "try", "except":
+		return next == "except" || next == "else" || next == "finally"
+	}
+	return false
+}
+
+// suite converts the block of a compound statement, or its inline statement
+func (x *pythonFileExtractor) suite(node *pyNode) []codespec.Statement {
+	if node.inline != "" {
+		if node.inline == "pass" || node.inline == "..." {
+			return nil
+		}
+		var statements []codespec.Statement
+		for _, part := range pySplit(node.inline, ';') {
+			if part != "" {
+				statements = append(statements, x.simpleStatement(part))
+			}
+		}
+		return statements
+	}
+	return x.statements(node.body)
+}
+
+// ifStatement converts an if statement with its elif and else clauses
+func (x *pythonFileExtractor) ifStatement(node *pyNode, clauses []*pyNode) codespec.Statement {
+	st := codespec.Statement{Type: codespec.StatementIf, Condition: node.header, TrueBranch: x.suite(node)}
+	if len(clauses) > 0 {
+		switch clauses[0].keyword {
+		case "elif":
+			st.FalseBranch = []codespec.Statement{x.ifStatement(clauses[0], clauses[1:])}
+		case "else":
+			st.FalseBranch = x.suite(clauses[0])
+		}
+	}
+	return st
+}
+
+// simpleStatement classifies a statement that opens no block
+func (x *pythonFileExtractor) simpleStatement(code string) codespec.Statement {
+	switch pyFirstWord(code) {
+	case "return":
+		return codespec.Statement{
+			Type:  codespec.StatementReturn,
+			Code:  code,
+			Value: strings.TrimSpace(strings.TrimPrefix(code, "return")),
+		}
+	case "await":
+		st := x.simpleStatement(strings.TrimSpace(strings.TrimPrefix(code, "await")))
+		st.Code = code
+		st.Description = "await"
+		return st
+	case "raise", "del", "assert", "global", "nonlocal", "yield", "import", "from", "break", "continue":
+		return codespec.Statement{Type: codespec.StatementFunctionBody, Code: code}
+	}
+
+	if idx, op := pyAssignment(code); idx >= 0 {
+		st := codespec.Statement{Type: codespec.StatementAssignment, Code: code}
+		target, value := code[:idx], strings.TrimSpace(code[idx+len(op):])
+		if _, annotation := pyAnnotation(target); annotation != "" {
+			st.Type = codespec.StatementVariableDeclaration
+		}
+		if op != "=" {
+			st.Operation = op
+			return st
+		}
+		if callee, ok := pyCallee(strings.TrimPrefix(value, "await ")); ok {
+			st.Operation = callee
+			if callee == "cast" || callee == "typing.cast" {
+				st.Type = codespec.StatementTypeAssertion
+			}
+		}
+		return st
+	}
+	if _, annotation := pyAnnotation(code); annotation != "" && pyIdentifier(strings.Split(code, ":")[0]) {
+		return codespec.Statement{Type: codespec.StatementVariableDeclaration, Code: code}
+	}
+
+	if callee, ok := pyCallee(code); ok {
+		st := codespec.Statement{Type: codespec.StatementFunctionCall, Operation: callee, Code: code}
+		if dot := strings.LastIndexByte(callee, '.'); dot >= 0 {
+			switch {
+			case strings.HasSuffix(callee, ".append") || strings.HasSuffix(callee, ".extend"):
+				st.Type = codespec.StatementAppend
+			case !x.modules[pyFirstWord(callee)]:
+				// module.func is a function call, value.method a method call
+				st.Type = codespec.StatementMethodCall
+			}
+		}
+		return st
+	}
+	return codespec.Statement{Type: codespec.StatementFunctionBody, Code: code}
+}
+
+// pyCallee returns the called expression when code is a single call
+func pyCallee(code string) (string, bool) {
+	code = strings.TrimSpace(code)
+	if !strings.HasSuffix(code, ")") {
+		return "", false
+	}
+	open := strings.IndexByte(code, '(')
+	if open <= 0 || pyMatchingParen(code, open) != len(code)-1 {
+		return "", false
+	}
+	callee := strings.TrimSpace(code[:open])
+	for _, part := range strings.Split(callee, ".") {
+		if !pyIdentifier(part) {
+			return "", false
+		}
+	}
+	return callee, true
+}
+
+// moduleAssignment adds a module level assignment as a constant or variable.
+// Upper case names and Final annotations mark constants.
+func (x *pythonFileExtractor) moduleAssignment(spec *codespec.Spec, text string) {
+	target, value := text, ""
+	if idx, op := pyAssignment(text); idx >= 0 {
+		if op != "=" {
+			return
+		}
+		target, value = text[:idx], strings.TrimSpace(text[idx+1:])
+	}
+	name, annotation := pyAnnotation(target)
+	if annotation == "" && value == "" {
+		return
+	}
+
+	// Chained (a = b = 1) and tuple (a, b = 1, 2) assignments
+	targets := []string{name}
+	for {
+		idx, op := pyAssignment(value)
+		if idx < 0 || op != "=" {
+			break
+		}
+		targets = append(targets, strings.TrimSpace(value[:idx]))
+		value = strings.TrimSpace(value[idx+1:])
+	}
+	values := make([]string, len(targets))
+	for i := range values {
+		values[i] = value
+	}
+	if len(targets) == 1 {
+		if names := pySplit(name, ','); len(names) > 1 {
+			targets = names
+			values = pySplit(strings.Trim(value, "()"), ',')
+			if len(values) != len(targets) {
+				values = make([]string, len(targets))
+			}
+		}
+	}
+
+	for i, target := range targets {
+		if !pyIdentifier(target) {
+			continue
+		}
+		v := values[i]
+		typ := annotation
+		if typ == "" {
+			typ = pyLiteralType(v)
+		}
+		exported := !strings.HasPrefix(target, "_")
+		final := typ == "Final" || strings.HasPrefix(typ, "Final[") || strings.HasPrefix(typ, "typing.Final")
+		if (pyConstantName.MatchString(target) || final) && v != "" {
+			if final {
+				typ = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(typ, "typing."), "Final["), "]")
+				if typ == "Final" {
+					typ = pyLiteralType(v)
+				}
+			}
+			spec.Constants = append(spec.Constants, codespec.Constant{
+				Name:     target,
+				Type:     typ,
+				Value:    codespec.RawValue(pyConstantValue(v)),
+				Exported: codespec.Bool(exported),
+			})
+			continue
+		}
+
+		variable := codespec.Variable{Name: target, Type: typ, Exported: codespec.Bool(exported)}
+		if v != "" {
+			variable.Initialization = map[string]any{"code": v}
+		}
+		spec.Variables = append(spec.Variables, variable)
+	}
+}
+
+// pyConstantName matches the UPPER_CASE naming convention for constants
+var pyConstantName = regexp.MustCompile(`^_*[A-Z][A-Z0-9_]*$`)
+
+// pyIdentifierPattern matches a plain identifier
+var pyIdentifierPattern = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_]*$`)
+
+// pyIdentifier reports whether s is a plain identifier
+func pyIdentifier(s string) bool {
+	return pyIdentifierPattern.MatchString(strings.TrimSpace(s))
+}
+
+// pythonVisibility maps the underscore conventions onto visibility: dunder
+// names are public, anything else with a leading underscore private
+func pythonVisibility(name string) string {
+	if strings.HasPrefix(name, "_") && !(strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__")) {
+		return codespec.VisibilityPrivate
+	}
+	return codespec.VisibilityPublic
+}
+
+// pythonMethodSignature renders a method the way it is listed on its class
+func pythonMethodSignature(fn codespec.Function) string {
+	var params []string
+	for _, p := range fn.Parameters {
+		params = append(params, pyParameter(p))
+	}
+	signature := fn.Name + "(" + strings.Join(params, ", ") + ")"
+	if len(fn.Returns) > 0 {
+		signature += " -> " + fn.Returns[0].Type
+	}
+	return signature
+}
+
+// pyParameter renders a parameter with its annotation and default
+func pyParameter(p codespec.Parameter) string {
+	text := p.Name
+	if p.Type != "" {
+		text += ": " + p.Type
+	}
+	if len(p.Default) > 0 {
+		sep := "="
+		if p.Type != "" {
+			sep = " = "
+		}
+		text += sep + fmtDefault(codespec.DecodeValue(p.Default))
+	}
+	return text
+}
+
+// fmtDefault renders a parameter default as Python source
+func fmtDefault(value any) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case string:
+		return v
+	}
+	return formatJSON(value)
+}
+
+// pyConstantValue converts a constant value: literals become JSON values,
+// other expressions stay Python source
+func pyConstantValue(code string) any {
+	if pyLiteralType(code) == "bytes" {
+		return code
+	}
+	if s, ok := pyStringValue(code); ok {
+		return s
+	}
+	switch code {
+	case "True":
+		return true
+	case "False":
+		return false
+	}
+	if number, ok := pyNumber(code); ok {
+		return number
+	}
+	return code
+}
+
+// pyNumber parses a decimal int or float literal
+func pyNumber(code string) (json.Number, bool) {
+	code = strings.ReplaceAll(code, "_", "")
+	if _, err := strconv.ParseInt(code, 10, 64); err == nil {
+		return json.Number(code), true
+	}
+	if _, err := strconv.ParseFloat(code, 64); err == nil && !strings.ContainsAny(code, "xXnN") {
+		return json.Number(code), true
+	}
+	return "", false
+}
+
+// pyLiteralType infers the type of a literal value
+func pyLiteralType(code string) string {
+	code = strings.TrimSpace(code)
+	switch {
+	case code == "":
+		return ""
+	case code == "True" || code == "False":
+		return "bool"
+	case strings.HasPrefix(code, "[") && strings.HasSuffix(code, "]"):
+		return "list"
+	case strings.HasPrefix(code, "{") && strings.HasSuffix(code, "}"):
+		if code == "{}" || len(pySplit(strings.Trim(code, "{}"), ':')) > 1 {
+			return "dict"
+		}
+		return "set"
+	case strings.HasPrefix(code, "(") && strings.HasSuffix(code, ")") && len(pySplit(code[1:len(code)-1], ',')) > 1:
+		return "tuple"
+	}
+	if _, ok := pyStringValue(code); ok {
+		if strings.HasPrefix(strings.ToLower(code), "b") || strings.HasPrefix(strings.ToLower(code), "rb") {
+			return "bytes"
+		}
+		return "str"
+	}
+	if number, ok := pyNumber(code); ok {
+		if strings.ContainsAny(string(number), ".eE") {
+			return "float"
+		}
+		return "int"
+	}
+	return ""
+}
+
+// pyStringValue decodes a single (possibly prefixed) string literal
+func pyStringValue(code string) (string, bool) {
+	code = strings.TrimSpace(code)
+	prefix := strings.IndexAny(code, `"'`)
+	if prefix < 0 || prefix > 2 || strings.Trim(strings.ToLower(code[:prefix]), "rbuf") != "" {
+		return "", false
+	}
+	if pyStringEnd([]byte(code), prefix) != len(code) {
+		return "", false
+	}
+	raw := strings.ContainsRune(strings.ToLower(code[:prefix]), 'r')
+	body := code[prefix:]
+	quote := 1
+	if len(body) >= 6 && body[1] == body[0] && body[2] == body[0] {
+		quote = 3
+	}
+	if len(body) < 2*quote {
+		return "", false
+	}
+	body = body[quote : len(body)-quote]
+	if raw {
+		return body, true
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' || i+1 >= len(body) {
+			b.WriteByte(body[i])
+			continue
+		}
+		i++
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\', '\'', '"':
+			b.WriteByte(body[i])
+		case '\n':
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(body[i])
+		}
+	}
+	return b.String(), true
+}
+
+// pyCleanDoc strips docstring indentation like inspect.cleandoc
+func pyCleanDoc(doc string) string {
+	lines := strings.Split(strings.ReplaceAll(doc, "\t", "        "), "\n")
+	margin := -1
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimLeft(line, " "); trimmed != "" {
+			if indent := len(line) - len(trimmed); margin < 0 || indent < margin {
+				margin = indent
+			}
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= margin && margin > 0 {
+			lines[i] = lines[i][margin:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// complexity counts the decision points of a function plus one
+func (x *pythonFileExtractor) complexity(fn codespec.Function) int {
+	complexity := 1
+	codespec.Walk(fn.BodyStatements, func(st *codespec.Statement) {
+		switch st.Type {
+		case codespec.StatementIf, codespec.StatementFor:
+			complexity++
+		case codespec.StatementSwitch:
+			for _, c := range st.Cases {
+				if c.Type != "case _" {
+					complexity++
+				}
+			}
+		}
+		for _, text := range []string{st.Condition, st.Code} {
+			for _, word := range strings.Fields(text) {
+				switch word {
+				case "and", "or", "except":
+					complexity++
+				}
+			}
+		}
+	})
+	return complexity
+}
+
+// pythonLineMetrics counts code, blank and comment lines of Python source
+func pythonLineMetrics(src []byte) *codespec.Metadata {
+	metrics := &codespec.Metadata{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(src), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			metrics.BlankLines++
+		case strings.HasPrefix(trimmed, "#"):
+			metrics.CommentLines++
+		default:
+			metrics.LinesOfCode++
+		}
+	}
+	return metrics
+}
+
+// setExtra stores value as an extra property unless it is empty
+func setExtra(extra *map[string]json.RawMessage, key string, value any) {
+	if list, ok := value.([]string); ok && len(list) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return
+	}
+	if *extra == nil {
+		*extra = make(map[string]json.RawMessage)
+	}
+	(*extra)[key] = bytes.TrimSpace(buf.Bytes())
+}
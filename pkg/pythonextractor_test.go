@@ -0,0 +1,124 @@
+package deepspec
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+const cartSource = `"""Shopping cart helpers."""
+from __future__ import annotations
+
+import logging
+from dataclasses import dataclass, field
+from typing import Optional
+
+MAX_ITEMS: int = 50
+_DEFAULT_CURRENCY = "EUR"
+log = logging.getLogger(__name__)
+
+
+@dataclass
+class Item:
+    """A line item."""
+    sku: str
+    quantity: int = 1
+    _note: Optional[str] = None
+
+
+class Cart:
+    """A cart of items."""
+
+    def __init__(self, owner: str, items: list[Item] | None = None) -> None:
+        self.owner = owner
+        self.items = items or []
+
+    @property
+    def size(self) -> int:
+        """Number of items."""
+        return sum(item.quantity for item in self.items)
+
+    def add(self, sku: str, quantity: int = 1, *, note: str = "") -> Item:
+        if quantity <= 0:
+            raise ValueError("quantity must be positive")
+        item = Item(sku, quantity, note or None)
+        self.items.append(item)
+        return item
+
+    @staticmethod
+    def _validate(sku: str) -> bool:
+        return bool(sku)
+
+    async def checkout(self, *args, **kwargs) -> None:
+        for item in self.items:
+            log.info("checkout %s", item.sku)
+
+
+def total(cart: Cart, discount: float = 0.0) -> float:
+    """Total quantity after a discount."""
+    return cart.size * (1 - discount)
+`
+
+func TestPythonExtractor(t *testing.T) {
+	files := map[string]string{
+		"pyproject.toml":   "[project]\nname = \"shop\"\nrequires-python = \">=3.10\"\n",
+		"src/shop/cart.py": cartSource,
+	}
+	builtin := extractFixture(t, codespec.LanguagePython, files, "src/shop/cart.py")
+
+	checkSpecFacts(t, builtin, []specFact{
+		{name: "module", got: func(s *codespec.Spec) any { return []string{s.Module, s.LanguageVersion, s.Description} }, want: `["shop.cart", ">=3.10", "Shopping cart helpers."]`},
+		{name: "imports", got: func(s *codespec.Spec) any { return s.Imports }, want: `[{"path": "__future__.annotations"}, {"path": "logging"}, {"path": "dataclasses.dataclass"}, {"path": "dataclasses.field"}, {"path": "typing.Optional"}]`},
+		{name: "dataclass", got: func(s *codespec.Spec) any { return findType(s, "Item") }, want: `{
+			"name": "Item", "kind": "class", "visibility": "public", "documentation": "A line item.", "decorators": ["dataclass"],
+			"fields": [
+				{"name": "sku", "type": "str", "visibility": "public"},
+				{"name": "quantity", "type": "int", "visibility": "public", "default": "1"},
+				{"name": "_note", "type": "Optional[str]", "visibility": "private", "default": "None"}
+			]}`},
+		{name: "class methods", got: func(s *codespec.Spec) any { return findType(s, "Cart").Methods }, want: `[
+			"__init__(owner: str, items: list[Item] | None = None)", "size() -> int", "add(sku: str, quantity: int = 1, note: str = \"\") -> Item",
+			"_validate(sku: str) -> bool", "checkout(*args, **kwargs)"]`},
+		{name: "defaults", got: func(s *codespec.Spec) any { return findFunction(s, "add").Parameters }, want: `[
+			{"name": "sku", "type": "str"}, {"name": "quantity", "type": "int", "default": "1"}, {"name": "note", "type": "str", "default": "\"\""}]`},
+		{name: "property", got: func(s *codespec.Spec) any { fn := findFunction(s, "size"); return []any{fn.Receiver, fn.Extra} }, want: `[{"name": "self", "type": "Cart"}, {"decorators": ["property"]}]`},
+		{name: "static private method", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "_validate")
+			return []any{fn.Visibility, fn.Receiver}
+		}, want: `["private", {"name": "", "type": "Cart"}]`},
+		{name: "async", got: func(s *codespec.Spec) any { return findFunction(s, "checkout").Extra }, want: `{"async": true}`},
+		{name: "function", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "total")
+			return []any{fn.Receiver, fn.Documentation, fn.Parameters[1], fn.Returns}
+		}, want: `[null, "Total quantity after a discount.", {"name": "discount", "type": "float", "default": "0.0"}, [{"type": "float"}]]`},
+		{name: "constants", got: func(s *codespec.Spec) any { return s.Constants }, want: `[{"name": "MAX_ITEMS", "type": "int", "value": 50, "exported": true}, {"name": "_DEFAULT_CURRENCY", "type": "str", "value": "EUR", "exported": false}]`},
+		{name: "variables", got: func(s *codespec.Spec) any { return s.Variables }, want: `[{"name": "log", "type": "", "initialization": {"code": "logging.getLogger(__name__)"}, "exported": true}]`},
+	})
+
+	// The interpreter backend must agree with the built-in parser
+	t.Run("interpreter", func(t *testing.T) {
+		interpreter, err := exec.LookPath("python3")
+		if err != nil {
+			t.Skip("no python3 interpreter")
+		}
+		specs, err := ExtractPythonSpecs(writeSourceTree(t, files), interpreter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(specs) != 1 {
+			t.Fatalf("got %d specs, want 1", len(specs))
+		}
+		want, err := builtin.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := specs[0].Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("interpreter spec differs from the built-in one:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package deepspec
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// pythonExtractScript parses files with Python's own ast module
+//
+//go:embed pythonextract.py
+var pythonExtractScript string
+
+// extractPythonWithInterpreter runs the embedded extraction script with a
+// local interpreter and completes the specs it returns
+func extractPythonWithInterpreter(interpreter, root string, files []string) ([]*codespec.Spec, error) {
+	request, err := json.Marshal(map[string]any{"root": root, "files": files})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(interpreter, "-c", pythonExtractScript)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", interpreter, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var result struct {
+		Specs  []*codespec.Spec `json:"specs"`
+		Errors []string         `json:"errors"`
+	}
+	dec := json.NewDecoder(&stdout)
+	dec.UseNumber()
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s: reading extraction output: %w", interpreter, err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, errors.New(strings.Join(result.Errors, "\n"))
+	}
+
+	for _, spec := range result.Specs {
+		src, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(spec.File)))
+		if err != nil {
+			return nil, err
+		}
+		complexity := 0
+		if spec.Metadata != nil {
+			complexity = spec.Metadata.CyclomaticComplexity
+		}
+		spec.Module = pythonModuleName(spec.File)
+		spec.Metadata = pythonLineMetrics(src)
+		spec.Metadata.CyclomaticComplexity = complexity
+	}
+	return result.Specs, nil
+}
@@ -0,0 +1,322 @@
+package deepspec
+
+import (
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// RoundtripReport is the result of extracting, regenerating and re-checking
+// every package below a directory. It passes when the fidelity reaches the
+// threshold and every package regenerates and type-checks.
+type RoundtripReport struct {
+	Root      string             `json:"root"`
+	Fidelity  float64            `json:"fidelity"`
+	Threshold float64            `json:"threshold"`
+	Passed    bool               `json:"passed"`
+	Packages  []PackageRoundtrip `json:"packages"`
+}
+
+// PackageRoundtrip is the round-trip result of a single package
+type PackageRoundtrip struct {
+	Dir             string             `json:"dir"`
+	Package         string             `json:"package"`
+	Fidelity        float64            `json:"fidelity"`
+	APITotal        int                `json:"api_total"`
+	APIMatched      int                `json:"api_matched"`
+	StatementsTotal int                `json:"statements_total"`
+	StatementsKept  int                `json:"statements_kept"`
+	GenerateErrors  []string           `json:"generate_errors,omitempty"`
+	CompileErrors   []string           `json:"compile_errors,omitempty"`
+	APIDifferences  []APIDifference    `json:"api_differences,omitempty"`
+	FunctionLosses  []FunctionFidelity `json:"function_losses,omitempty"`
+}
+
+// APIDifference is an exported declaration that did not survive unchanged
+type APIDifference struct {
+	Name        string `json:"name"`
+	Change      string `json:"change"`
+	Original    string `json:"original,omitempty"`
+	Regenerated string `json:"regenerated,omitempty"`
+}
+
+// FunctionFidelity lists the statements of a function lost in regeneration
+type FunctionFidelity struct {
+	File        string   `json:"file"`
+	Function    string   `json:"function"`
+	Original    int      `json:"original"`
+	Regenerated int      `json:"regenerated"`
+	Lost        []string `json:"lost"`
+}
+
+// Roundtrip extracts specs for every package below root, regenerates Go
+// source from them, type-checks the result and compares it with the original
+func Roundtrip(root string, threshold float64) (*RoundtripReport, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := goPackageDirs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", goExportLookup(root))
+
+	report := &RoundtripReport{Root: root, Threshold: threshold}
+	matched, total := 0, 0
+	for _, dir := range dirs {
+		result, err := roundtripPackage(fset, imp, dir)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, dir); err == nil {
+			result.Dir = filepath.ToSlash(rel)
+		}
+		matched += result.APIMatched + result.StatementsKept
+		total += result.APITotal + result.StatementsTotal
+		report.Packages = append(report.Packages, *result)
+	}
+
+	report.Fidelity = ratio(matched, total)
+	report.Passed = report.Fidelity >= threshold && !report.HasErrors()
+	return report, nil
+}
+
+// HasErrors reports whether the source of any package could not be
+// regenerated or failed to type-check. Such a round trip fails whatever its
+// fidelity.
+func (r *RoundtripReport) HasErrors() bool {
+	for _, pkg := range r.Packages {
+		if len(pkg.GenerateErrors) > 0 || len(pkg.CompileErrors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// roundtripPackage runs the round trip for a single package directory
+func roundtripPackage(fset *token.FileSet, imp types.Importer, dir string) (*PackageRoundtrip, error) {
+	original, err := loadGoPackage(fset, imp, dir)
+	if err != nil || original == nil {
+		return nil, err
+	}
+	specs, err := original.specs(fset, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PackageRoundtrip{Package: original.types.Name()}
+
+	tmp, err := os.MkdirTemp("", "deepspec-roundtrip-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, spec := range specs {
+		src, err := GenerateGo(spec)
+		if err != nil {
+			result.GenerateErrors = append(result.GenerateErrors, fmt.Sprintf("%s: %v", spec.File, err))
+		}
+		if err := os.WriteFile(filepath.Join(tmp, filepath.Base(spec.File)), src, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	regenerated, err := loadGoPackage(fset, imp, tmp)
+	if err != nil {
+		result.CompileErrors = append(result.CompileErrors, strings.ReplaceAll(err.Error(), tmp+string(filepath.Separator), ""))
+	}
+	var regeneratedSpecs []*codespec.Spec
+	if regenerated != nil {
+		for _, err := range regenerated.errors {
+			result.CompileErrors = append(result.CompileErrors, strings.ReplaceAll(err.Error(), tmp+string(filepath.Separator), ""))
+		}
+		if regeneratedSpecs, err = regenerated.specs(fset, tmp); err != nil {
+			return nil, err
+		}
+	}
+
+	compareAPI(result, exportedAPI(original.types), exportedAPI(packageTypes(regenerated)))
+	compareStatements(result, specs, regeneratedSpecs)
+	result.Fidelity = ratio(result.APIMatched+result.StatementsKept, result.APITotal+result.StatementsTotal)
+	return result, nil
+}
+
+// packageTypes returns the type-checked package, if any
+func packageTypes(pkg *goPackage) *types.Package {
+	if pkg == nil {
+		return nil
+	}
+	return pkg.types
+}
+
+// exportedAPI describes every exported declaration of a package, including
+// the exported methods of its named types
+func exportedAPI(pkg *types.Package) map[string]string {
+	api := make(map[string]string)
+	if pkg == nil {
+		return api
+	}
+	qualifier := func(other *types.Package) string {
+		if other.Path() == pkg.Path() {
+			return ""
+		}
+		return other.Path()
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if typeName, ok := obj.(*types.TypeName); ok && !typeName.IsAlias() {
+			named, _ := obj.Type().(*types.Named)
+			for i := 0; named != nil && i < named.NumMethods(); i++ {
+				method := named.Method(i)
+				if method.Exported() {
+					api[name+"."+method.Name()] = types.TypeString(method.Type(), qualifier)
+				}
+			}
+		}
+		if !obj.Exported() {
+			continue
+		}
+
+		switch obj := obj.(type) {
+		case *types.Const:
+			api[name] = "const " + types.TypeString(types.Default(obj.Type()), qualifier) + " = " + obj.Val().ExactString()
+		case *types.Var:
+			api[name] = "var " + types.TypeString(obj.Type(), qualifier)
+		case *types.Func:
+			api[name] = "func " + types.TypeString(obj.Type(), qualifier)
+		case *types.TypeName:
+			api[name] = "type " + types.TypeString(obj.Type().Underlying(), qualifier)
+		}
+	}
+	return api
+}
+
+// compareAPI records exported declarations that went missing or changed
+func compareAPI(result *PackageRoundtrip, original, regenerated map[string]string) {
+	names := make([]string, 0, len(original))
+	for name := range original {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result.APITotal = len(original)
+	for _, name := range names {
+		got, ok := regenerated[name]
+		switch {
+		case !ok:
+			result.APIDifferences = append(result.APIDifferences, APIDifference{Name: name, Change: "missing", Original: original[name]})
+		case got != original[name]:
+			result.APIDifferences = append(result.APIDifferences, APIDifference{Name: name, Change: "changed", Original: original[name], Regenerated: got})
+		default:
+			result.APIMatched++
+		}
+	}
+
+	var added []string
+	for name := range regenerated {
+		if _, ok := original[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		result.APIDifferences = append(result.APIDifferences, APIDifference{Name: name, Change: "added", Regenerated: regenerated[name]})
+	}
+}
+
+// compareStatements records, per function, the statements that are missing
+// from the re-extracted spec of the regenerated source
+func compareStatements(result *PackageRoundtrip, original, regenerated []*codespec.Spec) {
+	regeneratedFuncs := make(map[string]*codespec.Function)
+	for _, spec := range regenerated {
+		for i := range spec.Functions {
+			regeneratedFuncs[filepath.Base(spec.File)+":"+functionKey(&spec.Functions[i])] = &spec.Functions[i]
+		}
+	}
+
+	for _, spec := range original {
+		file := filepath.Base(spec.File)
+		for i := range spec.Functions {
+			fn := &spec.Functions[i]
+			want := statementSignatures(fn.BodyStatements)
+			var got []string
+			if other, ok := regeneratedFuncs[file+":"+functionKey(fn)]; ok {
+				got = statementSignatures(other.BodyStatements)
+			}
+
+			remaining := make(map[string]int)
+			for _, sig := range got {
+				remaining[sig]++
+			}
+			var lost []string
+			for _, sig := range want {
+				if remaining[sig] > 0 {
+					remaining[sig]--
+					continue
+				}
+				lost = append(lost, sig)
+			}
+
+			result.StatementsTotal += len(want)
+			result.StatementsKept += len(want) - len(lost)
+			if len(lost) > 0 {
+				result.FunctionLosses = append(result.FunctionLosses, FunctionFidelity{
+					File:        file,
+					Function:    functionKey(fn),
+					Original:    len(want),
+					Regenerated: len(got),
+					Lost:        lost,
+				})
+			}
+		}
+	}
+}
+
+// functionKey identifies a function by receiver type and name
+func functionKey(fn *codespec.Function) string {
+	if fn.Receiver != nil {
+		return "(" + fn.Receiver.Type + ")." + fn.Name
+	}
+	return fn.Name
+}
+
+// statementSignatures flattens statements into comparable signatures made of
+// type, condition and whitespace-normalized code
+func statementSignatures(statements []codespec.Statement) []string {
+	var sigs []string
+	codespec.Walk(statements, func(st *codespec.Statement) {
+		sig := st.Type
+		if st.Condition != "" {
+			sig += " " + strings.Join(strings.Fields(st.Condition), " ")
+		}
+		if st.Code != "" {
+			sig += ": " + strings.Join(strings.Fields(st.Code), " ")
+		}
+		sigs = append(sigs, sig)
+	})
+	return sigs
+}
+
+// ratio returns matched/total, treating an empty total as a perfect score
+func ratio(matched, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(matched) / float64(total)
+}
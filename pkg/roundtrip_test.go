@@ -0,0 +1,51 @@
+package deepspec
+
+import "testing"
+
+func TestRoundtripReportHasErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages []PackageRoundtrip
+		want     bool
+	}{
+		{name: "clean", packages: []PackageRoundtrip{{Fidelity: 1}, {Fidelity: 0.5}}, want: false},
+		{name: "compile error", packages: []PackageRoundtrip{{Fidelity: 1}, {Fidelity: 1, CompileErrors: []string{"a.go:1:1: undefined: T"}}}, want: true},
+		{name: "generate error", packages: []PackageRoundtrip{{Fidelity: 1, GenerateErrors: []string{"a.go: format"}}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &RoundtripReport{Packages: tt.packages}
+			if got := report.HasErrors(); got != tt.want {
+				t.Errorf("HasErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundtripFailsOnCompileErrors(t *testing.T) {
+	// Aliases come back as defined types, so regenerated code calling
+	// strings.ToUpper with the alias no longer type-checks
+	dir := writeGoModule(t, map[string]string{"alias.go": `package alias
+
+import "strings"
+
+// Name is a plain string
+type Name = string
+
+// Upper upper-cases a name
+func Upper(n Name) string {
+	return strings.ToUpper(n)
+}
+`})
+
+	report, err := Roundtrip(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.HasErrors() {
+		t.Skip("the alias round trip type-checks now; pick another fixture")
+	}
+	if report.Passed {
+		t.Errorf("roundtrip with compile errors passed at fidelity %v", report.Fidelity)
+	}
+}
@@ -0,0 +1,1017 @@
+package deepspec
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// RustExtractor extracts Rust files of a Cargo package
+type RustExtractor struct {
+	root    string
+	crate   string
+	version string
+}
+
+// NewRustExtractor creates an extractor for the Cargo package at root. The
+// crate name and rust-version come from its Cargo.toml.
+func NewRustExtractor(root string) (*RustExtractor, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	e := &RustExtractor{root: root, crate: "crate"}
+	if data, err := os.ReadFile(filepath.Join(root, "Cargo.toml")); err == nil {
+		section := ""
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "[") {
+				section = line
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok || section != "[package]" {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"'`)
+			switch strings.TrimSpace(key) {
+			case "name":
+				e.crate = strings.ReplaceAll(value, "-", "_")
+			case "rust-version":
+				e.version = value
+			}
+		}
+	}
+	return e, nil
+}
+
+// Language returns codespec.LanguageRust
+func (e *RustExtractor) Language() string {
+	return codespec.LanguageRust
+}
+
+// Match reports whether path is a .rs file
+func (e *RustExtractor) Match(path string) bool {
+	return strings.HasSuffix(path, ".rs")
+}
+
+// Extract builds the spec of a Rust file
+func (e *RustExtractor) Extract(path string) (*codespec.Spec, error) {
+	rel, err := relativeSource(e.root, path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := extractRustFile(rel, rustModuleName(e.crate, rel), src)
+	spec.LanguageVersion = e.version
+	return spec, nil
+}
+
+// rustModuleName derives the module path of a file: src/lib.rs is the crate
+// itself and src/net/tcp.rs or src/net/tcp/mod.rs is crate::net::tcp
+func rustModuleName(crate, file string) string {
+	parts := strings.Split(strings.TrimSuffix(file, ".rs"), "/")
+	if parts[0] == "src" {
+		parts = parts[1:]
+	}
+	if last := len(parts) - 1; last >= 0 && (parts[last] == "mod" || (last == 0 && (parts[0] == "lib" || parts[0] == "main"))) {
+		parts = parts[:last]
+	}
+	return strings.Join(append([]string{crate}, parts...), "::")
+}
+
+// rustParser turns the tokens of a Rust file into a spec
+type rustParser struct {
+	tokenStream
+	spec       *codespec.Spec
+	complexity int
+}
+
+// extractRustFile builds the spec for a single Rust file
+func extractRustFile(file, module string, src []byte) *codespec.Spec {
+	tokens, header := tokenize(src, dialectRust)
+	p := &rustParser{
+		tokenStream: tokenStream{src: src, tokens: tokens},
+		spec: &codespec.Spec{
+			SpecVersion: codespec.Version,
+			Language:    codespec.LanguageRust,
+			Module:      module,
+			File:        file,
+			Description: header,
+		},
+	}
+	for i := 0; i < len(p.tokens); {
+		i = p.item(i)
+	}
+	p.spec.Metadata = lineMetrics(src)
+	p.spec.Metadata.CyclomaticComplexity = p.complexity
+	return p.spec
+}
+
+// rustItemHead holds what precedes an item: its doc comment, attributes
+// and visibility
+type rustItemHead struct {
+	doc        string
+	attributes []string
+	derives    []string
+	visibility string
+	next       int
+}
+
+// head parses the doc comment, outer attributes and visibility at i
+func (p *rustParser) head(i int) rustItemHead {
+	h := rustItemHead{doc: p.tok(i).doc, visibility: codespec.VisibilityPrivate}
+	for p.is(i, "#") {
+		if p.is(i+1, "!") {
+			// Inner attribute of the enclosing item
+			i = p.match(i+2) + 1
+			continue
+		}
+		close := p.match(i + 1)
+		attr := p.source(i+2, close-1)
+		if derive, ok := strings.CutPrefix(attr, "derive"); ok {
+			for _, name := range strings.Split(strings.Trim(strings.TrimSpace(derive), "()"), ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					h.derives = append(h.derives, name)
+				}
+			}
+		} else {
+			h.attributes = append(h.attributes, attr)
+		}
+		i = close + 1
+		if h.doc == "" {
+			h.doc = p.tok(i).doc
+		}
+	}
+	if p.is(i, "pub") {
+		h.visibility = codespec.VisibilityPublic
+		i++
+		if p.is(i, "(") {
+			// pub(crate), pub(super) and pub(in path) stay inside the crate
+			h.visibility = codespec.VisibilityInternal
+			i = p.match(i) + 1
+		}
+	}
+	h.next = i
+	return h
+}
+
+// cfgTest reports whether the item is compiled for tests only
+func (h rustItemHead) cfgTest() bool {
+	for _, attr := range h.attributes {
+		if strings.ReplaceAll(attr, " ", "") == "cfg(test)" {
+			return true
+		}
+	}
+	return false
+}
+
+// item parses the module level item starting at i and returns the index of
+// the next one
+func (p *rustParser) item(i int) int {
+	h := p.head(i)
+	i = h.next
+	if h.cfgTest() {
+		return p.itemEnd(i) + 1
+	}
+
+	// Qualifiers in front of fn, trait and impl
+	var qualifiers []string
+	for {
+		switch {
+		case p.is(i, "default") || p.is(i, "async") || p.is(i, "unsafe") || p.is(i, "auto"):
+			qualifiers = append(qualifiers, p.tok(i).text)
+			i++
+			continue
+		case p.is(i, "const") && (p.is(i+1, "fn") || p.is(i+1, "unsafe") || p.is(i+1, "async")):
+			qualifiers = append(qualifiers, "const")
+			i++
+			continue
+		case p.is(i, "extern") && !p.is(i+1, "crate"):
+			qualifiers = append(qualifiers, "extern")
+			i++
+			if p.tok(i).kind == tokString {
+				i++
+			}
+			continue
+		}
+		break
+	}
+
+	switch {
+	case p.is(i, "use"):
+		end := p.itemEnd(i)
+		imports := p.useTree("", i+1, p.withoutSemicolon(i, end))
+		for n := range imports {
+			if h.visibility != codespec.VisibilityPrivate {
+				setExtra(&imports[n].Extra, "reexport", true)
+			}
+		}
+		p.spec.Imports = append(p.spec.Imports, imports...)
+		return end + 1
+
+	case p.is(i, "extern") && p.is(i+1, "crate"):
+		end := p.itemEnd(i)
+		imp := codespec.Import{Path: p.tok(i + 2).text}
+		if p.is(i+3, "as") {
+			imp.Alias = p.tok(i + 4).text
+		}
+		p.spec.Imports = append(p.spec.Imports, imp)
+		return end + 1
+
+	case p.is(i, "struct") || p.is(i, "union"):
+		typ, next := p.structDecl(i)
+		p.addType(typ, h)
+		return next
+
+	case p.is(i, "enum"):
+		typ, next := p.enumDecl(i)
+		p.addType(typ, h)
+		return next
+
+	case p.is(i, "trait"):
+		typ, methods, next := p.traitDecl(i)
+		p.addType(typ, h)
+		p.spec.Functions = append(p.spec.Functions, methods...)
+		return next
+
+	case p.is(i, "impl"):
+		return p.implBlock(i)
+
+	case p.is(i, "fn"):
+		fn, next := p.function(i, "")
+		fn.Visibility = h.visibility
+		fn.Documentation = h.doc
+		setExtra(&fn.Extra, "qualifiers", qualifiers)
+		setExtra(&fn.Extra, "attributes", h.attributes)
+		p.spec.Functions = append(p.spec.Functions, fn)
+		return next
+
+	case p.is(i, "const") || p.is(i, "static"):
+		return p.valueDecl(i, h)
+
+	case p.is(i, "type") && p.isIdent(i+1):
+		end := p.itemEnd(i)
+		typ := codespec.TypeDefinition{Kind: codespec.KindTypeAlias, Name: p.tok(i + 1).text}
+		if eq := p.indexOf(i, "="); eq < end {
+			typ.Fields = []codespec.Field{{Type: p.source(eq+1, p.withoutSemicolon(i, end)), Visibility: h.visibility}}
+		}
+		p.addType(typ, h)
+		return end + 1
+	}
+
+	// Modules, macros and anything else are skipped as a whole
+	return p.itemEnd(i) + 1
+}
+
+// itemEnd returns the index of the last token of the item at i: its
+// semicolon or the brace closing its body. An unmatched closing brace at i
+// is an item of its own, so callers always advance.
+func (p *rustParser) itemEnd(i int) int {
+	for j := i; j < len(p.tokens); j++ {
+		switch {
+		case p.is(j, ";"):
+			return j
+		case p.is(j, "{"):
+			close := p.match(j)
+			if p.is(close+1, ";") {
+				close++
+			}
+			return close
+		case p.is(j, "(") || p.is(j, "["):
+			j = p.match(j)
+		case p.is(j, "}"):
+			return max(j-1, i)
+		}
+	}
+	return len(p.tokens) - 1
+}
+
+// addType adds a type definition with the attributes of its head
+func (p *rustParser) addType(typ codespec.TypeDefinition, h rustItemHead) {
+	typ.Visibility = h.visibility
+	typ.Documentation = h.doc
+	setExtra(&typ.Extra, "derives", h.derives)
+	setExtra(&typ.Extra, "attributes", h.attributes)
+	p.spec.Types = append(p.spec.Types, typ)
+}
+
+// useTree expands a use declaration into one import per path
+func (p *rustParser) useTree(prefix string, from, to int) []codespec.Import {
+	path := prefix
+	for j := from; j <= to; j++ {
+		switch {
+		case p.is(j, "{"):
+			close := p.match(j)
+			var imports []codespec.Import
+			for _, part := range p.split(j+1, close-1, ",") {
+				if part[0] <= part[1] {
+					imports = append(imports, p.useTree(path, part[0], part[1])...)
+				}
+			}
+			return imports
+		case p.is(j, "as"):
+			return []codespec.Import{{Path: strings.TrimSuffix(path, "::self"), Alias: p.tok(j + 1).text}}
+		default:
+			path += p.tok(j).text
+		}
+	}
+	return []codespec.Import{{Path: strings.TrimSuffix(path, "::self")}}
+}
+
+// skipGenerics skips a generic parameter list at i
+func (p *rustParser) skipGenerics(i int) int {
+	if p.is(i, "<") {
+		return p.matchAngle(i) + 1
+	}
+	return i
+}
+
+// skipWhere skips a where clause at i, stopping at the body or semicolon
+func (p *rustParser) skipWhere(i int) int {
+	if !p.is(i, "where") {
+		return i
+	}
+	for i < len(p.tokens) && !p.is(i, "{") && !p.is(i, ";") {
+		if p.is(i, "(") || p.is(i, "[") {
+			i = p.match(i)
+		}
+		i++
+	}
+	return i
+}
+
+// typeEnd returns the index of the last token of the type starting at i
+func (p *rustParser) typeEnd(i int) int {
+	last, angle := i-1, 0
+	for j := i; j < len(p.tokens); j++ {
+		t := p.tokens[j]
+		if t.kind == tokIdent && angle == 0 && j > i && (t.text == "where" || t.text == "for") {
+			return last
+		}
+		if t.kind == tokPunct {
+			switch t.text {
+			case "(", "[":
+				j = p.match(j)
+				last = j
+				continue
+			case "<":
+				angle++
+			case ">":
+				if angle == 0 {
+					return last
+				}
+				angle--
+			case "{", ";", ",", "=", ")", "]", "}":
+				if angle == 0 {
+					return last
+				}
+			}
+		}
+		last = j
+	}
+	return last
+}
+
+// structDecl parses a struct or union with named or tuple fields
+func (p *rustParser) structDecl(i int) (codespec.TypeDefinition, int) {
+	typ := codespec.TypeDefinition{Kind: codespec.KindStruct, Name: p.tok(i + 1).text}
+	if p.is(i, "union") {
+		typ.Kind = codespec.KindUnion
+	}
+	j := p.skipWhere(p.skipGenerics(i + 2))
+	switch {
+	case p.is(j, "{"):
+		close := p.match(j)
+		typ.Fields = p.fields(j+1, close-1)
+		return typ, close + 1
+	case p.is(j, "("):
+		close := p.match(j)
+		for n, part := range p.split(j+1, close-1, ",") {
+			if part[0] > part[1] {
+				continue
+			}
+			h := p.head(part[0])
+			typ.Fields = append(typ.Fields, codespec.Field{
+				Name:          strconv.Itoa(n),
+				Type:          p.source(h.next, part[1]),
+				Visibility:    h.visibility,
+				Documentation: h.doc,
+			})
+		}
+		return typ, p.itemEnd(close+1) + 1
+	}
+	return typ, p.itemEnd(j) + 1
+}
+
+// fields parses named fields between tokens from and to
+func (p *rustParser) fields(from, to int) []codespec.Field {
+	var fields []codespec.Field
+	for _, part := range p.split(from, to, ",") {
+		if part[0] > part[1] {
+			continue
+		}
+		h := p.head(part[0])
+		if !p.is(h.next+1, ":") {
+			continue
+		}
+		field := codespec.Field{
+			Name:          p.tok(h.next).text,
+			Type:          p.source(h.next+2, part[1]),
+			Visibility:    h.visibility,
+			Documentation: h.doc,
+		}
+		setExtra(&field.Extra, "attributes", h.attributes)
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// enumDecl parses an enum. Variants become fields whose type is their
+// payload, empty for unit variants.
+func (p *rustParser) enumDecl(i int) (codespec.TypeDefinition, int) {
+	typ := codespec.TypeDefinition{Kind: codespec.KindEnum, Name: p.tok(i + 1).text}
+	open := p.skipWhere(p.skipGenerics(i + 2))
+	close := p.match(open)
+	for _, part := range p.split(open+1, close-1, ",") {
+		if part[0] > part[1] {
+			continue
+		}
+		h := p.head(part[0])
+		field := codespec.Field{Name: p.tok(h.next).text, Visibility: codespec.VisibilityPublic, Documentation: h.doc}
+		switch j := h.next + 1; {
+		case p.is(j, "(") || p.is(j, "{"):
+			field.Type = p.source(j, p.match(j))
+		case p.is(j, "="):
+			setExtra(&field.Extra, "default", p.source(j+1, part[1]))
+		}
+		setExtra(&field.Extra, "attributes", h.attributes)
+		typ.Fields = append(typ.Fields, field)
+	}
+	return typ, close + 1
+}
+
+// traitDecl parses a trait into an interface. Provided methods also become
+// functions with the trait as receiver.
+func (p *rustParser) traitDecl(i int) (codespec.TypeDefinition, []codespec.Function, int) {
+	typ := codespec.TypeDefinition{Kind: codespec.KindInterface, Name: p.tok(i + 1).text}
+	j := p.skipGenerics(i + 2)
+	if p.is(j, ":") {
+		var supertraits []string
+		end := j + 1
+		for end < len(p.tokens) && !p.is(end, "{") && !p.is(end, "where") {
+			end++
+		}
+		for _, part := range p.split(j+1, end-1, "+") {
+			supertraits = append(supertraits, p.source(part[0], part[1]))
+		}
+		setExtra(&typ.Extra, "supertraits", supertraits)
+		j = end
+	}
+	open := p.skipWhere(j)
+	close := p.match(open)
+
+	var methods []codespec.Function
+	var associated []string
+	for k := open + 1; k < close; {
+		h := p.head(k)
+		k = h.next
+		for p.is(k, "async") || p.is(k, "unsafe") || p.is(k, "const") && p.is(k+1, "fn") {
+			k++
+		}
+		switch {
+		case p.is(k, "fn"):
+			fn, next := p.function(k, typ.Name)
+			typ.Methods = append(typ.Methods, p.signature(k))
+			if p.is(next-1, "}") {
+				fn.Visibility = codespec.VisibilityPublic
+				fn.Documentation = h.doc
+				methods = append(methods, fn)
+			}
+			k = next
+		case p.is(k, "type"):
+			end := p.itemEnd(k)
+			associated = append(associated, p.source(k+1, p.withoutSemicolon(k, end)))
+			k = end + 1
+		default:
+			k = p.itemEnd(k) + 1
+		}
+	}
+	setExtra(&typ.Extra, "associated_types", associated)
+	return typ, methods, close + 1
+}
+
+// signature renders the signature of the function at i as written
+func (p *rustParser) signature(i int) string {
+	end := i
+	for end < len(p.tokens) && !p.is(end, "{") && !p.is(end, ";") {
+		if p.is(end, "(") || p.is(end, "[") {
+			end = p.match(end)
+		}
+		end++
+	}
+	return strings.Join(strings.Fields(p.source(i+1, end-1)), " ")
+}
+
+// implBlock parses an impl block, adding its functions with the implemented
+// type as receiver
+func (p *rustParser) implBlock(i int) int {
+	j := p.skipGenerics(i + 1)
+	if p.is(j, "!") {
+		j++
+	}
+	first := p.typeEnd(j)
+	trait, self := "", p.source(j, first)
+	if p.is(first+1, "for") {
+		trait = self
+		end := p.typeEnd(first + 2)
+		self = p.source(first+2, end)
+		first = end
+	}
+	open := p.skipWhere(first + 1)
+	if !p.is(open, "{") {
+		return p.itemEnd(open) + 1
+	}
+	close := p.match(open)
+
+	receiver := rustBaseType(self)
+	for k := open + 1; k < close; {
+		h := p.head(k)
+		if h.cfgTest() {
+			k = p.itemEnd(h.next) + 1
+			continue
+		}
+		k = h.next
+		var qualifiers []string
+		for p.is(k, "default") || p.is(k, "async") || p.is(k, "unsafe") || p.is(k, "const") && p.is(k+1, "fn") || p.is(k, "extern") {
+			qualifiers = append(qualifiers, p.tok(k).text)
+			k++
+			if p.tok(k).kind == tokString {
+				k++
+			}
+		}
+		if !p.is(k, "fn") {
+			k = p.itemEnd(k) + 1
+			continue
+		}
+		fn, next := p.function(k, receiver)
+		fn.Visibility = h.visibility
+		if trait != "" {
+			// Trait methods are as visible as the trait
+			fn.Visibility = codespec.VisibilityPublic
+			setExtra(&fn.Extra, "trait", trait)
+		}
+		fn.Documentation = h.doc
+		setExtra(&fn.Extra, "qualifiers", qualifiers)
+		setExtra(&fn.Extra, "attributes", h.attributes)
+		p.spec.Functions = append(p.spec.Functions, fn)
+		k = next
+	}
+	return close + 1
+}
+
+// rustBaseType strips references, paths and generic arguments from a type
+func rustBaseType(typ string) string {
+	typ = strings.TrimLeft(typ, "&")
+	typ = strings.TrimPrefix(strings.TrimSpace(typ), "mut ")
+	if idx := strings.IndexByte(typ, '<'); idx >= 0 {
+		typ = typ[:idx]
+	}
+	if idx := strings.LastIndex(typ, "::"); idx >= 0 {
+		typ = typ[idx+2:]
+	}
+	return strings.TrimSpace(typ)
+}
+
+// function parses the function at the fn keyword i. A non-empty receiver
+// type makes it a method: its self parameter, if any, becomes the receiver
+// name, so associated functions get an unnamed receiver.
+func (p *rustParser) function(i int, receiver string) (codespec.Function, int) {
+	fn := codespec.Function{Name: p.tok(i + 1).text}
+	if receiver != "" {
+		fn.Receiver = &codespec.Receiver{Type: receiver}
+	}
+	j := p.skipGenerics(i + 2)
+	if p.is(j, "(") {
+		close := p.match(j)
+		for _, part := range p.split(j+1, close-1, ",") {
+			if part[0] > part[1] {
+				continue
+			}
+			start := p.head(part[0]).next
+			colon := p.indexOf(start, ":")
+			if colon > part[1] {
+				// self, &self, &'a mut self
+				if fn.Receiver != nil {
+					fn.Receiver.Name = strings.Join(strings.Fields(p.source(start, part[1])), " ")
+				}
+				continue
+			}
+			name := p.source(start, colon-1)
+			if name == "self" || name == "mut self" {
+				// self: Box<Self>
+				if fn.Receiver != nil {
+					fn.Receiver.Name = p.source(start, part[1])
+				}
+				continue
+			}
+			fn.Parameters = append(fn.Parameters, codespec.Parameter{
+				Name: strings.TrimPrefix(name, "mut "),
+				Type: p.source(colon+1, part[1]),
+			})
+		}
+		j = close + 1
+	}
+	if p.is(j, "->") {
+		end := p.typeEnd(j + 1)
+		fn.Returns = []codespec.ReturnValue{{Type: p.source(j+1, end)}}
+		j = end + 1
+	}
+	j = p.skipWhere(j)
+	if p.is(j, "{") {
+		close := p.match(j)
+		fn.BodyStatements = p.statements(j+1, close-1)
+		p.complexity += p.decisions(j+1, close-1)
+		return fn, close + 1
+	}
+	if p.is(j, ";") {
+		j++
+	}
+	return fn, j
+}
+
+// valueDecl parses a const or static item. Consts become constants and
+// statics variables.
+func (p *rustParser) valueDecl(i int, h rustItemHead) int {
+	end := p.itemEnd(i)
+	last := p.withoutSemicolon(i, end)
+	static := p.is(i, "static")
+	j := i + 1
+	mutable := false
+	if p.is(j, "mut") {
+		mutable = true
+		j++
+	}
+	name := p.tok(j).text
+	if name == "_" {
+		return end + 1
+	}
+	typ, value := "", ""
+	if p.is(j+1, ":") {
+		typeEnd := p.typeEnd(j + 2)
+		typ = p.source(j+2, typeEnd)
+		j = typeEnd
+	}
+	if eq := p.indexOf(j, "="); eq <= last {
+		value = p.source(eq+1, last)
+	}
+	exported := h.visibility == codespec.VisibilityPublic
+
+	if !static {
+		p.spec.Constants = append(p.spec.Constants, codespec.Constant{Name: name, Type: typ, Value: codespec.RawValue(rustConstantValue(value)), Doc: h.doc, Exported: codespec.Bool(exported)})
+		return end + 1
+	}
+	variable := codespec.Variable{Name: name, Type: typ, Doc: h.doc, Exported: codespec.Bool(exported), Initialization: map[string]any{"code": value}}
+	if mutable {
+		setExtra(&variable.Extra, "mutable", true)
+	}
+	p.spec.Variables = append(p.spec.Variables, variable)
+	return end + 1
+}
+
+// rustConstantValue converts a literal into its JSON value: strings are
+// decoded, numbers lose their type suffix and anything else stays source
+func rustConstantValue(code string) any {
+	code = strings.TrimSpace(code)
+	switch code {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if strings.HasPrefix(code, `"`) {
+		if s, err := strconv.Unquote(code); err == nil {
+			return s
+		}
+		return code[1 : len(code)-1]
+	}
+	number := strings.ReplaceAll(code, "_", "")
+	for _, suffix := range []string{"i8", "i16", "i32", "i64", "i128", "isize", "u8", "u16", "u32", "u64", "u128", "usize", "f32", "f64"} {
+		if trimmed, ok := strings.CutSuffix(number, suffix); ok && !strings.HasPrefix(number, "0x") {
+			number = trimmed
+			break
+		}
+	}
+	if n, ok := pyNumber(number); ok {
+		return n
+	}
+	return code
+}
+
+// statements converts the statements between tokens from and to. A final
+// expression without semicolon is the block's value and becomes a return.
+func (p *rustParser) statements(from, to int) []codespec.Statement {
+	var statements []codespec.Statement
+	for i := from; i <= to; {
+		st, next, ok := p.statement(i, to)
+		if ok {
+			statements = append(statements, st)
+		}
+		if next <= i {
+			next = i + 1
+		}
+		i = next
+	}
+	return statements
+}
+
+// block converts the block opened at i
+func (p *rustParser) block(i int) ([]codespec.Statement, int) {
+	close := p.match(i)
+	return p.statements(i+1, close-1), close + 1
+}
+
+// openBrace returns the index of the brace opening the body of an if,
+// while, for or match whose head starts at i
+func (p *rustParser) openBrace(i, to int) int {
+	for j := i; j <= to; j++ {
+		switch {
+		case p.is(j, "{"):
+			return j
+		case p.is(j, "(") || p.is(j, "["):
+			j = p.match(j)
+		case p.is(j, "|"):
+			// Closure bodies in the head: |x| { ... }
+			if k := p.indexOf(j+1, "|"); k <= to && p.is(k+1, "{") {
+				j = p.match(k + 1)
+			}
+		}
+	}
+	return to + 1
+}
+
+// statementEnd returns the index of the last token of the statement at i:
+// its semicolon, the brace closing a block-like statement or the last
+// token before to
+func (p *rustParser) statementEnd(i, to int) int {
+	blockLike := false
+	switch p.tok(i).text {
+	case "fn", "struct", "enum", "impl", "trait", "mod", "unsafe", "async", "loop", "while", "for", "if", "match", "macro_rules":
+		blockLike = true
+	}
+	for j := i; j <= to; j++ {
+		switch {
+		case p.is(j, ";"):
+			return j
+		case p.is(j, "{"):
+			close := p.match(j)
+			if blockLike && !p.is(close+1, ".") && !p.is(close+1, "?") && !p.is(close+1, "else") {
+				if p.is(close+1, ";") {
+					close++
+				}
+				return close
+			}
+			j = close
+		case p.is(j, "(") || p.is(j, "["):
+			j = p.match(j)
+		}
+	}
+	return to
+}
+
+// statement converts the statement starting at i and returns the index of
+// the following one
+func (p *rustParser) statement(i, to int) (codespec.Statement, int, bool) {
+	for p.is(i, "#") {
+		i = p.match(i+1) + 1
+	}
+	if p.is(i, ";") {
+		return codespec.Statement{}, i + 1, false
+	}
+	// Loop labels
+	if t := p.tok(i); t.kind == tokIdent && strings.HasPrefix(t.text, "'") && p.is(i+1, ":") {
+		i += 2
+	}
+	skipSemicolon := func(next int) int {
+		if p.is(next, ";") {
+			return next + 1
+		}
+		return next
+	}
+
+	switch p.tok(i).text {
+	case "if":
+		open := p.openBrace(i+1, to)
+		st := codespec.Statement{Type: codespec.StatementIf, Condition: p.source(i+1, open-1)}
+		var next int
+		st.TrueBranch, next = p.block(open)
+		if p.is(next, "else") {
+			if p.is(next+1, "if") {
+				elseIf, after, _ := p.statement(next+1, to)
+				st.FalseBranch = []codespec.Statement{elseIf}
+				next = after
+			} else {
+				st.FalseBranch, next = p.block(next + 1)
+			}
+		}
+		return st, skipSemicolon(next), true
+
+	case "for":
+		open := p.openBrace(i+1, to)
+		st := codespec.Statement{Type: codespec.StatementFor, Operation: "range", Condition: p.source(i+1, open-1)}
+		var next int
+		st.TrueBranch, next = p.block(open)
+		return st, skipSemicolon(next), true
+
+	case "while":
+		open := p.openBrace(i+1, to)
+		st := codespec.Statement{Type: codespec.StatementFor, Operation: "while", Condition: p.source(i+1, open-1)}
+		var next int
+		st.TrueBranch, next = p.block(open)
+		return st, skipSemicolon(next), true
+
+	case "loop":
+		if !p.is(i+1, "{") {
+			break
+		}
+		st := codespec.Statement{Type: codespec.StatementFor, Operation: "loop"}
+		var next int
+		st.TrueBranch, next = p.block(i + 1)
+		return st, skipSemicolon(next), true
+
+	case "match":
+		open := p.openBrace(i+1, to)
+		close := p.match(open)
+		st := codespec.Statement{Type: codespec.StatementSwitch, Condition: p.source(i+1, open-1)}
+		for j := open + 1; j < close; {
+			arrow := p.indexOf(j, "=>")
+			if arrow >= close {
+				break
+			}
+			c := codespec.Case{Type: p.source(j, arrow-1)}
+			j = arrow + 1
+			if p.is(j, "{") {
+				c.Statements, j = p.block(j)
+			} else {
+				end := close - 1
+				if comma := p.indexOf(j, ","); comma < close {
+					end = comma - 1
+				}
+				st, _, ok := p.statement(j, end)
+				if ok {
+					c.Statements = []codespec.Statement{st}
+				}
+				j = end + 1
+			}
+			if p.is(j, ",") {
+				j++
+			}
+			st.Cases = append(st.Cases, c)
+		}
+		return st, skipSemicolon(close + 1), true
+
+	case "return":
+		end := p.statementEnd(i, to)
+		last := p.withoutSemicolon(i, end)
+		return codespec.Statement{Type: codespec.StatementReturn, Code: p.code(i, last), Value: p.source(i+1, last)}, end + 1, true
+
+	case "let":
+		end := p.statementEnd(i, to)
+		last := p.withoutSemicolon(i, end)
+		st := codespec.Statement{Type: codespec.StatementVariableDeclaration, Code: p.code(i, last)}
+		if eq := p.assignmentIndex(i+1, last); eq >= 0 {
+			st.Operation, _ = p.callee(eq+1, last)
+		}
+		return st, end + 1, true
+	}
+
+	end := p.statementEnd(i, to)
+	last := p.withoutSemicolon(i, end)
+	switch p.tok(i).text {
+	case "fn", "struct", "enum", "impl", "trait", "mod", "use", "const", "static", "unsafe", "async", "macro_rules":
+		return codespec.Statement{Type: codespec.StatementFunctionBody, Code: p.code(i, last)}, end + 1, true
+	}
+	if p.is(i, "{") {
+		return codespec.Statement{Type: codespec.StatementFunctionBody, Code: p.code(i, last)}, end + 1, true
+	}
+
+	st := p.expressionStatement(i, last)
+	if end == to && !p.is(end, ";") && st.Type != codespec.StatementAssignment {
+		// Tail expression
+		return codespec.Statement{Type: codespec.StatementReturn, Value: p.source(i, last)}, end + 1, true
+	}
+	return st, end + 1, true
+}
+
+// assignmentIndex returns the index of the top level assignment operator
+// between tokens from and to, or -1
+func (p *rustParser) assignmentIndex(from, to int) int {
+	for j := from; j <= to; j++ {
+		t := p.tok(j)
+		if t.kind != tokPunct {
+			continue
+		}
+		switch {
+		case t.text == "(" || t.text == "[" || t.text == "{":
+			j = p.match(j)
+		case jsAssignmentOperators[t.text]:
+			if prev := p.tok(j - 1); t.text == "=" && (prev.text == ">" || prev.text == "<") && prev.end == t.start {
+				continue
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// callee returns the called path or method when tokens from through to form
+// a single call, possibly awaited or followed by ?, and whether it is awaited
+func (p *rustParser) callee(from, to int) (string, bool) {
+	awaited := false
+	for {
+		switch {
+		case p.is(to, "?"):
+			to--
+			continue
+		case p.is(to, "await") && p.is(to-1, "."):
+			awaited = true
+			to -= 2
+			continue
+		}
+		break
+	}
+	j := from
+	for p.isIdent(j) && (p.is(j+1, "::") || p.is(j+1, ".")) {
+		j += 2
+	}
+	if !p.isIdent(j) {
+		return "", false
+	}
+	end := j
+	open := j + 1
+	if p.is(open, "!") {
+		// Macro invocation
+		end = open
+		open++
+	}
+	if p.is(open, "::") && p.is(open+1, "<") {
+		open = p.matchAngle(open+1) + 1
+	}
+	if !(p.is(open, "(") || p.is(end, "!") && (p.is(open, "[") || p.is(open, "{"))) || p.match(open) != to {
+		return "", false
+	}
+	return p.source(from, end), awaited
+}
+
+// expressionStatement classifies an expression statement
+func (p *rustParser) expressionStatement(from, to int) codespec.Statement {
+	code := p.code(from, to)
+	if eq := p.assignmentIndex(from, to); eq >= 0 {
+		st := codespec.Statement{Type: codespec.StatementAssignment, Code: code}
+		if op := p.tok(eq).text; op != "=" {
+			st.Operation = op
+		} else {
+			st.Operation, _ = p.callee(eq+1, to)
+		}
+		return st
+	}
+
+	st := codespec.Statement{Type: codespec.StatementFunctionBody, Code: code}
+	callee, awaited := p.callee(from, to)
+	if callee != "" {
+		st.Type = codespec.StatementFunctionCall
+		st.Operation = callee
+		if dot := strings.LastIndexByte(callee, '.'); dot >= 0 {
+			st.Type = codespec.StatementMethodCall
+			if method := callee[dot+1:]; method == "push" || method == "extend" || method == "push_str" {
+				st.Type = codespec.StatementAppend
+			}
+		}
+	}
+	if awaited {
+		st.Description = "await"
+	}
+	return st
+}
+
+// decisions counts the decision points between tokens from and to. Every
+// match arm counts, like a case.
+func (p *rustParser) decisions(from, to int) int {
+	count := 1
+	for j := from; j <= to; j++ {
+		t := p.tok(j)
+		switch {
+		case t.kind == tokIdent && (t.text == "if" || t.text == "for" || t.text == "while"):
+			count++
+		case t.kind == tokPunct && (t.text == "=>" || t.text == "&&" || t.text == "||"):
+			count++
+		}
+	}
+	return count
+}
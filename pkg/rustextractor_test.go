@@ -0,0 +1,139 @@
+package deepspec
+
+import (
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+const cartCrate = `//! Shopping cart helpers.
+use std::collections::HashMap;
+use std::fmt::{self, Display};
+
+/// Maximum items per cart.
+pub const MAX_ITEMS: usize = 50;
+static CURRENCY: &str = "EUR";
+
+/// A line item.
+#[derive(Debug, Clone)]
+pub struct Item {
+    pub sku: String,
+    pub(crate) quantity: u32,
+    note: Option<String>,
+}
+
+/// The state of a cart.
+pub enum Status {
+    Open,
+    Closed { reason: String },
+}
+
+/// Something with a price.
+pub trait Priced {
+    fn price(&self) -> u64;
+}
+
+pub struct Cart<'a> {
+    owner: &'a str,
+    items: HashMap<String, Item>,
+}
+
+impl<'a> Cart<'a> {
+    /// Creates an empty cart.
+    pub fn new(owner: &'a str) -> Self {
+        Cart { owner, items: HashMap::new() }
+    }
+
+    pub fn add(&mut self, sku: &str, quantity: u32) -> Result<&Item, String> {
+        if quantity == 0 {
+            return Err("quantity must be positive".to_string());
+        }
+        let item = Item { sku: sku.to_string(), quantity, note: None };
+        Ok(self.items.entry(sku.to_string()).or_insert(item))
+    }
+
+    fn validate(sku: &str) -> bool {
+        !sku.is_empty()
+    }
+
+    pub async fn checkout(self) {}
+}
+
+impl Display for Item {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{} x{}", self.sku, self.quantity)
+    }
+}
+
+pub fn total<T: Priced>(items: &[T]) -> u64 {
+    items.iter().map(|i| i.price()).sum()
+}
+`
+
+func TestRustExtractor(t *testing.T) {
+	files := map[string]string{
+		"Cargo.toml":  "[package]\nname = \"shop\"\nversion = \"0.1.0\"\nrust-version = \"1.70\"\n",
+		"src/cart.rs": cartCrate,
+	}
+	spec := extractFixture(t, codespec.LanguageRust, files, "src/cart.rs")
+
+	checkSpecFacts(t, spec, []specFact{
+		{name: "module", got: func(s *codespec.Spec) any { return []string{s.Module, s.LanguageVersion, s.Description} }, want: `["shop::cart", "1.70", "Shopping cart helpers."]`},
+		{name: "imports", got: func(s *codespec.Spec) any { return s.Imports }, want: `[{"path": "std::collections::HashMap"}, {"path": "std::fmt"}, {"path": "std::fmt::Display"}]`},
+		{name: "struct", got: func(s *codespec.Spec) any { return findType(s, "Item") }, want: `{
+			"name": "Item", "kind": "struct", "visibility": "public", "documentation": "A line item.", "derives": ["Debug", "Clone"],
+			"fields": [
+				{"name": "sku", "type": "String", "visibility": "public"},
+				{"name": "quantity", "type": "u32", "visibility": "internal"},
+				{"name": "note", "type": "Option<String>", "visibility": "private"}
+			]}`},
+		{name: "enum", got: func(s *codespec.Spec) any { return findType(s, "Status").Fields }, want: `[
+			{"name": "Open", "type": "", "visibility": "public"}, {"name": "Closed", "type": "{ reason: String }", "visibility": "public"}]`},
+		{name: "trait", got: func(s *codespec.Spec) any { t := findType(s, "Priced"); return []any{t.Kind, t.Methods} }, want: `["interface", ["price(&self) -> u64"]]`},
+		{name: "lifetime", got: func(s *codespec.Spec) any { return findType(s, "Cart").Fields[0] }, want: `{"name": "owner", "type": "&'a str", "visibility": "private"}`},
+		{name: "associated function", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "new")
+			return []any{fn.Receiver, fn.Documentation, fn.Returns}
+		}, want: `[{"name": "", "type": "Cart"}, "Creates an empty cart.", [{"type": "Self"}]]`},
+		{name: "method", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "add")
+			return []any{fn.Receiver, fn.Parameters, fn.Returns}
+		}, want: `[{"name": "&mut self", "type": "Cart"}, [{"name": "sku", "type": "&str"}, {"name": "quantity", "type": "u32"}], [{"type": "Result<&Item, String>"}]]`},
+		{name: "private method", got: func(s *codespec.Spec) any { return findFunction(s, "validate").Visibility }, want: `"private"`},
+		{name: "async method", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "checkout")
+			return []any{fn.Receiver, fn.Extra}
+		}, want: `[{"name": "self", "type": "Cart"}, {"qualifiers": ["async"]}]`},
+		{name: "trait implementation", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "fmt")
+			return []any{fn.Receiver, fn.Visibility, fn.Extra}
+		}, want: `[{"name": "&self", "type": "Item"}, "public", {"trait": "Display"}]`},
+		{name: "function", got: func(s *codespec.Spec) any {
+			fn := findFunction(s, "total")
+			return []any{fn.Receiver, fn.Parameters, fn.BodyStatements}
+		}, want: `[null, [{"name": "items", "type": "&[T]"}], [{"type": "return", "value": "items.iter().map(|i| i.price()).sum()"}]]`},
+		{name: "constants", got: func(s *codespec.Spec) any { return s.Constants }, want: `[{"name": "MAX_ITEMS", "type": "usize", "value": 50, "doc": "Maximum items per cart.", "exported": true}]`},
+		{name: "statics", got: func(s *codespec.Spec) any { return s.Variables }, want: `[{"name": "CURRENCY", "type": "&str", "initialization": {"code": "\"EUR\""}, "exported": false}]`},
+	})
+}
+
+func TestRustExtractorStrayClosers(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// want are the names of the functions declared
+		want string
+	}{
+		{name: "stray brace", src: "}", want: `[]`},
+		{name: "stray brace after a function", src: "fn f() { } }", want: `["f"]`},
+		{name: "stray brace after an impl", src: "impl A { fn f(&self) {} } }\nfn g() {}", want: `["f", "g"]`},
+		{name: "stray brace in a module", src: "mod m { fn f() {} } }", want: `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSpecFacts(t, extractWithin(t, codespec.LanguageRust, "src/lib.rs", tt.src), []specFact{
+				{name: "functions", got: functionNames, want: tt.want},
+			})
+		})
+	}
+}
@@ -1,38 +1,168 @@
 package deepspec
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// stdioSessionID is the ID mcp-go gives its single stdio session
+const stdioSessionID = "stdio"
+
 // Server represents our MCP server instance
 type Server struct {
 	mcpServer *server.MCPServer
+	resources *specResources
+	config    *Config
 }
 
-// NewServer creates and initializes a new MCP server
-func NewServer() *Server {
-	// Create a new MCP server with tool capabilities
+// NewServer creates and initializes a new MCP server whose spec tools read
+// the configured specs directory
+func NewServer(config *Config) *Server {
+	// Create a new MCP server with tool, resource and prompt capabilities
+	hooks := &server.Hooks{}
 	mcpServer := server.NewMCPServer(
 		"deepspec-server",
 		"1.0.0",
 		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(true),
+		server.WithHooks(hooks),
 	)
 
-	srv := &Server{mcpServer: mcpServer}
-
-	// Register all external tools
+	// Register all external tools and prompts, and publish the specs as resources
 	RegisterExternalTools(mcpServer)
+	RegisterSpecTools(mcpServer, config.Specs)
+	RegisterSpecPrompts(mcpServer, config.Specs, config.Source)
+	resources := RegisterSpecResources(mcpServer, config.Specs)
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		resources.unsubscribe(session.SessionID())
+	})
+
+	srv := &Server{mcpServer: mcpServer, resources: resources, config: config}
 
 	return srv
 }
 
-// Start starts the MCP server on HTTP port 8080
+// Start serves the MCP server over the configured transport: stdio on stdin
+// and stdout, or sse and streamable http listening on the configured address.
+// Each transport answers resource subscriptions before the MCP server sees
+// them, since mcp-go does not handle them.
 func (s *Server) Start() error {
-	log.Println("Starting MCP server on :8080...")
-	httpServer := server.NewStreamableHTTPServer(s.mcpServer)
-	return httpServer.Start(":8080")
+	// Keep the spec resources in sync with the files on disk
+	go s.resources.Watch(context.Background(), SpecWatchInterval)
+
+	// Logs go to stderr, so they never mix with stdio messages
+	transport, addr := s.config.Server.Transport, s.config.Server.Addr
+	switch transport {
+	case TransportStdio:
+		log.Println("Starting MCP server on stdio...")
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+		return s.serveStdio(ctx, os.Stdin, os.Stdout)
+	case TransportSSE:
+		log.Printf("Starting MCP server on %s (sse)...", addr)
+		httpServer := &http.Server{}
+		sseServer := server.NewSSEServer(s.mcpServer, server.WithHTTPServer(httpServer))
+		httpServer.Handler = s.subscriptionHandler(sseServer, func(r *http.Request) string {
+			return r.URL.Query().Get("sessionId")
+		}, func(w http.ResponseWriter, sessionID string, response mcp.JSONRPCMessage) {
+			// Like every sse response, this one goes over the event stream
+			if err := sseServer.SendEventToSession(sessionID, response); err != nil {
+				s.resources.unsubscribe(sessionID)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		})
+		return sseServer.Start(addr)
+	case TransportHTTP:
+		log.Printf("Starting MCP server on %s...", addr)
+		httpServer := &http.Server{}
+		streamableServer := server.NewStreamableHTTPServer(s.mcpServer, server.WithStreamableHTTPServer(httpServer))
+		mux := http.NewServeMux()
+		mux.Handle("/mcp", s.subscriptionHandler(streamableServer, func(r *http.Request) string {
+			return r.Header.Get(server.HeaderKeySessionID)
+		}, func(w http.ResponseWriter, sessionID string, response mcp.JSONRPCMessage) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		}))
+		httpServer.Handler = mux
+		return streamableServer.Start(addr)
+	default:
+		return fmt.Errorf("unknown transport %q, expected %s", transport, strings.Join(Transports, ", "))
+	}
+}
+
+// serveStdio serves the MCP server on in and out until in ends or ctx is done
+func (s *Server) serveStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	out = &lockedWriter{w: out}
+	messages, forward := io.Pipe()
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if response, ok := s.resources.handleSubscription(stdioSessionID, line); ok {
+				data, _ := json.Marshal(response)
+				out.Write(append(data, '\n'))
+			} else if len(line) > 0 {
+				forward.Write(line)
+			}
+			if err != nil {
+				forward.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return server.NewStdioServer(s.mcpServer).Listen(ctx, messages, out)
+}
+
+// subscriptionHandler answers the resource subscriptions posted to an http
+// transport with reply and passes every other request on to next
+func (s *Server) subscriptionHandler(next http.Handler, sessionID func(*http.Request) string, reply func(http.ResponseWriter, string, mcp.JSONRPCMessage)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := sessionID(r)
+		if r.Method != http.MethodPost || id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if response, ok := s.resources.handleSubscription(id, body); ok {
+			reply(w, id, response)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// lockedWriter serializes writes, so whole messages never interleave
+type lockedWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.w.Write(p)
 }
 
 // GetMCPServer returns the underlying MCP server for advanced usage
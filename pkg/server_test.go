@@ -0,0 +1,116 @@
+package deepspec
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestServerTransports(t *testing.T) {
+	for _, transport := range []string{TransportSSE, TransportHTTP} {
+		t.Run(transport, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			config := DefaultConfig()
+			config.Specs = t.TempDir()
+			config.Server.Transport = transport
+			config.Server.Addr = freeAddr(t)
+			writeSpec(t, config.Specs, "a.go", "")
+
+			srv := NewServer(config)
+			started := make(chan error, 1)
+			go func() { started <- srv.Start() }()
+			waitForListener(t, config.Server.Addr, started)
+
+			c := NewMCPClient(config)
+			if err := c.Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+			defer c.client.Close()
+			tools, err := c.ListTools(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, tool := range tools {
+				names = append(names, tool.Name)
+			}
+			for _, want := range []string{"healthz", "get_spec", "list_specs"} {
+				if !slices.Contains(names, want) {
+					t.Errorf("tools = %v, missing %s", names, want)
+				}
+			}
+
+			// Subscriptions are answered before the MCP server sees them
+			updated := make(chan string, 1)
+			c.client.OnNotification(func(notification mcp.JSONRPCNotification) {
+				if notification.Method == mcp.MethodNotificationResourceUpdated {
+					uri, _ := notification.Params.AdditionalFields["uri"].(string)
+					updated <- uri
+				}
+			})
+			uri := specURIOf(t, config.Specs, "a.go")
+			if err := c.client.Subscribe(ctx, mcp.SubscribeRequest{Params: mcp.SubscribeParams{URI: uri}}); err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+			writeSpec(t, config.Specs, "a.go", "Rewritten")
+			if err := srv.resources.Sync(); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-updated:
+				if got != uri {
+					t.Errorf("updated %s, want %s", got, uri)
+				}
+			case <-ctx.Done():
+				t.Error("no resources/updated notification for the subscribed spec")
+			}
+		})
+	}
+}
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// waitForListener waits until a server accepts connections on addr, failing
+// when it stops first
+func waitForListener(t *testing.T, addr string, stopped <-chan error) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-stopped:
+			t.Fatalf("server stopped: %v", err)
+		default:
+		}
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server not listening on %s", addr)
+}
+
+// specURIOf returns the resource URI of a spec written by writeSpec
+func specURIOf(t *testing.T, dir, file string) string {
+	t.Helper()
+	spec, err := NewSpecStore(dir).GetSpec(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SpecURI(spec)
+}
@@ -0,0 +1,194 @@
+package deepspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+	"github.com/commercetools/deepspec/schemas"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// codeSpecSchemaURI identifies the embedded code-spec schema in prompts
+const codeSpecSchemaURI = "schema://code-spec.schema.json"
+
+// RegisterSpecPrompts registers prompts for spec-driven workflows with the
+// MCP server. Specs are read below specsDir and source files below
+// sourceRoot, the directory the specs were extracted from, which prompts
+// cannot read outside of.
+func RegisterSpecPrompts(mcpServer *server.MCPServer, specsDir, sourceRoot string) {
+	store := NewSpecStore(specsDir)
+	root := sourceRoot
+
+	mcpServer.AddPrompt(mcp.NewPrompt("write_spec",
+		mcp.WithPromptDescription("Write the code spec of a source file, or update its existing spec"),
+		mcp.WithArgument("file", mcp.RequiredArgument(), mcp.ArgumentDescription("Source file path, e.g. pkg/tools.go")),
+	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		file := request.Params.Arguments["file"]
+		source, err := sourceResource(root, file)
+		if err != nil {
+			return nil, err
+		}
+
+		instructions := fmt.Sprintf("Write the code spec of %s as a JSON document that validates against the code-spec schema. "+
+			"Capture every import, type, function, variable and constant with its visibility and documentation, and describe "+
+			"function bodies as body_statements. Reply with the JSON document only.", file)
+		resources := []mcp.ResourceContents{source, schemaResource()}
+		if spec, err := store.GetSpec(file); err == nil {
+			instructions += " The file already has the spec below: keep what is still accurate, including properties added by hand, and update the rest."
+			data, err := spec.Marshal()
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, mcp.TextResourceContents{URI: SpecURI(spec), MIMEType: "application/json", Text: string(data)})
+		}
+		return promptResult("Write the spec of "+file, instructions, resources...), nil
+	})
+
+	mcpServer.AddPrompt(mcp.NewPrompt("implement_function",
+		mcp.WithPromptDescription("Implement a function from its spec"),
+		mcp.WithArgument("file", mcp.RequiredArgument(), mcp.ArgumentDescription("Source file path of the spec, e.g. pkg/tools.go")),
+		mcp.WithArgument("name", mcp.RequiredArgument(), mcp.ArgumentDescription("Function name, or Type.Method for methods")),
+	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		file, name := request.Params.Arguments["file"], request.Params.Arguments["name"]
+		spec, err := store.GetSpec(file)
+		if err != nil {
+			return nil, err
+		}
+		functions, err := store.GetFunction(name, spec.File)
+		if err != nil {
+			return nil, err
+		}
+		if len(functions) == 0 {
+			return nil, fmt.Errorf("no function %s in %s", name, spec.File)
+		}
+		text, err := indentedJSON(functions)
+		if err != nil {
+			return nil, err
+		}
+		resources := []mcp.ResourceContents{
+			mcp.TextResourceContents{URI: SpecURI(spec) + "#function/" + name, MIMEType: "application/json", Text: text},
+		}
+
+		// The receiver type tells what state a method works with
+		if fn := &functions[0].Function; fn.Receiver != nil {
+			typeName := strings.TrimSuffix(functionIdentity(fn), "."+fn.Name)
+			types, _ := store.FindType(typeName)
+			for _, typ := range types {
+				if typ.Module != spec.Module {
+					continue
+				}
+				if text, err := indentedJSON(typ); err == nil {
+					uri := SpecURI(&codespec.Spec{Module: typ.Module, File: typ.File}) + "#type/" + typeName
+					resources = append(resources, mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: text})
+				}
+				break
+			}
+		}
+
+		instructions := fmt.Sprintf("Implement %s in %s (%s, module %s) exactly as its spec below describes: same signature and "+
+			"visibility, the documented behavior, and the control flow of its body_statements. Reply with the function source only.",
+			name, spec.File, spec.Language, spec.Module)
+		return promptResult("Implement "+name+" from its spec", instructions, resources...), nil
+	})
+
+	mcpServer.AddPrompt(mcp.NewPrompt("review_against_spec",
+		mcp.WithPromptDescription("Review a source file against its spec"),
+		mcp.WithArgument("file", mcp.RequiredArgument(), mcp.ArgumentDescription("Source file path, e.g. pkg/tools.go")),
+	), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		file := request.Params.Arguments["file"]
+		spec, err := store.GetSpec(file)
+		if err != nil {
+			return nil, err
+		}
+		source, err := sourceResource(root, spec.File)
+		if err != nil {
+			return nil, err
+		}
+		data, err := spec.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		instructions := fmt.Sprintf("Review %s against its spec below. List every place where the code and the spec disagree: "+
+			"missing or extra declarations, signature and visibility changes, behavior that differs from the documented body. "+
+			"For each, say whether the code or the spec should change.", spec.File)
+		return promptResult("Review "+spec.File+" against its spec", instructions,
+			mcp.TextResourceContents{URI: SpecURI(spec), MIMEType: "application/json", Text: string(data)},
+			source,
+		), nil
+	})
+}
+
+// promptResult builds a single user message of instructions followed by the
+// embedded resources
+func promptResult(description, instructions string, resources ...mcp.ResourceContents) *mcp.GetPromptResult {
+	messages := []mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(instructions))}
+	for _, resource := range resources {
+		messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(resource)))
+	}
+	return mcp.NewGetPromptResult(description, messages)
+}
+
+// sourceResource reads a source file relative to the project root
+func sourceResource(root, file string) (mcp.ResourceContents, error) {
+	path, err := projectPath(root, file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.TextResourceContents{URI: "file://" + filepath.ToSlash(path), MIMEType: "text/plain", Text: string(data)}, nil
+}
+
+// projectPath resolves a relative file path below the project root. Absolute
+// paths and paths leaving the root, also through symlinks, are rejected.
+func projectPath(root, file string) (string, error) {
+	if file == "" {
+		return "", errors.New("file is required")
+	}
+	file = filepath.FromSlash(file)
+	if filepath.IsAbs(file) || filepath.VolumeName(file) != "" {
+		return "", fmt.Errorf("file %s must be relative to the project root", file)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	path := resolveExisting(filepath.Join(root, file))
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s is outside the project root", file)
+	}
+	return path, nil
+}
+
+// resolveExisting resolves the symlinks of the longest existing prefix of a
+// clean absolute path
+func resolveExisting(path string) string {
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// schemaResource embeds the code-spec schema
+func schemaResource() mcp.ResourceContents {
+	return mcp.TextResourceContents{URI: codeSpecSchemaURI, MIMEType: "application/schema+json", Text: string(schemas.CodeSpec)}
+}
@@ -0,0 +1,136 @@
+package deepspec
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestProjectPath(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		ok   bool
+	}{
+		{file: "pkg/tools.go", ok: true},
+		{file: "./pkg/../main.go", ok: true},
+		{file: "", ok: false},
+		{file: "/etc/hostname", ok: false},
+		{file: "../secret.txt", ok: false},
+		{file: "pkg/../../secret.txt", ok: false},
+		{file: "escape/secret.txt", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := projectPath(root, tt.file)
+			if (err == nil) != tt.ok {
+				t.Errorf("projectPath(%q) error = %v, want ok %v", tt.file, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestWriteSpecPromptRejectsFilesOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mcpServer := server.NewMCPServer("test", Version, server.WithPromptCapabilities(true))
+	RegisterSpecPrompts(mcpServer, filepath.Join(root, "specs"), root)
+
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{file: "main.go"},
+		{file: "/etc/hostname", wantErr: "must be relative"},
+		{file: "../main.go", wantErr: "outside the project root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			response := getPrompt(t, mcpServer, "write_spec", tt.file)
+			if tt.wantErr == "" {
+				if !strings.Contains(string(response), "package main") {
+					t.Errorf("response does not embed main.go: %s", response)
+				}
+				return
+			}
+			if !strings.Contains(string(response), tt.wantErr) || strings.Contains(string(response), `"result"`) {
+				t.Errorf("response = %s, want error containing %q", response, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReviewPromptReadsSourceRoot(t *testing.T) {
+	project := t.TempDir()
+	source := filepath.Join(project, "service")
+	specs := filepath.Join(project, "specs")
+	for path, content := range map[string]string{
+		filepath.Join(project, "main.go"): "package project\n",
+		filepath.Join(source, "main.go"):  "package service\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(specs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSpec(t, specs, "main.go", "")
+	escape := &codespec.Spec{SpecVersion: "1.0", Language: codespec.LanguageGo, Module: "example.com/m", File: "../main.go"}
+	if err := escape.WriteFile(filepath.Join(specs, "escape.json")); err != nil {
+		t.Fatal(err)
+	}
+	// The server runs from the project, not from the source root
+	t.Chdir(project)
+
+	mcpServer := server.NewMCPServer("test", Version, server.WithPromptCapabilities(true))
+	RegisterSpecPrompts(mcpServer, specs, source)
+
+	if response := getPrompt(t, mcpServer, "review_against_spec", "main.go"); !strings.Contains(response, "package service") {
+		t.Errorf("review does not embed the source below the source root: %s", response)
+	}
+	if response := getPrompt(t, mcpServer, "review_against_spec", "escape.json"); !strings.Contains(response, "outside the project root") {
+		t.Errorf("review of a spec file outside the source root = %s, want error", response)
+	}
+}
+
+// getPrompt gets a prompt for a file argument and returns the JSON-RPC
+// response
+func getPrompt(t *testing.T, mcpServer *server.MCPServer, name, file string) string {
+	t.Helper()
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  string(mcp.MethodPromptsGet),
+		"params":  map[string]any{"name": name, "arguments": map[string]string{"file": file}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := json.Marshal(mcpServer.HandleMessage(context.Background(), request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(response)
+}
@@ -0,0 +1,349 @@
+package deepspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SpecWatchInterval is how often the specs directory is checked for changes
+const SpecWatchInterval = 2 * time.Second
+
+// Spec resource URI templates. A spec is spec://<module>/<file>; the
+// fragments address single functions and types within it.
+const (
+	specFunctionTemplate = "spec://{module}/{+file}#function/{name}"
+	specTypeTemplate     = "spec://{module}/{+file}#type/{name}"
+)
+
+// Resource subscription methods, which mcp-go leaves to the server
+const (
+	methodResourcesSubscribe   = "resources/subscribe"
+	methodResourcesUnsubscribe = "resources/unsubscribe"
+)
+
+// specResources publishes every spec below a directory as an MCP resource,
+// keeps the published list in sync with the files on disk and tells
+// subscribed sessions about changed specs
+type specResources struct {
+	mcpServer *server.MCPServer
+	store     *SpecStore
+	mutex     sync.Mutex
+	// files maps spec file paths to their state at the last sync
+	files map[string]specFile
+	// subscribers maps subscribed URIs to the IDs of their sessions
+	subscribers map[string]map[string]bool
+}
+
+// specFile is the state of a spec file at the last sync
+type specFile struct {
+	modTime time.Time
+	size    int64
+	// uri is the resource URI of the spec, empty if the file is no spec
+	uri string
+}
+
+// RegisterSpecResources publishes the specs below specsDir as resources of
+// the MCP server, together with templates for their functions and types
+func RegisterSpecResources(mcpServer *server.MCPServer, specsDir string) *specResources {
+	r := &specResources{
+		mcpServer:   mcpServer,
+		store:       NewSpecStore(specsDir),
+		files:       make(map[string]specFile),
+		subscribers: make(map[string]map[string]bool),
+	}
+
+	mcpServer.AddResourceTemplate(mcp.NewResourceTemplate(specFunctionTemplate, "Spec function",
+		mcp.WithTemplateDescription("A function or method (Type.Method) of a code spec, with its signature"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), r.readFunction)
+	mcpServer.AddResourceTemplate(mcp.NewResourceTemplate(specTypeTemplate, "Spec type",
+		mcp.WithTemplateDescription("A type definition of a code spec, with the signatures of its methods"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), r.readType)
+
+	if err := r.Sync(); err != nil {
+		LogToFile("Failed to publish spec resources: %v", err)
+	}
+	return r
+}
+
+// SpecURI returns the resource URI of a spec
+func SpecURI(spec *codespec.Spec) string {
+	// Escape ':' too, so module paths such as crate::net stay in the authority
+	module := strings.ReplaceAll(url.PathEscape(spec.Module), ":", "%3A")
+	segments := strings.Split(spec.File, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "spec://" + module + "/" + strings.Join(segments, "/")
+}
+
+// parseSpecURI splits a spec URI into module, file and fragment
+func parseSpecURI(uri string) (module, file, fragment string, err error) {
+	rest, ok := strings.CutPrefix(uri, "spec://")
+	if !ok {
+		return "", "", "", fmt.Errorf("not a spec URI: %s", uri)
+	}
+	rest, fragment, _ = strings.Cut(rest, "#")
+	module, file, ok = strings.Cut(rest, "/")
+	if !ok || file == "" {
+		return "", "", "", fmt.Errorf("spec URI without file: %s", uri)
+	}
+	if module, err = url.PathUnescape(module); err != nil {
+		return "", "", "", err
+	}
+	if file, err = url.PathUnescape(file); err != nil {
+		return "", "", "", err
+	}
+	if fragment, err = url.PathUnescape(fragment); err != nil {
+		return "", "", "", err
+	}
+	return module, file, fragment, nil
+}
+
+// Sync publishes new specs, withdraws deleted ones and notifies subscribers
+// of specs whose files changed since the last sync. Only files whose
+// modification time or size changed are read again. A missing specs
+// directory publishes nothing.
+func (r *specResources) Sync() error {
+	paths, err := FindSpecFiles([]string{r.store.dir})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	previous := make(map[string]bool, len(r.files))
+	for _, file := range r.files {
+		previous[file.uri] = true
+	}
+
+	files := make(map[string]specFile, len(paths))
+	published := make(map[string]bool, len(paths))
+	var added []server.ServerResource
+	var updated []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		file, known := r.files[path]
+		if !known || file.modTime != info.ModTime() || file.size != info.Size() {
+			file = specFile{modTime: info.ModTime(), size: info.Size()}
+			if spec, err := codespec.ReadFile(path); err == nil && spec.File != "" {
+				file.uri = SpecURI(spec)
+				switch {
+				case previous[file.uri]:
+					updated = append(updated, file.uri)
+				case !published[file.uri]:
+					resource := mcp.NewResource(file.uri, spec.File,
+						mcp.WithResourceDescription(fmt.Sprintf("Code spec of %s (%s module %s)", spec.File, spec.Language, spec.Module)),
+						mcp.WithMIMEType("application/json"),
+					)
+					added = append(added, server.ServerResource{Resource: resource, Handler: r.readSpec})
+				}
+			}
+		}
+		files[path] = file
+		if file.uri != "" {
+			published[file.uri] = true
+		}
+	}
+
+	var removed []string
+	for uri := range previous {
+		if uri != "" && !published[uri] {
+			removed = append(removed, uri)
+		}
+	}
+	r.files = files
+
+	// Adding and deleting notify clients that the resource list changed
+	if len(added) > 0 {
+		r.mcpServer.AddResources(added...)
+	}
+	if len(removed) > 0 {
+		r.mcpServer.DeleteResources(removed...)
+	}
+	for _, uri := range updated {
+		r.notifyUpdated(uri)
+	}
+	return nil
+}
+
+// notifyUpdated sends resources/updated to the sessions subscribed to a spec
+// or to one of its functions and types. The caller holds the mutex.
+func (r *specResources) notifyUpdated(specURI string) {
+	for uri, sessions := range r.subscribers {
+		if base, _, _ := strings.Cut(uri, "#"); base != specURI {
+			continue
+		}
+		for sessionID := range sessions {
+			err := r.mcpServer.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+			if err != nil {
+				LogToFile("Failed to notify session %s of %s: %v", sessionID, uri, err)
+			}
+		}
+	}
+}
+
+// handleSubscription answers a resources/subscribe or resources/unsubscribe
+// request of a session, which the MCP server does not handle itself. It
+// reports false for any other message.
+func (r *specResources) handleSubscription(sessionID string, message []byte) (mcp.JSONRPCMessage, bool) {
+	var request struct {
+		ID     any    `json:"id"`
+		Method string `json:"method"`
+		Params struct {
+			URI string `json:"uri"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(message, &request); err != nil || request.ID == nil {
+		return nil, false
+	}
+	subscribe := request.Method == methodResourcesSubscribe
+	if !subscribe && request.Method != methodResourcesUnsubscribe {
+		return nil, false
+	}
+	id := mcp.NewRequestId(request.ID)
+	if _, _, _, err := parseSpecURI(request.Params.URI); err != nil {
+		return mcp.NewJSONRPCError(id, mcp.INVALID_PARAMS, err.Error(), nil), true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	sessions := r.subscribers[request.Params.URI]
+	if subscribe {
+		if sessions == nil {
+			sessions = make(map[string]bool)
+			r.subscribers[request.Params.URI] = sessions
+		}
+		sessions[sessionID] = true
+	} else {
+		delete(sessions, sessionID)
+		if len(sessions) == 0 {
+			delete(r.subscribers, request.Params.URI)
+		}
+	}
+	return mcp.NewJSONRPCResultResponse(id, mcp.EmptyResult{}), true
+}
+
+// unsubscribe drops every subscription of a session
+func (r *specResources) unsubscribe(sessionID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for uri, sessions := range r.subscribers {
+		delete(sessions, sessionID)
+		if len(sessions) == 0 {
+			delete(r.subscribers, uri)
+		}
+	}
+}
+
+// Watch syncs the resources every interval until ctx is done
+func (r *specResources) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Sync(); err != nil {
+				LogToFile("Failed to sync spec resources: %v", err)
+			}
+		}
+	}
+}
+
+// lookup finds the spec a URI refers to
+func (r *specResources) lookup(uri string) (*codespec.Spec, string, error) {
+	module, file, fragment, err := parseSpecURI(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	spec, err := r.store.GetSpec(file)
+	if err != nil {
+		return nil, "", err
+	}
+	if spec.Module != module {
+		return nil, "", fmt.Errorf("no spec for %s in module %s", file, module)
+	}
+	return spec, fragment, nil
+}
+
+// readSpec returns a whole spec
+func (r *specResources) readSpec(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	spec, _, err := r.lookup(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+	data, err := spec.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return jsonResource(request.Params.URI, string(data)), nil
+}
+
+// readFunction returns the functions a #function/{name} URI names
+func (r *specResources) readFunction(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	spec, fragment, err := r.lookup(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(fragment, "function/")
+	matches, err := r.store.GetFunction(name, spec.File)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no function %s in %s", name, spec.File)
+	}
+	return resourceJSON(request.Params.URI, matches)
+}
+
+// readType returns the type a #type/{name} URI names
+func (r *specResources) readType(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	spec, fragment, err := r.lookup(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(fragment, "type/")
+	matches, err := r.store.FindType(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		if match.File == spec.File {
+			return resourceJSON(request.Params.URI, match)
+		}
+	}
+	return nil, fmt.Errorf("no type %s in %s", name, spec.File)
+}
+
+// resourceJSON renders a value as the JSON contents of a resource
+func resourceJSON(uri string, value any) ([]mcp.ResourceContents, error) {
+	text, err := indentedJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResource(uri, text), nil
+}
+
+// jsonResource wraps JSON text as resource contents
+func jsonResource(uri, text string) []mcp.ResourceContents {
+	return []mcp.ResourceContents{mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: text}}
+}
@@ -0,0 +1,185 @@
+package deepspec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// writeSpec writes a spec of a file to the specs directory
+func writeSpec(t *testing.T, dir, file, description string) {
+	t.Helper()
+	spec := &codespec.Spec{SpecVersion: "1.0", Language: codespec.LanguageGo, Module: "example.com/m", File: file, Description: description}
+	if err := spec.WriteFile(filepath.Join(dir, file+".json")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpecResourcesSync(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeSpec(t, dir, "a.go", "")
+
+	mcpServer := server.NewMCPServer("test", Version, server.WithResourceCapabilities(true, true))
+	resources := RegisterSpecResources(mcpServer, dir)
+
+	session := &notificationSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+	if err := mcpServer.RegisterSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.NewInProcessClient(mcpServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION}}); err != nil {
+		t.Fatal(err)
+	}
+	subscription := func(method string) func() {
+		return func() {
+			uri := SpecURI(&codespec.Spec{Module: "example.com/m", File: "a.go"}) + "#function/F"
+			request := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":{"uri":%q}}`, method, uri)
+			if _, ok := resources.handleSubscription(session.SessionID(), []byte(request)); !ok {
+				t.Fatalf("%s not handled", method)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func()
+		want   []string
+		// wantNotifications are the notifications clients receive
+		wantNotifications []string
+	}{
+		{name: "unchanged", change: func() {}, want: []string{"a.go"}},
+		{name: "added", change: func() { writeSpec(t, dir, "b.go", "") }, want: []string{"a.go", "b.go"}, wantNotifications: []string{mcp.MethodNotificationResourcesListChanged}},
+		{name: "rewritten unsubscribed", change: func() { writeSpec(t, dir, "a.go", "Rewritten") }, want: []string{"a.go", "b.go"}},
+		{name: "subscribed", change: subscription(methodResourcesSubscribe), want: []string{"a.go", "b.go"}},
+		{name: "rewritten", change: func() { writeSpec(t, dir, "a.go", "Rewritten again") }, want: []string{"a.go", "b.go"}, wantNotifications: []string{mcp.MethodNotificationResourceUpdated}},
+		{name: "other spec rewritten", change: func() { writeSpec(t, dir, "b.go", "Rewritten") }, want: []string{"a.go", "b.go"}},
+		{name: "unsubscribed", change: subscription(methodResourcesUnsubscribe), want: []string{"a.go", "b.go"}},
+		{name: "rewritten after unsubscribing", change: func() { writeSpec(t, dir, "a.go", "") }, want: []string{"a.go", "b.go"}},
+		{name: "removed", change: func() { os.Remove(filepath.Join(dir, "b.go.json")) }, want: []string{"a.go"}, wantNotifications: []string{mcp.MethodNotificationResourcesListChanged}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			if err := resources.Sync(); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, resource := range result.Resources {
+				names = append(names, resource.Name)
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("resources = %v, want %v", names, tt.want)
+			}
+
+			if got := session.received(); !slices.Equal(got, tt.wantNotifications) {
+				t.Errorf("notifications = %v, want %v", got, tt.wantNotifications)
+			}
+		})
+	}
+}
+
+func TestServeStdioSubscriptions(t *testing.T) {
+	dir := t.TempDir()
+	writeSpec(t, dir, "a.go", "")
+	config := DefaultConfig()
+	config.Specs = dir
+	srv := NewServer(config)
+
+	in, input := io.Pipe()
+	responses, out := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- srv.serveStdio(context.Background(), in, out) }()
+	lines := bufio.NewReader(responses)
+	call := func(id int, method, params string) map[string]any {
+		t.Helper()
+		fmt.Fprintf(input, `{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`+"\n", id, method, params)
+		line, err := lines.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response map[string]any
+		if err := json.Unmarshal(line, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response["id"] != float64(id) {
+			t.Fatalf("response %s, want id %d", line, id)
+		}
+		return response
+	}
+
+	uri := SpecURI(&codespec.Spec{Module: "example.com/m", File: "a.go"})
+	initialize := call(1, string(mcp.MethodInitialize), `{"protocolVersion":"`+mcp.LATEST_PROTOCOL_VERSION+`","capabilities":{},"clientInfo":{"name":"test","version":"1"}}`)
+	capabilities := initialize["result"].(map[string]any)["capabilities"].(map[string]any)["resources"]
+	if capabilities.(map[string]any)["subscribe"] != true {
+		t.Errorf("resource capabilities = %v, want subscribe", capabilities)
+	}
+	if response := call(2, methodResourcesSubscribe, `{"uri":"`+uri+`"}`); response["error"] != nil {
+		t.Errorf("subscribe failed: %v", response["error"])
+	}
+	if response := call(3, methodResourcesSubscribe, `{"uri":"file:///a.go"}`); response["error"] == nil {
+		t.Error("subscribing to a URI that is no spec succeeded")
+	}
+	if response := call(4, string(mcp.MethodResourcesList), `{}`); response["error"] != nil {
+		t.Errorf("other requests no longer reach the server: %v", response["error"])
+	}
+	if sessions := srv.resources.subscribers[uri]; !sessions[stdioSessionID] {
+		t.Errorf("subscribers of %s = %v, want the stdio session", uri, sessions)
+	}
+
+	input.Close()
+	if err := <-done; err != nil {
+		t.Errorf("serveStdio: %v", err)
+	}
+}
+
+// notificationSession is a client session collecting the notifications the
+// server sends to all clients
+type notificationSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *notificationSession) Initialize()       {}
+func (s *notificationSession) Initialized() bool { return true }
+func (s *notificationSession) SessionID() string { return "notifications" }
+
+func (s *notificationSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// received returns the methods of the notifications received so far
+func (s *notificationSession) received() []string {
+	var methods []string
+	for {
+		select {
+		case notification := <-s.notifications:
+			methods = append(methods, notification.Method)
+		default:
+			return methods
+		}
+	}
+}
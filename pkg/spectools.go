@@ -0,0 +1,377 @@
+package deepspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultSpecsDir is the directory spec tools read when none is configured
+const DefaultSpecsDir = "specs"
+
+// defaultSearchLimit caps search_specs results unless a limit is given
+const defaultSearchLimit = 50
+
+// SpecStore reads the specs below a directory. Specs are read on every
+// lookup, so tools always see the files as they are on disk.
+type SpecStore struct {
+	dir string
+}
+
+// NewSpecStore creates a store for the specs below dir
+func NewSpecStore(dir string) *SpecStore {
+	return &SpecStore{dir: dir}
+}
+
+// storedSpec is a spec together with the path it was read from
+type storedSpec struct {
+	path string
+	spec *codespec.Spec
+}
+
+// is reports whether the spec is the one of a source file, also accepting
+// the spec path relative to the specs directory and a leading ./
+func (stored storedSpec) is(file string) bool {
+	file = filepath.ToSlash(strings.TrimPrefix(file, "./"))
+	return stored.spec.File == file || stored.path == file
+}
+
+// load reads every spec in the store, sorted by source file. Files that are
+// not specs are skipped.
+func (s *SpecStore) load() ([]storedSpec, error) {
+	if _, err := os.Stat(s.dir); err != nil {
+		return nil, fmt.Errorf("specs directory: %w", err)
+	}
+	files, err := FindSpecFiles([]string{s.dir})
+	if err != nil {
+		return nil, err
+	}
+	var specs []storedSpec
+	for _, path := range files {
+		spec, err := codespec.ReadFile(path)
+		if err != nil || spec.File == "" {
+			continue
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			rel = path
+		}
+		specs = append(specs, storedSpec{path: filepath.ToSlash(rel), spec: spec})
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].spec.File < specs[j].spec.File })
+	return specs, nil
+}
+
+// specSummary is a list_specs entry
+type specSummary struct {
+	File      string `json:"file"`
+	Path      string `json:"path"`
+	Language  string `json:"language"`
+	Module    string `json:"module"`
+	Types     int    `json:"types"`
+	Functions int    `json:"functions"`
+}
+
+// ListSpecs summarizes every spec, optionally only those of one language
+func (s *SpecStore) ListSpecs(language string) ([]specSummary, error) {
+	specs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	summaries := []specSummary{}
+	for _, stored := range specs {
+		spec := stored.spec
+		if language != "" && spec.Language != language {
+			continue
+		}
+		summaries = append(summaries, specSummary{
+			File:      spec.File,
+			Path:      stored.path,
+			Language:  spec.Language,
+			Module:    spec.Module,
+			Types:     len(spec.Types),
+			Functions: len(spec.Functions),
+		})
+	}
+	return summaries, nil
+}
+
+// GetSpec returns the spec of a source file, also accepting the spec path
+// relative to the specs directory
+func (s *SpecStore) GetSpec(file string) (*codespec.Spec, error) {
+	specs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, stored := range specs {
+		if stored.is(file) {
+			return stored.spec, nil
+		}
+	}
+	return nil, fmt.Errorf("no spec for %s", file)
+}
+
+// functionMatch is a get_function result
+type functionMatch struct {
+	File      string            `json:"file"`
+	Signature string            `json:"signature"`
+	Function  codespec.Function `json:"function"`
+}
+
+// GetFunction finds functions named name, or Type.name for methods, in every
+// spec or only in the spec of file, which GetSpec would accept too
+func (s *SpecStore) GetFunction(name, file string) ([]functionMatch, error) {
+	specs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	matches := []functionMatch{}
+	for _, stored := range specs {
+		if file != "" && !stored.is(file) {
+			continue
+		}
+		for i := range stored.spec.Functions {
+			fn := &stored.spec.Functions[i]
+			if fn.Name == name || functionIdentity(fn) == name {
+				matches = append(matches, functionMatch{File: stored.spec.File, Signature: functionSignature(fn), Function: *fn})
+			}
+		}
+	}
+	return matches, nil
+}
+
+// typeMatch is a find_type result
+type typeMatch struct {
+	File    string                  `json:"file"`
+	Module  string                  `json:"module"`
+	Type    codespec.TypeDefinition `json:"type"`
+	Methods []string                `json:"method_signatures,omitempty"`
+}
+
+// FindType finds type definitions named name together with the signatures
+// of the functions declared on them
+func (s *SpecStore) FindType(name string) ([]typeMatch, error) {
+	specs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	matches := []typeMatch{}
+	for _, stored := range specs {
+		typ := stored.spec.FindType(name)
+		if typ == nil {
+			continue
+		}
+		match := typeMatch{File: stored.spec.File, Module: stored.spec.Module, Type: *typ}
+		// Methods may live in other files of the same module
+		for _, other := range specs {
+			if other.spec.Module != stored.spec.Module || other.spec.Language != stored.spec.Language {
+				continue
+			}
+			for i := range other.spec.Functions {
+				fn := &other.spec.Functions[i]
+				if fn.Receiver != nil && functionIdentity(fn) == name+"."+fn.Name {
+					match.Methods = append(match.Methods, functionSignature(fn))
+				}
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// searchHit is a search_specs result
+type searchHit struct {
+	File   string `json:"file"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SearchSpecs finds modules, types, fields, functions, constants and
+// variables whose name, signature or documentation contains query, ignoring
+// case. At most limit hits are returned.
+func (s *SpecStore) SearchSpecs(query string, limit int) ([]searchHit, error) {
+	specs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+	matches := func(texts ...string) bool {
+		for _, text := range texts {
+			if strings.Contains(strings.ToLower(text), query) {
+				return true
+			}
+		}
+		return false
+	}
+
+	hits := []searchHit{}
+	add := func(hit searchHit) bool {
+		hits = append(hits, hit)
+		return len(hits) < limit
+	}
+	for _, stored := range specs {
+		spec := stored.spec
+		if matches(spec.File, spec.Module, spec.Description) && !add(searchHit{File: spec.File, Kind: "module", Name: spec.Module, Detail: firstLine(spec.Description)}) {
+			return hits, nil
+		}
+		for _, typ := range spec.Types {
+			if matches(typ.Name, typ.Documentation) && !add(searchHit{File: spec.File, Kind: typ.Kind, Name: typ.Name, Detail: firstLine(typ.Documentation)}) {
+				return hits, nil
+			}
+			for _, field := range typ.Fields {
+				if matches(field.Name, field.Documentation) && !add(searchHit{File: spec.File, Kind: "field", Name: typ.Name + "." + field.Name, Detail: field.Type}) {
+					return hits, nil
+				}
+			}
+		}
+		for i := range spec.Functions {
+			fn := &spec.Functions[i]
+			signature := functionSignature(fn)
+			if matches(signature, fn.Documentation, fn.BodySummary) && !add(searchHit{File: spec.File, Kind: "function", Name: functionIdentity(fn), Detail: signature}) {
+				return hits, nil
+			}
+		}
+		for _, constant := range spec.Constants {
+			if matches(constant.Name, constant.Doc) && !add(searchHit{File: spec.File, Kind: "constant", Name: constant.Name, Detail: constant.Type}) {
+				return hits, nil
+			}
+		}
+		for _, variable := range spec.Variables {
+			if matches(variable.Name, variable.Doc) && !add(searchHit{File: spec.File, Kind: "variable", Name: variable.Name, Detail: variable.Type}) {
+				return hits, nil
+			}
+		}
+	}
+	return hits, nil
+}
+
+// firstLine returns the first line of a documentation comment
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+// specValidation is a validate_spec result
+type specValidation struct {
+	Valid      bool              `json:"valid"`
+	Violations []ValidationError `json:"violations"`
+}
+
+// RegisterSpecTools registers the code spec tools with the MCP server. They
+// read the specs below specsDir.
+func RegisterSpecTools(mcpServer *server.MCPServer, specsDir string) {
+	store := NewSpecStore(specsDir)
+
+	mcpServer.AddTool(specTool("list_specs",
+		mcp.WithDescription("List the code specs with their source file, language, module and number of types and functions"),
+		mcp.WithString("language", mcp.Description("Only list specs of this language, e.g. go or python")),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return specToolResult(store.ListSpecs(request.GetString("language", "")))
+	})
+
+	mcpServer.AddTool(specTool("get_spec",
+		mcp.WithDescription("Get the full code spec of a source file"),
+		mcp.WithString("file", mcp.Required(), mcp.Description("Source file path as listed by list_specs, e.g. pkg/tools.go")),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		file, err := request.RequireString("file")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return specToolResult(store.GetSpec(file))
+	})
+
+	mcpServer.AddTool(specTool("get_function",
+		mcp.WithDescription("Get the spec of a function or method: parameters, returns, documentation and body statements"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Function name, or Type.Method for methods")),
+		mcp.WithString("file", mcp.Description("Only search the spec of this source file")),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return specToolResult(store.GetFunction(name, request.GetString("file", "")))
+	})
+
+	mcpServer.AddTool(specTool("find_type",
+		mcp.WithDescription("Find a type definition by name, with its fields and the signatures of its methods"),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Type name")),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return specToolResult(store.FindType(name))
+	})
+
+	mcpServer.AddTool(specTool("search_specs",
+		mcp.WithDescription("Search modules, types, fields, functions, constants and variables by name, signature or documentation"),
+		mcp.WithString("query", mcp.Required(), mcp.Description("Case-insensitive text to search for")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of results"), mcp.DefaultNumber(defaultSearchLimit), mcp.Min(1)),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query, err := request.RequireString("query")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return specToolResult(store.SearchSpecs(query, max(request.GetInt("limit", defaultSearchLimit), 1)))
+	})
+
+	mcpServer.AddTool(specTool("validate_spec",
+		mcp.WithDescription("Validate a code spec document against the code-spec schema"),
+		mcp.WithString("json", mcp.Required(), mcp.Description("The spec document as JSON")),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		document, err := request.RequireString("json")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		violations := ValidateSpec([]byte(document))
+		if violations == nil {
+			violations = []ValidationError{}
+		}
+		return specToolResult(specValidation{Valid: len(violations) == 0, Violations: violations}, nil)
+	})
+}
+
+// specTool creates a spec tool, annotated as only reading the specs
+func specTool(name string, options ...mcp.ToolOption) mcp.Tool {
+	options = append(options,
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+	return mcp.NewTool(name, options...)
+}
+
+// specToolResult renders a tool result as indented JSON, or a tool error
+func specToolResult(value any, err error) (*mcp.CallToolResult, error) {
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	text, err := indentedJSON(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode tool result: %w", err)
+	}
+	return mcp.NewToolResultText(text), nil
+}
+
+// indentedJSON encodes a value as indented JSON, leaving HTML characters in
+// code snippets unescaped
+func indentedJSON(value any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,165 @@
+package deepspec
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// writeSpecStore writes specs of a shop module and a file that is no spec
+func writeSpecStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	cart := &codespec.Spec{
+		SpecVersion: "1.0", Language: codespec.LanguageGo, Module: "example.com/shop", File: "cart/cart.go",
+		Types: []codespec.TypeDefinition{{Name: "Cart", Kind: "struct", Visibility: "public", Documentation: "Cart holds the items of an order."}},
+		Functions: []codespec.Function{
+			{Name: "NewCart", Visibility: "public", Returns: []codespec.ReturnValue{{Type: "*Cart"}}},
+			{Name: "Add", Visibility: "public", Receiver: &codespec.Receiver{Name: "c", Type: "*Cart"}, Documentation: "Add puts an item into the cart.",
+				Parameters: []codespec.Parameter{{Name: "sku", Type: "string"}}, Returns: []codespec.ReturnValue{{Type: "error"}}},
+		},
+	}
+	total := &codespec.Spec{
+		SpecVersion: "1.0", Language: codespec.LanguageGo, Module: "example.com/shop", File: "cart/total.go",
+		Functions: []codespec.Function{{Name: "Total", Visibility: "public", Receiver: &codespec.Receiver{Name: "c", Type: "*Cart"}, Returns: []codespec.ReturnValue{{Type: "int"}}}},
+	}
+	util := &codespec.Spec{
+		SpecVersion: "1.0", Language: codespec.LanguagePython, Module: "shop.util", File: "util.py",
+		Functions: []codespec.Function{{Name: "add", Visibility: "public", Parameters: []codespec.Parameter{{Name: "a", Type: "int"}}}},
+	}
+	for _, spec := range []*codespec.Spec{cart, total, util} {
+		path := filepath.Join(dir, filepath.FromSlash(spec.File)+".json")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := spec.WriteFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{"notes": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestSpecStore(t *testing.T) {
+	store := NewSpecStore(writeSpecStore(t))
+
+	tests := []struct {
+		name    string
+		got     func() (any, error)
+		want    string
+		wantErr bool
+	}{
+		{name: "list specs", got: func() (any, error) { return store.ListSpecs("") }, want: `[
+			{"file": "cart/cart.go", "path": "cart/cart.go.json", "language": "go", "module": "example.com/shop", "types": 1, "functions": 2},
+			{"file": "cart/total.go", "path": "cart/total.go.json", "language": "go", "module": "example.com/shop", "types": 0, "functions": 1},
+			{"file": "util.py", "path": "util.py.json", "language": "python", "module": "shop.util", "types": 0, "functions": 1}]`},
+		{name: "list specs of a language", got: func() (any, error) { return store.ListSpecs("python") }, want: `[
+			{"file": "util.py", "path": "util.py.json", "language": "python", "module": "shop.util", "types": 0, "functions": 1}]`},
+		{name: "get spec", got: func() (any, error) { return specModule(store.GetSpec("./cart/total.go")) }, want: `"example.com/shop"`},
+		{name: "get spec by path", got: func() (any, error) { return specModule(store.GetSpec("util.py.json")) }, want: `"shop.util"`},
+		{name: "get missing spec", got: func() (any, error) { return store.GetSpec("main.go") }, wantErr: true},
+		{name: "get function", got: func() (any, error) { return functionSignatures(store.GetFunction("add", "")) }, want: `["util.py: func add(a int)"]`},
+		{name: "get method", got: func() (any, error) { return functionSignatures(store.GetFunction("Cart.Add", "./cart/cart.go")) }, want: `["cart/cart.go: func (c *Cart) Add(sku string) error"]`},
+		{name: "get function by spec path", got: func() (any, error) { return functionSignatures(store.GetFunction("NewCart", "cart/cart.go.json")) }, want: `["cart/cart.go: func NewCart() *Cart"]`},
+		{name: "get function of another file", got: func() (any, error) { return functionSignatures(store.GetFunction("Add", "cart/total.go")) }, want: `[]`},
+		{name: "find type", got: func() (any, error) { return store.FindType("Cart") }, want: `[{
+			"file": "cart/cart.go", "module": "example.com/shop",
+			"type": {"name": "Cart", "kind": "struct", "visibility": "public", "documentation": "Cart holds the items of an order."},
+			"method_signatures": ["func (c *Cart) Add(sku string) error", "func (c *Cart) Total() int"]}]`},
+		{name: "search", got: func() (any, error) { return store.SearchSpecs("ADD", 10) }, want: `[
+			{"file": "cart/cart.go", "kind": "function", "name": "Cart.Add", "detail": "func (c *Cart) Add(sku string) error"},
+			{"file": "util.py", "kind": "function", "name": "add", "detail": "func add(a int)"}]`},
+		{name: "search limit", got: func() (any, error) { return store.SearchSpecs("cart", 2) }, want: `[
+			{"file": "cart/cart.go", "kind": "module", "name": "example.com/shop"},
+			{"file": "cart/cart.go", "kind": "struct", "name": "Cart", "detail": "Cart holds the items of an order."}]`},
+		{name: "missing directory", got: func() (any, error) { return NewSpecStore(filepath.Join(t.TempDir(), "specs")).ListSpecs("") }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.got()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameJSON(data, []byte(tt.want)) {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+// specModule returns the module of a looked up spec
+func specModule(spec *codespec.Spec, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return spec.Module, nil
+}
+
+// functionSignatures returns the files and signatures of get_function matches
+func functionSignatures(matches []functionMatch, err error) (any, error) {
+	signatures := []string{}
+	for _, match := range matches {
+		signatures = append(signatures, match.File+": "+match.Signature)
+	}
+	return signatures, err
+}
+
+func TestRegisterSpecTools(t *testing.T) {
+	ctx := context.Background()
+	mcpServer := server.NewMCPServer("test", Version, server.WithToolCapabilities(true))
+	RegisterSpecTools(mcpServer, writeSpecStore(t))
+	c, err := client.NewInProcessClient(mcpServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != 6 {
+		t.Errorf("got %d tools, want 6", len(tools.Tools))
+	}
+	for _, tool := range tools.Tools {
+		hints := tool.Annotations
+		if hints.ReadOnlyHint == nil || !*hints.ReadOnlyHint || hints.DestructiveHint == nil || *hints.DestructiveHint || hints.OpenWorldHint == nil || *hints.OpenWorldHint {
+			data, _ := json.Marshal(hints)
+			t.Errorf("%s annotations = %s, want read-only, not destructive, closed world", tool.Name, data)
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "get_function"
+	request.Params.Arguments = map[string]any{"name": "Total", "file": "./cart/total.go"}
+	result, err := c.CallTool(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matches []functionMatch
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &matches); err != nil || len(matches) != 1 {
+		t.Errorf("get_function result = %v (%v), want one match", result.Content, err)
+	}
+}
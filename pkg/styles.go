@@ -19,4 +19,6 @@ var (
 	AssistantBoldStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("252"))
 	AssistantStyle        = lipgloss.NewStyle().Bold(false).Foreground(lipgloss.Color("15"))
 	LoaderStyle           = lipgloss.NewStyle().Foreground(lipgloss.Color(ctYellow)).Bold(true)
+	ToolCallStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color(ctPurple))
+	CancelledStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Italic(true)
 )
@@ -0,0 +1,89 @@
+package deepspec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SystemPrompt holds the base rules of the system prompt when none are
+// configured
+const SystemPrompt = `You are an AI assistant with access to specific function tools. You MUST ONLY provide responses that can be handled by the available user-provided functions.
+
+CRITICAL RULES:
+1. Before responding, verify that an appropriate function exists to handle your response
+2. If no suitable function is available for the user's request, politely explain that you cannot perform that action
+3. Do not make assumptions about available capabilities beyond the provided functions
+4. Always structure your responses to align with the function schemas provided
+5. If asked to perform an action without a corresponding function, decline and suggest what you CAN do instead
+
+Remember: You can only take actions through the functions provided to you. Do not promise or attempt actions for which no function exists.`
+
+// SystemFragment is a part of the system prompt. Fragments after the base
+// rules are shown under their title.
+type SystemFragment struct {
+	Title string
+	Text  string
+}
+
+// SystemPromptFragments loads the fragments of the configured system prompt:
+// the base rules, then the project context and the active spec when set.
+// The base rules are read from system.prompt_file, else taken from
+// system.prompt, else SystemPrompt.
+func SystemPromptFragments(config *Config) ([]SystemFragment, error) {
+	base := SystemFragment{Title: "Base rules", Text: SystemPrompt}
+	switch {
+	case config.System.PromptFile != "":
+		text, err := readPromptFile(config.System.PromptFile)
+		if err != nil {
+			return nil, err
+		}
+		base.Text = text
+	case config.System.Prompt != "":
+		base.Text = strings.TrimSpace(config.System.Prompt)
+	}
+	fragments := []SystemFragment{base}
+
+	if config.System.ContextFile != "" {
+		text, err := readPromptFile(config.System.ContextFile)
+		if err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, SystemFragment{Title: "Project context", Text: text})
+	}
+	if config.System.Spec != "" {
+		text, err := readPromptFile(config.System.Spec)
+		if err != nil {
+			return nil, err
+		}
+		language := strings.TrimPrefix(filepath.Ext(config.System.Spec), ".")
+		fragments = append(fragments, SystemFragment{
+			Title: "Active spec " + filepath.ToSlash(config.System.Spec),
+			Text:  "```" + language + "\n" + text + "\n```",
+		})
+	}
+	return fragments, nil
+}
+
+// ComposeSystemPrompt joins fragments into the system prompt, the fragments
+// after the base rules under a heading of their title
+func ComposeSystemPrompt(fragments []SystemFragment) string {
+	var parts []string
+	for i, fragment := range fragments {
+		if i == 0 {
+			parts = append(parts, fragment.Text)
+			continue
+		}
+		parts = append(parts, "## "+fragment.Title+"\n\n"+fragment.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// readPromptFile reads a prompt fragment without surrounding blank space
+func readPromptFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
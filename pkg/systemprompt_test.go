@@ -0,0 +1,138 @@
+package deepspec
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSystemPromptFragments(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "rules.md"), "\nFile rules.\n\n")
+	writeFile(t, filepath.Join(dir, "context.md"), "The shop module.\n")
+	writeFile(t, filepath.Join(dir, "specs", "shop.json"), `{"file": "shop.go"}`)
+
+	tests := []struct {
+		name      string
+		configure func(system *SystemConfig)
+		want      string
+		wantErr   bool
+	}{
+		{name: "built-in rules", configure: func(system *SystemConfig) {}, want: SystemPrompt},
+		{
+			name:      "prompt",
+			configure: func(system *SystemConfig) { system.Prompt = "  Configured rules.\n" },
+			want:      "Configured rules.",
+		},
+		{
+			name: "prompt file over prompt",
+			configure: func(system *SystemConfig) {
+				system.Prompt = "Configured rules."
+				system.PromptFile = filepath.Join(dir, "rules.md")
+			},
+			want: "File rules.",
+		},
+		{
+			name: "context and spec",
+			configure: func(system *SystemConfig) {
+				system.Prompt = "Rules."
+				system.ContextFile = filepath.Join(dir, "context.md")
+				system.Spec = filepath.Join(dir, "specs", "shop.json")
+			},
+			want: "Rules.\n\n## Project context\n\nThe shop module.\n\n## Active spec " + filepath.ToSlash(filepath.Join(dir, "specs", "shop.json")) +
+				"\n\n```json\n{\"file\": \"shop.go\"}\n```",
+		},
+		{
+			name:      "missing prompt file",
+			configure: func(system *SystemConfig) { system.PromptFile = filepath.Join(dir, "missing.md") },
+			wantErr:   true,
+		},
+		{
+			name:      "missing context file",
+			configure: func(system *SystemConfig) { system.ContextFile = filepath.Join(dir, "missing.md") },
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.configure(&config.System)
+			fragments, err := SystemPromptFragments(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := ComposeSystemPrompt(fragments); got != tt.want {
+				t.Errorf("system prompt:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatModelSystemPrompt(t *testing.T) {
+	tests := []struct {
+		name       string
+		promptFile string
+		want       string
+		wantError  bool
+	}{
+		{name: "override", promptFile: "Project rules.\n", want: "Project rules."},
+		{name: "unreadable override", want: SystemPrompt, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := DefaultConfig()
+			config.Provider = ProviderFake
+			config.Server.Addr = "127.0.0.1:1"
+			config.Fake.Script = filepath.Join(dir, "script.yaml")
+			writeFile(t, config.Fake.Script, "turns:\n  - text: ok\n")
+			config.System.PromptFile = filepath.Join(dir, "rules.md")
+			if tt.promptFile != "" {
+				writeFile(t, config.System.PromptFile, tt.promptFile)
+			}
+
+			model := NewChatModel(config)
+			if model.chat == nil {
+				t.Fatalf("chat not started: %+v", model.viewport.Conversation().Messages())
+			}
+			var errors []string
+			for _, message := range model.viewport.Conversation().Messages() {
+				if message.Role == RoleError {
+					errors = append(errors, message.Text)
+				}
+			}
+			if (len(errors) > 0) != tt.wantError {
+				t.Errorf("errors = %q, want error %v", errors, tt.wantError)
+			}
+
+			// The prompt is the system instruction of the session, not a
+			// prefix of the messages
+			if _, err := model.chat.Send(context.Background(), "hi"); err != nil {
+				t.Fatal(err)
+			}
+			session := model.chat.provider.(*FakeProvider).Sessions()[0]
+			if session.System() != tt.want {
+				t.Errorf("system instruction = %q, want %q", session.System(), tt.want)
+			}
+			if sent := session.Messages(); len(sent) != 1 || sent[0].Text != "hi" {
+				t.Errorf("sent %+v, want hi alone", sent)
+			}
+
+			// /system shows the effective prompt
+			before := model.viewport.Conversation().Len()
+			model.handleCommand("/system")
+			var shown []string
+			for _, message := range model.viewport.Conversation().Messages()[before:] {
+				shown = append(shown, message.Text)
+			}
+			if !slices.Contains(shown, tt.want) || !strings.Contains(strings.Join(shown, "\n"), "Base rules:") {
+				t.Errorf("/system showed %q, want the base rules %q", shown, tt.want)
+			}
+		})
+	}
+}
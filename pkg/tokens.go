@@ -0,0 +1,499 @@
+package deepspec
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind classifies a source token
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokPunct
+	tokString
+	// tokTemplate covers template literals and JSX elements, both kept whole
+	tokTemplate
+	tokNumber
+	tokRegex
+)
+
+// sourceToken is a single token of brace-delimited source. Comments are not
+// tokens; a doc comment directly above a token is attached to it instead.
+type sourceToken struct {
+	kind  tokenKind
+	text  string
+	start int
+	end   int
+	// nl reports a line break between this token and the previous one
+	nl  bool
+	doc string
+}
+
+// tokenDialect selects the lexical rules of a language
+type tokenDialect int
+
+const (
+	dialectScript tokenDialect = iota
+	dialectJSX
+	dialectJava
+	dialectRust
+)
+
+// punctuators lists the multi-character punctuators of each dialect, longest
+// first. None starts with '>' so closing type argument lists always split.
+var punctuators = map[tokenDialect][]string{
+	dialectScript: {
+		"...", "===", "!==", "**=", "&&=", "||=", "??=", "<<=",
+		"=>", "==", "!=", "<=", "&&", "||", "??", "?.", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "**", "<<",
+	},
+	dialectJava: {
+		"...", "<<=",
+		"->", "::", "==", "!=", "<=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<",
+	},
+	dialectRust: {
+		"..=", "...", "<<=",
+		"..", "::", "->", "=>", "==", "!=", "<=", "&&", "||", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<",
+	},
+}
+
+// tokenize splits source into tokens, returning them together with the
+// leading file comment. Script dialects keep JSX elements in expression
+// position and template literals as single tokens. Doc comments are /** */
+// blocks everywhere, runs of // lines in scripts and /// lines in Rust,
+// whose //! lines make up the file comment.
+func tokenize(src []byte, dialect tokenDialect) ([]sourceToken, string) {
+	script := dialect == dialectScript || dialect == dialectJSX
+	puncts := punctuators[dialect]
+	if script {
+		puncts = punctuators[dialectScript]
+	}
+
+	var tokens []sourceToken
+	var header, pending string
+	pendingLine, line := -1, 1
+	pendingIsLine, nl, seenToken := false, true, false
+
+	emit := func(kind tokenKind, start, end int) {
+		t := sourceToken{kind: kind, text: string(src[start:end]), start: start, end: end, nl: nl}
+		if pending != "" && pendingLine >= line-1 {
+			t.doc = pending
+		}
+		tokens = append(tokens, t)
+		pending, nl, seenToken = "", false, true
+		line += strings.Count(t.text, "\n")
+	}
+	comment := func(text string, isLine bool, endLine int) {
+		if !seenToken && header == "" {
+			header = text
+		}
+		if isLine && pendingIsLine && pending != "" && pendingLine == line-1 {
+			pending += "\n" + text
+		} else {
+			pending = text
+		}
+		pendingIsLine, pendingLine = isLine, endLine
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		next := byte(0)
+		if i+1 < len(src) {
+			next = src[i+1]
+		}
+		switch {
+		case c == '\n':
+			line++
+			nl = true
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '/' && next == '/':
+			end := i
+			for end < len(src) && src[end] != '\n' {
+				end++
+			}
+			raw := string(src[i:end])
+			switch {
+			case dialect == dialectRust && strings.HasPrefix(raw, "//!"):
+				if !seenToken {
+					header = strings.TrimSpace(header + "\n" + strings.TrimPrefix(strings.TrimPrefix(raw, "//!"), " "))
+				}
+			case dialect == dialectRust:
+				if strings.HasPrefix(raw, "///") && !strings.HasPrefix(raw, "////") {
+					comment(strings.TrimPrefix(strings.TrimPrefix(raw, "///"), " "), true, line)
+				}
+			case script:
+				text := strings.TrimSpace(raw[2:])
+				if !strings.HasPrefix(text, "eslint") && !strings.HasPrefix(text, "@ts-") && !strings.HasPrefix(text, "/ <reference") {
+					comment(text, true, line)
+				}
+			}
+			i = end
+		case c == '/' && next == '*':
+			end := blockCommentEnd(src, i, dialect == dialectRust)
+			raw := string(src[i:end])
+			line += strings.Count(raw, "\n")
+			switch {
+			case dialect == dialectRust && strings.HasPrefix(raw, "/*!"):
+				if !seenToken {
+					header = blockDocText(raw)
+				}
+			case strings.HasPrefix(raw, "/**") && raw != "/**/":
+				comment(blockDocText(raw), false, line)
+			case !seenToken && header == "":
+				header = blockDocText(raw)
+			case script:
+				pending = ""
+			}
+			i = end
+		case dialect == dialectRust && (c == 'r' || c == 'b') && rustRawStringStart(src, i):
+			end := rustRawStringEnd(src, i)
+			emit(tokString, i, end)
+			i = end
+		case dialect == dialectRust && c == 'b' && (next == '\'' || next == '"'):
+			end := quotedEnd(src, i+1, true)
+			emit(tokString, i, end)
+			i = end
+		case dialect == dialectRust && c == '\'':
+			// Char literal or lifetime
+			if end, ok := rustCharEnd(src, i); ok {
+				emit(tokString, i, end)
+				i = end
+				continue
+			}
+			end := i + 1
+			for end < len(src) && identByte(src[end]) {
+				end++
+			}
+			emit(tokIdent, i, end)
+			i = end
+		case dialect == dialectJava && strings.HasPrefix(string(src[i:min(i+3, len(src))]), `"""`):
+			end := strings.Index(string(src[i+3:]), `"""`)
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 6
+			}
+			emit(tokString, i, end)
+			i = end
+		case c == '"' || c == '\'':
+			end := quotedEnd(src, i, !script)
+			emit(tokString, i, end)
+			i = end
+		case dialect == dialectJSX && c == '<' && (identByte(next) || next == '>') && jsRegexAllowed(tokens):
+			end := jsxElementEnd(src, i)
+			emit(tokTemplate, i, end)
+			i = end
+		case script && c == '`':
+			end := jsTemplateEnd(src, i)
+			emit(tokTemplate, i, end)
+			i = end
+		case isDigit(c) || (c == '.' && isDigit(next) && dialect != dialectRust):
+			end := i + 1
+			for end < len(src) && (identByte(src[end]) || (src[end] == '.' && end+1 < len(src) && src[end+1] != '.' && !(dialect == dialectRust && identByte(src[end+1]) && !isDigit(src[end+1]))) ||
+				((src[end] == '+' || src[end] == '-') && (src[end-1] == 'e' || src[end-1] == 'E') && !strings.HasPrefix(strings.ToLower(string(src[i:end])), "0x"))) {
+				end++
+			}
+			emit(tokNumber, i, end)
+			i = end
+		case identByte(c) || (script && c == '#' && identByte(next)):
+			end := i + 1
+			if dialect == dialectRust && c == 'r' && next == '#' {
+				// Raw identifier r#type
+				end = i + 2
+			}
+			for end < len(src) && identByte(src[end]) {
+				end++
+			}
+			emit(tokIdent, i, end)
+			i = end
+		case script && c == '/' && jsRegexAllowed(tokens):
+			if end := jsRegexEnd(src, i); end > 0 {
+				emit(tokRegex, i, end)
+				i = end
+				continue
+			}
+			emit(tokPunct, i, i+1)
+			i++
+		default:
+			end := i + 1
+			for _, punct := range puncts {
+				if strings.HasPrefix(string(src[i:min(i+len(punct), len(src))]), punct) {
+					end = i + len(punct)
+					break
+				}
+			}
+			// a?.5 is a conditional, not optional chaining
+			if string(src[i:end]) == "?." && end < len(src) && isDigit(src[end]) {
+				end = i + 1
+			}
+			emit(tokPunct, i, end)
+			i = end
+		}
+	}
+	return tokens, header
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// identByte reports whether c can be part of an identifier. Bytes of
+// multi-byte characters count as identifier bytes.
+func identByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// quotedEnd returns the offset just past the quoted literal starting at i.
+// Unless multiline is set, a line break ends an unterminated literal.
+func quotedEnd(src []byte, i int, multiline bool) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '\n':
+			if !multiline {
+				return j
+			}
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// blockCommentEnd returns the offset just past the block comment starting
+// at i. Rust block comments nest.
+func blockCommentEnd(src []byte, i int, nested bool) int {
+	depth := 0
+	for j := i; j+1 < len(src); j++ {
+		switch {
+		case src[j] == '/' && src[j+1] == '*' && (nested || depth == 0):
+			depth++
+			j++
+		case src[j] == '*' && src[j+1] == '/':
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(src)
+}
+
+// rustRawStringStart reports whether a raw string literal (r"", r#""#,
+// br"") starts at i
+func rustRawStringStart(src []byte, i int) bool {
+	j := i
+	if src[j] == 'b' {
+		j++
+	}
+	if j >= len(src) || src[j] != 'r' || (i > 0 && identByte(src[i-1])) {
+		return false
+	}
+	j++
+	for j < len(src) && src[j] == '#' {
+		j++
+	}
+	return j < len(src) && src[j] == '"'
+}
+
+// rustRawStringEnd returns the offset just past the raw string at i
+func rustRawStringEnd(src []byte, i int) int {
+	j := i + 1
+	if src[i] == 'b' {
+		j++
+	}
+	hashes := 0
+	for ; j < len(src) && src[j] == '#'; j++ {
+		hashes++
+	}
+	closing := `"` + strings.Repeat("#", hashes)
+	end := strings.Index(string(src[j+1:]), closing)
+	if end < 0 {
+		return len(src)
+	}
+	return j + 1 + end + len(closing)
+}
+
+// rustCharEnd returns the offset just past the char literal at i, or false
+// when the quote starts a lifetime
+func rustCharEnd(src []byte, i int) (int, bool) {
+	if i+1 >= len(src) {
+		return len(src), true
+	}
+	if src[i+1] == '\\' {
+		return quotedEnd(src, i, false), true
+	}
+	_, size := utf8.DecodeRune(src[i+1:])
+	if end := i + 1 + size; end < len(src) && src[end] == '\'' {
+		return end + 1, true
+	}
+	return 0, false
+}
+
+// blockDocText strips the comment markers and leading asterisks of a block comment
+func blockDocText(raw string) string {
+	raw = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(raw, "/*"), "*"), "!"), "*/")
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "*" {
+			line = ""
+		} else if rest, ok := strings.CutPrefix(line, "* "); ok {
+			line = rest
+		}
+		lines[i] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// tokenStream gives positional access to the tokens of a source file
+type tokenStream struct {
+	src    []byte
+	tokens []sourceToken
+}
+
+// tok returns the token at i, or an empty token past the end
+func (s *tokenStream) tok(i int) sourceToken {
+	if i < 0 || i >= len(s.tokens) {
+		return sourceToken{kind: -1, start: len(s.src), end: len(s.src)}
+	}
+	return s.tokens[i]
+}
+
+// is reports whether the token at i is the punctuator or identifier text
+func (s *tokenStream) is(i int, text string) bool {
+	t := s.tok(i)
+	return (t.kind == tokPunct || t.kind == tokIdent) && t.text == text
+}
+
+// isIdent reports whether the token at i is an identifier
+func (s *tokenStream) isIdent(i int) bool {
+	return s.tok(i).kind == tokIdent
+}
+
+// match returns the index of the bracket closing the one at i
+func (s *tokenStream) match(i int) int {
+	depth := 0
+	for j := i; j < len(s.tokens); j++ {
+		if s.tokens[j].kind != tokPunct {
+			continue
+		}
+		switch s.tokens[j].text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(s.tokens) - 1
+}
+
+// matchAngle returns the index of the '>' closing the type argument or
+// parameter list opened at i
+func (s *tokenStream) matchAngle(i int) int {
+	depth := 0
+	for j := i; j < len(s.tokens); j++ {
+		switch t := s.tokens[j]; {
+		case t.kind != tokPunct:
+		case t.text == "(" || t.text == "[" || t.text == "{":
+			j = s.match(j)
+		case t.text == "<":
+			depth++
+		case t.text == ">":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		case t.text == ";" || t.text == ")" || t.text == "]" || t.text == "}":
+			return j - 1
+		}
+	}
+	return len(s.tokens) - 1
+}
+
+// source returns the source text spanning tokens from through to
+func (s *tokenStream) source(from, to int) string {
+	if to < from {
+		return ""
+	}
+	return string(s.src[s.tok(from).start:s.tok(to).end])
+}
+
+// code returns the source of tokens from through to, with continuation
+// lines dedented to the column of the first token
+func (s *tokenStream) code(from, to int) string {
+	text := s.source(from, to)
+	start := s.tok(from).start
+	column := start - (strings.LastIndexByte(string(s.src[:start]), '\n') + 1)
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		trim := 0
+		for trim < column && trim < len(lines[i]) && (lines[i][trim] == ' ' || lines[i][trim] == '\t') {
+			trim++
+		}
+		lines[i] = strings.TrimRight(lines[i][trim:], " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// withoutSemicolon drops a trailing semicolon from a token range
+func (s *tokenStream) withoutSemicolon(from, to int) int {
+	if to >= from && s.is(to, ";") {
+		return to - 1
+	}
+	return to
+}
+
+// split splits the tokens from through to at top level separators,
+// returning inclusive index ranges
+func (s *tokenStream) split(from, to int, sep string) [][2]int {
+	var parts [][2]int
+	start, angle := from, 0
+	for j := from; j <= to; j++ {
+		t := s.tok(j)
+		if t.kind != tokPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			j = s.match(j)
+		case "<":
+			angle++
+		case ">":
+			if angle > 0 {
+				angle--
+			}
+		default:
+			if t.text == sep && angle == 0 {
+				parts = append(parts, [2]int{start, j - 1})
+				start = j + 1
+			}
+		}
+	}
+	if start <= to {
+		parts = append(parts, [2]int{start, to})
+	}
+	return parts
+}
+
+// indexOf returns the index of the next top level token with the given text
+func (s *tokenStream) indexOf(i int, text string) int {
+	for j := i; j < len(s.tokens); j++ {
+		if s.is(j, text) {
+			return j
+		}
+		if s.is(j, "(") || s.is(j, "[") || (s.is(j, "{") && text != "{") {
+			j = s.match(j)
+		}
+	}
+	return len(s.tokens) - 1
+}
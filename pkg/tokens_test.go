@@ -0,0 +1,222 @@
+package deepspec
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect tokenDialect
+		src     string
+		want    []string
+		// wantKinds are the kinds of the tokens, checked when set
+		wantKinds  []tokenKind
+		wantHeader string
+	}{
+		{
+			name:    "script punctuators",
+			dialect: dialectScript,
+			src:     "a ??= b?.c === d => e",
+			want:    []string{"a", "??=", "b", "?.", "c", "===", "d", "=>", "e"},
+		},
+		{
+			name:    "conditional before a number",
+			dialect: dialectScript,
+			src:     "a?.5:1",
+			want:    []string{"a", "?", ".5", ":", "1"},
+		},
+		{
+			name:      "template literal and regex",
+			dialect:   dialectScript,
+			src:       "x = `a ${b + `c`}` / 2; y = /a\\/b/g",
+			want:      []string{"x", "=", "`a ${b + `c`}`", "/", "2", ";", "y", "=", "/a\\/b/g"},
+			wantKinds: []tokenKind{tokIdent, tokPunct, tokTemplate, tokPunct, tokNumber, tokPunct, tokIdent, tokPunct, tokRegex},
+		},
+		{
+			name:    "closing type arguments split",
+			dialect: dialectScript,
+			src:     "Map<string, Array<number>>",
+			want:    []string{"Map", "<", "string", ",", "Array", "<", "number", ">", ">"},
+		},
+		{
+			name:      "jsx element",
+			dialect:   dialectJSX,
+			src:       "return <div a={x > 1}>{y}</div>;",
+			want:      []string{"return", "<div a={x > 1}>{y}</div>", ";"},
+			wantKinds: []tokenKind{tokIdent, tokTemplate, tokPunct},
+		},
+		{
+			name:    "comparison is no jsx",
+			dialect: dialectJSX,
+			src:     "a <b",
+			want:    []string{"a", "<", "b"},
+		},
+		{
+			name:       "private names and file comment",
+			dialect:    dialectScript,
+			src:        "/* Header */\n// eslint-disable\nthis.#x = 1e-3",
+			want:       []string{"this", ".", "#x", "=", "1e-3"},
+			wantKinds:  []tokenKind{tokIdent, tokPunct, tokIdent, tokPunct, tokNumber},
+			wantHeader: "Header",
+		},
+		{
+			name:    "java text block and method reference",
+			dialect: dialectJava,
+			src:     "s = \"\"\"\n  a \"b\"\n  \"\"\"; f(String::valueOf)",
+			want:    []string{"s", "=", "\"\"\"\n  a \"b\"\n  \"\"\"", ";", "f", "(", "String", "::", "valueOf", ")"},
+		},
+		{
+			name:      "rust literals and lifetimes",
+			dialect:   dialectRust,
+			src:       "let c: &'a str = r#\"a \"b\"\"#; let d = b'x'; let e = '\\n';",
+			want:      []string{"let", "c", ":", "&", "'a", "str", "=", "r#\"a \"b\"\"#", ";", "let", "d", "=", "b'x'", ";", "let", "e", "=", "'\\n'", ";"},
+			wantKinds: []tokenKind{tokIdent, tokIdent, tokPunct, tokPunct, tokIdent, tokIdent, tokPunct, tokString, tokPunct, tokIdent, tokIdent, tokPunct, tokString, tokPunct, tokIdent, tokIdent, tokPunct, tokString, tokPunct},
+		},
+		{
+			name:    "rust ranges and tuple fields",
+			dialect: dialectRust,
+			src:     "0..=9; t.0.1; r#type",
+			want:    []string{"0", "..=", "9", ";", "t", ".", "0.1", ";", "r#type"},
+		},
+		{
+			name:       "rust inner doc and nested comments",
+			dialect:    dialectRust,
+			src:        "//! Crate docs.\n//! More.\n/* a /* b */ c */ fn f() {}",
+			want:       []string{"fn", "f", "(", ")", "{", "}"},
+			wantHeader: "Crate docs.\nMore.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, header := tokenize([]byte(tt.src), tt.dialect)
+			var got []string
+			var kinds []tokenKind
+			for _, token := range tokens {
+				got = append(got, token.text)
+				kinds = append(kinds, token.kind)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("tokens:\n%q\nwant:\n%q", got, tt.want)
+			}
+			if tt.wantKinds != nil && !slices.Equal(kinds, tt.wantKinds) {
+				t.Errorf("kinds = %v, want %v", kinds, tt.wantKinds)
+			}
+			if header != tt.wantHeader {
+				t.Errorf("header = %q, want %q", header, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestTokenizeDocComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect tokenDialect
+		src     string
+		// want maps token texts to the doc comments attached to them
+		want map[string]string
+	}{
+		{
+			name:    "block doc",
+			dialect: dialectJava,
+			src:     "/**\n * Adds.\n * Twice.\n */\nint add;\n// plain\nint sub;",
+			want:    map[string]string{"int": "Adds.\nTwice."},
+		},
+		{
+			name:    "script line comments join",
+			dialect: dialectScript,
+			src:     "x;\n// one\n// two\nconst a = 1;\n// far\n\nconst b = 2;",
+			want:    map[string]string{"const": "one\ntwo"},
+		},
+		{
+			name:    "rust triple slash only",
+			dialect: dialectRust,
+			src:     "// plain\nfn a() {}\n/// Doc.\n//// not doc\nfn b() {}",
+			want:    map[string]string{"fn": ""},
+		},
+		{
+			name:    "rust doc",
+			dialect: dialectRust,
+			src:     "/// First.\n/// Second.\npub fn a() {}",
+			want:    map[string]string{"pub": "First.\nSecond."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, _ := tokenize([]byte(tt.src), tt.dialect)
+			got := map[string]string{}
+			for _, token := range tokens {
+				if _, ok := tt.want[token.text]; ok {
+					if _, seen := got[token.text]; !seen || token.doc != "" {
+						got[token.text] = token.doc
+					}
+				}
+			}
+			for text, doc := range tt.want {
+				if got[text] != doc {
+					t.Errorf("doc of %s = %q, want %q", text, got[text], doc)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenStream(t *testing.T) {
+	src := []byte("f(a, g(b, c), [d]) { x: Map<K, List<V>> }")
+	tokens, _ := tokenize(src, dialectScript)
+	s := &tokenStream{src: src, tokens: tokens}
+	index := func(text string) int {
+		return slices.IndexFunc(tokens, func(t sourceToken) bool { return t.text == text })
+	}
+	parts := func(from, to int, sep string) []string {
+		var got []string
+		for _, part := range s.split(from, to, sep) {
+			got = append(got, s.source(part[0], part[1]))
+		}
+		return got
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "match parenthesis", got: s.source(1, s.match(1)), want: "(a, g(b, c), [d])"},
+		{name: "match brace", got: s.source(index("{"), s.match(index("{"))), want: "{ x: Map<K, List<V>> }"},
+		{name: "match angle", got: s.source(index("<"), s.matchAngle(index("<"))), want: "<K, List<V>>"},
+		{name: "split", got: parts(2, s.match(1)-1, ","), want: []string{"a", "g(b, c)", "[d]"}},
+		{name: "split type arguments", got: parts(index("<")+1, s.matchAngle(index("<"))-1, ","), want: []string{"K", "List<V>"}},
+		{name: "index of top level token", got: s.indexOf(0, "{"), want: index("{")},
+		{name: "past the end", got: s.tok(len(tokens)).kind, want: tokenKind(-1)},
+		{name: "is", got: []bool{s.is(0, "f"), s.is(1, "("), s.isIdent(1)}, want: []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			switch want := tt.want.(type) {
+			case []string:
+				if !slices.Equal(tt.got.([]string), want) {
+					t.Errorf("got %q, want %q", tt.got, want)
+				}
+			case []bool:
+				if !slices.Equal(tt.got.([]bool), want) {
+					t.Errorf("got %v, want %v", tt.got, want)
+				}
+			default:
+				if tt.got != tt.want {
+					t.Errorf("got %v, want %v", tt.got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenStreamCode(t *testing.T) {
+	src := []byte("\tif (a) {\n\t\tb()\n\t}\n")
+	tokens, _ := tokenize(src, dialectScript)
+	s := &tokenStream{src: src, tokens: tokens}
+	if got, want := s.code(0, len(tokens)-1), "if (a) {\n\tb()\n}"; got != want {
+		t.Errorf("code = %q, want %q", got, want)
+	}
+}
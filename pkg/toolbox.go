@@ -0,0 +1,108 @@
+package deepspec
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Toolbox holds the tools a chat offers the model: the internal tools plus
+// the tools of the MCP server
+type Toolbox struct {
+	mcpClient *mcpClient
+	mutex     sync.Mutex
+	// mcpTools maps the declared names of MCP tools to tool names
+	mcpTools map[string]string
+}
+
+// NewToolbox creates a toolbox offering the tools of an MCP client, which
+// may be nil for internal tools only
+func NewToolbox(client *mcpClient) *Toolbox {
+	return &Toolbox{
+		mcpClient: client,
+		mcpTools:  make(map[string]string),
+	}
+}
+
+// Definitions lists every internal tool and every tool the MCP server lists
+// now. Server tools whose names clash with internal tools or earlier tools
+// are skipped.
+func (t *Toolbox) Definitions(ctx context.Context) []ToolDefinition {
+	definitions := internalTools.Definitions()
+	if t.mcpClient == nil {
+		return definitions
+	}
+	tools, err := t.mcpClient.ListTools(ctx)
+	if err != nil {
+		LogToFile("Failed to list MCP tools: %v", err)
+		return definitions
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	declared := make(map[string]bool)
+	for _, tool := range tools {
+		definition, err := mcpToolDefinition(tool)
+		if err != nil {
+			LogToFile("Skipping MCP tool: %v", err)
+			continue
+		}
+		if internalTools.Has(definition.Name) || declared[definition.Name] {
+			LogToFile("Skipping MCP tool %s: name %s already declared", tool.Name, definition.Name)
+			continue
+		}
+		declared[definition.Name] = true
+		// Keep names from earlier lists so running chats can still call them
+		t.mcpTools[definition.Name] = tool.Name
+		definitions = append(definitions, definition)
+	}
+	return definitions
+}
+
+// Execute runs an internal tool, or calls the MCP server for a declared
+// server tool, and returns the output for the model. Arguments that do not
+// match an internal tool's schema are reported back as violations. Once the
+// context is done no tool runs and the output reports why.
+func (t *Toolbox) Execute(ctx context.Context, call ToolCall) map[string]any {
+	if err := ctx.Err(); err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+
+	// Internal tools take precedence over server tools of the same name
+	if !internalTools.Has(call.Name) {
+		return t.callMCPTool(ctx, call)
+	}
+
+	// Execute the tool
+	result, err := ExecuteInternalTool(ctx, call.Name, call.Args)
+	var argsErr *ToolArgumentsError
+	if errors.As(err, &argsErr) {
+		var violations []map[string]any
+		for _, violation := range argsErr.Violations {
+			violations = append(violations, map[string]any{"pointer": violation.Pointer, "message": violation.Message})
+		}
+		return map[string]any{"error": "invalid arguments for " + call.Name, "violations": violations}
+	}
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+
+	return map[string]any{"output": result}
+}
+
+// callMCPTool routes a tool call to the MCP server
+func (t *Toolbox) callMCPTool(ctx context.Context, call ToolCall) map[string]any {
+	t.mutex.Lock()
+	name, ok := t.mcpTools[call.Name]
+	t.mutex.Unlock()
+	if !ok || t.mcpClient == nil {
+		return map[string]any{"error": "unknown function: " + call.Name}
+	}
+
+	result, err := t.mcpClient.CallTool(ctx, name, call.Args)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return toolResultOutput(result)
+}
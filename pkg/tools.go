@@ -2,45 +2,119 @@ package deepspec
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 )
 
 // ToolFunc is a function that executes a tool with given arguments
 type ToolFunc func(ctx context.Context, args map[string]interface{}) (string, error)
 
+// ToolDefinition describes a tool to the model
+type ToolDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON schema of the arguments object
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolArgumentsError reports arguments that do not match a tool's schema
+type ToolArgumentsError struct {
+	Tool       string
+	Violations []ValidationError
+}
+
+// Error lists the violations
+func (e *ToolArgumentsError) Error() string {
+	var messages []string
+	for _, violation := range e.Violations {
+		pointer := violation.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		messages = append(messages, pointer+": "+violation.Message)
+	}
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Tool, strings.Join(messages, "; "))
+}
+
+// registeredTool is a tool function together with its definition
+type registeredTool struct {
+	definition ToolDefinition
+	schema     *jsonSchema
+	fn         ToolFunc
+}
+
 // ToolRegistry manages tool functions
 type ToolRegistry struct {
-	tools map[string]ToolFunc
+	tools map[string]registeredTool
 }
 
 // NewToolRegistry creates a new tool registry
 func NewToolRegistry() *ToolRegistry {
 	return &ToolRegistry{
-		tools: make(map[string]ToolFunc),
+		tools: make(map[string]registeredTool),
 	}
 }
 
-// Register adds a tool to the registry
-func (r *ToolRegistry) Register(name string, fn ToolFunc) {
-	r.tools[name] = fn
+// Register adds a tool to the registry. Without parameters the tool takes an
+// empty arguments object.
+func (r *ToolRegistry) Register(definition ToolDefinition, fn ToolFunc) error {
+	if definition.Name == "" {
+		return errors.New("tool name is required")
+	}
+	if definition.Parameters == nil {
+		definition.Parameters = json.RawMessage(`{"type": "object", "properties": {}}`)
+	}
+	schema, err := newJSONSchema(definition.Parameters)
+	if err != nil {
+		return fmt.Errorf("tool %s: invalid parameters schema: %w", definition.Name, err)
+	}
+	r.tools[definition.Name] = registeredTool{definition: definition, schema: schema, fn: fn}
+	return nil
 }
 
 // Get retrieves a tool by name
 func (r *ToolRegistry) Get(name string) (ToolFunc, error) {
-	fn, ok := r.tools[name]
+	tool, ok := r.tools[name]
 	if !ok {
 		return nil, errors.New("tool not found: " + name)
 	}
-	return fn, nil
+	return tool.fn, nil
+}
+
+// Definitions returns the definitions of all tools, sorted by name
+func (r *ToolRegistry) Definitions() []ToolDefinition {
+	definitions := make([]ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, tool.definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+	return definitions
 }
 
-// Execute runs a tool by name with the given arguments
+// Validate checks arguments against the parameters schema of a tool
+func (r *ToolRegistry) Validate(name string, args map[string]interface{}) error {
+	tool, ok := r.tools[name]
+	if !ok {
+		return errors.New("tool not found: " + name)
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if violations := tool.schema.validate(args); len(violations) > 0 {
+		return &ToolArgumentsError{Tool: name, Violations: violations}
+	}
+	return nil
+}
+
+// Execute validates the arguments and runs a tool by name
 func (r *ToolRegistry) Execute(ctx context.Context, name string, args map[string]interface{}) (string, error) {
-	fn, err := r.Get(name)
-	if err != nil {
+	if err := r.Validate(name, args); err != nil {
 		return "", err
 	}
-	return fn(ctx, args)
+	return r.tools[name].fn(ctx, args)
 }
 
 // Has checks if a tool exists in the registry
@@ -10,6 +10,12 @@ type Model interface {
 	SetSize(width, height int)
 }
 
+// canceler is implemented by models that can cancel work in progress
+type canceler interface {
+	// Cancel cancels the work in progress and reports whether there was any
+	Cancel() bool
+}
+
 // TUI represents the terminal user interface
 type TUI struct {
 	models []Model
@@ -18,10 +24,10 @@ type TUI struct {
 	height int
 }
 
-// NewTUI creates a new TUI instance
-func NewTUI() *TUI {
+// NewTUI creates a new TUI instance from the config
+func NewTUI(config *Config) *TUI {
 	// Initialize with a chat model
-	models := []Model{NewChatModel()}
+	models := []Model{NewChatModel(config)}
 
 	return &TUI{
 		models: models,
@@ -51,7 +57,13 @@ func (t *TUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
+			return t, tea.Quit
+		case tea.KeyEsc:
+			// Esc cancels a request in flight and quits otherwise
+			if model, ok := t.model.(canceler); ok && model.Cancel() {
+				return t, nil
+			}
 			return t, tea.Quit
 		}
 	}
@@ -67,9 +79,9 @@ func (t *TUI) View() string {
 	return t.model.View()
 }
 
-// StartTUI starts the terminal user interface
-func StartTUI() error {
-	tui := NewTUI()
+// StartTUI starts the terminal user interface from the config
+func StartTUI(config *Config) error {
+	tui := NewTUI(config)
 	p := tea.NewProgram(tui, tea.WithAltScreen(), tea.WithMouseAllMotion())
 	_, err := p.Run()
 	return err
@@ -0,0 +1,1850 @@
+package deepspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"strconv"
+	"strings"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+// ExtractScriptSpecs walks a directory of TypeScript and JavaScript sources
+// and returns one spec per file, sorted by file path. language limits the
+// files to codespec.LanguageTypeScript or codespec.LanguageJavaScript; an
+// empty language extracts both.
+func ExtractScriptSpecs(root, language string) ([]*codespec.Spec, error) {
+	languages := []string{codespec.LanguageTypeScript, codespec.LanguageJavaScript}
+	if language != "" {
+		languages = []string{language}
+	}
+	var extractors []Extractor
+	for _, language := range languages {
+		extractor, err := NewScriptExtractor(root, language)
+		if err != nil {
+			return nil, err
+		}
+		extractors = append(extractors, extractor)
+	}
+	return ExtractSpecs(root, extractors...)
+}
+
+// ScriptExtractor extracts TypeScript or JavaScript files
+type ScriptExtractor struct {
+	root     string
+	language string
+}
+
+// NewScriptExtractor creates an extractor for the TypeScript or JavaScript
+// files below root
+func NewScriptExtractor(root, language string) (*ScriptExtractor, error) {
+	if language != codespec.LanguageTypeScript && language != codespec.LanguageJavaScript {
+		return nil, fmt.Errorf("unknown script language %q", language)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &ScriptExtractor{root: root, language: language}, nil
+}
+
+// Language returns codespec.LanguageTypeScript or codespec.LanguageJavaScript
+func (e *ScriptExtractor) Language() string {
+	return e.language
+}
+
+// Match reports whether path is a source file of the extractor's language
+func (e *ScriptExtractor) Match(path string) bool {
+	return scriptLanguage(filepath.Base(path)) == e.language
+}
+
+// Extract builds the spec of a script file
+func (e *ScriptExtractor) Extract(path string) (*codespec.Spec, error) {
+	rel, err := relativeSource(e.root, path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return extractScriptFile(rel, e.language, src), nil
+}
+
+// scriptLanguage maps a file name onto its language, or "" for anything
+// that is not a script source
+func scriptLanguage(name string) string {
+	if strings.HasSuffix(name, ".min.js") {
+		return ""
+	}
+	switch path.Ext(name) {
+	case ".ts", ".tsx", ".mts", ".cts":
+		return codespec.LanguageTypeScript
+	case ".js", ".jsx", ".mjs", ".cjs":
+		return codespec.LanguageJavaScript
+	}
+	return ""
+}
+
+// scriptModuleName derives the module specifier of a file: its path without
+// extension, with index files standing for their directory
+func scriptModuleName(file string) string {
+	name := strings.TrimSuffix(file, path.Ext(file))
+	name = strings.TrimSuffix(name, ".d")
+	if path.Base(name) == "index" && path.Dir(name) != "." {
+		name = path.Dir(name)
+	}
+	return name
+}
+
+// jsKeywordsBeforeExpression are identifiers after which a slash starts a
+// regular expression rather than a division
+var jsKeywordsBeforeExpression = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+// jsRegexAllowed reports whether a slash after the given tokens starts a
+// regular expression literal
+func jsRegexAllowed(tokens []sourceToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	prev := tokens[len(tokens)-1]
+	switch prev.kind {
+	case tokIdent:
+		return jsKeywordsBeforeExpression[prev.text]
+	case tokPunct:
+		if prev.text == ")" {
+			return jsClosesControlHead(tokens)
+		}
+		return prev.text != "]" && prev.text != "}"
+	}
+	return false
+}
+
+// jsControlKeywords are the statements whose parenthesized head is followed
+// by a statement, so a slash after the head starts a regular expression
+var jsControlKeywords = map[string]bool{"if": true, "while": true, "for": true, "with": true}
+
+// jsClosesControlHead reports whether the parenthesis ending tokens closes
+// the head of an if, while, for or with statement
+func jsClosesControlHead(tokens []sourceToken) bool {
+	depth := 0
+	for j := len(tokens) - 1; j >= 0; j-- {
+		if tokens[j].kind != tokPunct {
+			continue
+		}
+		switch tokens[j].text {
+		case ")":
+			depth++
+		case "(":
+			if depth--; depth > 0 {
+				continue
+			}
+			if j > 0 && tokens[j-1].text == "await" {
+				// for await (...)
+				j--
+			}
+			return j > 0 && tokens[j-1].kind == tokIdent && jsControlKeywords[tokens[j-1].text]
+		}
+	}
+	return false
+}
+
+// jsTemplateEnd returns the offset just past the template literal starting
+// at i, skipping nested substitutions
+func jsTemplateEnd(src []byte, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch {
+		case src[j] == '\\':
+			j++
+		case src[j] == '`':
+			return j + 1
+		case src[j] == '$' && j+1 < len(src) && src[j+1] == '{':
+			j = jsSubstitutionEnd(src, j+1) - 1
+		}
+	}
+	return len(src)
+}
+
+// jsSubstitutionEnd returns the offset just past the brace matching the one
+// at open
+func jsSubstitutionEnd(src []byte, open int) int {
+	depth := 0
+	for j := open; j < len(src); j++ {
+		switch src[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		case '"', '\'':
+			j = quotedEnd(src, j, false) - 1
+		case '`':
+			j = jsTemplateEnd(src, j) - 1
+		case '<':
+			// JSX elements nested in the expression, e.g. {items.map(i => <li>{i}</li>)}
+			prev := strings.TrimRight(string(src[open:j]), " \t\r\n")
+			if j+1 < len(src) && (identByte(src[j+1]) || src[j+1] == '>') && strings.ContainsAny(prev[len(prev)-1:], "({[,=>?:&|") {
+				j = jsxElementEnd(src, j) - 1
+			}
+		}
+	}
+	return len(src)
+}
+
+// jsxElementEnd returns the offset just past the JSX element starting at i,
+// including its children and closing tag
+func jsxElementEnd(src []byte, i int) int {
+	j := i + 1
+	// Opening tag with its attributes
+	for j < len(src) && src[j] != '>' {
+		switch src[j] {
+		case '{':
+			j = jsSubstitutionEnd(src, j)
+			continue
+		case '"', '\'':
+			j = quotedEnd(src, j, false)
+			continue
+		case '/':
+			if j+1 < len(src) && src[j+1] == '>' {
+				return j + 2
+			}
+		}
+		j++
+	}
+	for j++; j < len(src); {
+		switch {
+		case src[j] == '{':
+			j = jsSubstitutionEnd(src, j)
+		case src[j] == '<' && j+1 < len(src) && src[j+1] == '/':
+			for j < len(src) && src[j] != '>' {
+				j++
+			}
+			return min(j+1, len(src))
+		case src[j] == '<':
+			j = jsxElementEnd(src, j)
+		default:
+			j++
+		}
+	}
+	return len(src)
+}
+
+// jsRegexEnd returns the offset just past the regular expression starting
+// at i, or -1 when the line ends first
+func jsRegexEnd(src []byte, i int) int {
+	inClass := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '\n':
+			return -1
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if inClass {
+				continue
+			}
+			end := j + 1
+			for end < len(src) && identByte(src[end]) {
+				end++
+			}
+			return end
+		}
+	}
+	return -1
+}
+
+// scriptParser turns the tokens of a file into a spec
+type scriptParser struct {
+	tokenStream
+	spec *codespec.Spec
+	// exported holds top level names made public by an export
+	exported map[string]bool
+	// modules holds the bindings of default and namespace imports, calls
+	// through which count as function calls
+	modules map[string]bool
+	// overloads holds the indexes of functions declared without a body
+	overloads  map[string]int
+	complexity int
+	// java switches statements to Java syntax: mandatory semicolons, typed
+	// local declarations, for-each loops and arrow cases
+	java bool
+}
+
+// extractScriptFile builds the spec for a single TypeScript or JavaScript file
+func extractScriptFile(file, language string, src []byte) *codespec.Spec {
+	dialect := dialectJSX
+	if ext := path.Ext(file); ext == ".ts" || ext == ".mts" || ext == ".cts" {
+		// <T>value is a type assertion, not an element
+		dialect = dialectScript
+	}
+	tokens, header := tokenize(src, dialect)
+	p := &scriptParser{
+		tokenStream: tokenStream{src: src, tokens: tokens},
+		spec: &codespec.Spec{
+			SpecVersion: codespec.Version,
+			Language:    language,
+			Module:      scriptModuleName(file),
+			File:        file,
+		},
+		exported:  make(map[string]bool),
+		modules:   make(map[string]bool),
+		overloads: make(map[string]int),
+	}
+	for _, tag := range []string{"@fileoverview", "@file", "@module"} {
+		if idx := strings.Index(header, tag); idx >= 0 {
+			p.spec.Description = strings.TrimSpace(header[:idx] + header[idx+len(tag):])
+			break
+		}
+	}
+
+	for i := 0; i < len(p.tokens); {
+		i = p.topLevel(i)
+	}
+	p.applyExports()
+
+	p.spec.Metadata = lineMetrics(src)
+	p.spec.Metadata.CyclomaticComplexity = p.complexity
+	return p.spec
+}
+
+// endsStatement reports whether a statement may end after t
+func (p *scriptParser) endsStatement(t sourceToken) bool {
+	switch t.kind {
+	case tokIdent:
+		switch t.text {
+		case "new", "typeof", "instanceof", "in", "of", "extends", "implements", "as", "satisfies",
+			"keyof", "void", "delete", "else", "do", "case", "export", "default", "import":
+			return false
+		}
+		return true
+	case tokPunct:
+		switch t.text {
+		case ")", "]", "}", "++", "--":
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// continuesStatement reports whether t on a new line continues the
+// statement before it
+func (p *scriptParser) continuesStatement(t sourceToken) bool {
+	switch t.kind {
+	case tokTemplate:
+		return true
+	case tokIdent:
+		switch t.text {
+		case "as", "satisfies", "instanceof", "in", "of", "extends", "implements":
+			return true
+		}
+		return false
+	case tokPunct:
+		switch t.text {
+		case "{", "}", ";", "!", "~", "++", "--", "@", "#":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// statementEnd returns the index of the last token of the statement
+// starting at i, which is its semicolon if it has one. An unmatched closing
+// bracket at i is a statement of its own, so callers always advance.
+func (p *scriptParser) statementEnd(i int) int {
+	for j := i; j < len(p.tokens); j++ {
+		t := p.tokens[j]
+		if !p.java && j > i && t.nl && p.endsStatement(p.tokens[j-1]) && !p.continuesStatement(t) {
+			return j - 1
+		}
+		if t.kind != tokPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			j = p.match(j)
+		case ";":
+			return j
+		case ")", "]", "}":
+			return max(j-1, i)
+		}
+	}
+	return len(p.tokens) - 1
+}
+
+// typeEnd returns the index of the last token of the type starting at i.
+// With stopAtArrow set, a top level => ends the type, as in the return type
+// annotation of an arrow function.
+func (p *scriptParser) typeEnd(i int, stopAtArrow bool) int {
+	last, angle := i-1, 0
+	for j := i; j < len(p.tokens); j++ {
+		t := p.tokens[j]
+		if j > i && angle == 0 && t.nl {
+			prev := p.tokens[j-1]
+			ends := prev.kind != tokPunct || prev.text == ")" || prev.text == "]" || prev.text == "}" || prev.text == ">"
+			continues := t.kind == tokPunct && (t.text == "|" || t.text == "&" || t.text == "." || t.text == "?" || t.text == ":" || t.text == "[" || t.text == "=>") ||
+				t.kind == tokIdent && (t.text == "extends" || t.text == "is")
+			if ends && !continues {
+				return last
+			}
+		}
+		if t.kind == tokPunct {
+			switch t.text {
+			case "(", "[":
+				j = p.match(j)
+				last = j
+				continue
+			case "{":
+				if j > i && angle == 0 && !p.typeOpensObject(p.tokens[j-1]) {
+					return last
+				}
+				j = p.match(j)
+				last = j
+				continue
+			case "<":
+				angle++
+			case ">":
+				if angle == 0 {
+					return last
+				}
+				angle--
+			case "=>":
+				if stopAtArrow && angle == 0 {
+					return last
+				}
+			case ";", ",", ")", "]", "}", "=":
+				if angle == 0 {
+					return last
+				}
+			}
+		} else if t.kind == tokIdent && t.text == "implements" && j > i && angle == 0 {
+			return last
+		}
+		last = j
+	}
+	return last
+}
+
+// typeOpensObject reports whether a brace after t starts an object type
+// rather than a function body
+func (p *scriptParser) typeOpensObject(t sourceToken) bool {
+	switch t.text {
+	case ":", "|", "&", "<", ",", "=>", "(", "[", "?", "keyof", "typeof", "readonly", "extends":
+		return true
+	}
+	return false
+}
+
+// topLevel parses the module level statement starting at i and returns the
+// index of the next one
+func (p *scriptParser) topLevel(i int) int {
+	doc := p.tok(i).doc
+	var decorators []string
+	for p.is(i, "@") {
+		end := p.decoratorEnd(i)
+		decorators = append(decorators, p.source(i+1, end))
+		i = end + 1
+		if doc == "" {
+			doc = p.tok(i).doc
+		}
+	}
+
+	exported, isDefault := false, false
+	if p.is(i, "export") {
+		exported = true
+		i++
+		switch {
+		case p.is(i, "default"):
+			isDefault = true
+			i++
+		case p.is(i, "{") || p.is(i, "*") || (p.is(i, "type") && p.is(i+1, "{")):
+			return p.exportList(i)
+		case p.is(i, "="):
+			end := p.statementEnd(i)
+			if p.isIdent(i + 1) {
+				p.exported[p.tok(i+1).text] = true
+			}
+			return end + 1
+		case p.is(i, "import"):
+			// export import X = Y.Z
+			return p.statementEnd(i) + 1
+		}
+	}
+	if p.is(i, "declare") {
+		i++
+	}
+	abstract := false
+	if p.is(i, "abstract") && p.is(i+1, "class") {
+		abstract = true
+		i++
+	}
+	async := false
+	if p.is(i, "async") && p.is(i+1, "function") {
+		async = true
+		i++
+	}
+
+	t := p.tok(i)
+	if t.kind == tokIdent {
+		switch {
+		case t.text == "import" && !p.is(i+1, "(") && !p.is(i+1, "."):
+			return p.importDecl(i)
+		case t.text == "function":
+			fn, next := p.functionDecl(i, async, isDefault)
+			fn.Documentation = doc
+			setExtra(&fn.Extra, "decorators", decorators)
+			p.addFunction(fn, next, exported)
+			return next
+		case t.text == "class" && (p.isIdent(i+1) || isDefault):
+			typ, methods, next := p.classDecl(i, isDefault)
+			typ.Documentation = doc
+			setExtra(&typ.Extra, "decorators", decorators)
+			if abstract {
+				setExtra(&typ.Extra, "abstract", true)
+			}
+			p.spec.Types = append(p.spec.Types, typ)
+			p.spec.Functions = append(p.spec.Functions, methods...)
+			if exported {
+				p.exported[typ.Name] = true
+			}
+			return next
+		case t.text == "interface" && p.isIdent(i+1):
+			typ, next := p.interfaceDecl(i)
+			typ.Documentation = doc
+			p.spec.Types = append(p.spec.Types, typ)
+			if exported {
+				p.exported[typ.Name] = true
+			}
+			return next
+		case t.text == "type" && p.isIdent(i+1) && (p.is(i+2, "=") || p.is(i+2, "<")):
+			typ, next := p.typeAlias(i)
+			typ.Documentation = doc
+			p.spec.Types = append(p.spec.Types, typ)
+			if exported {
+				p.exported[typ.Name] = true
+			}
+			return next
+		case t.text == "enum" || (t.text == "const" && p.is(i+1, "enum")):
+			typ, next := p.enumDecl(i)
+			typ.Documentation = doc
+			p.spec.Types = append(p.spec.Types, typ)
+			if exported {
+				p.exported[typ.Name] = true
+			}
+			return next
+		case (t.text == "const" || t.text == "let" || t.text == "var") && !isDefault:
+			return p.variableDecl(i, doc, exported)
+		case (t.text == "namespace" || t.text == "module") && (p.isIdent(i+1) || p.tok(i+1).kind == tokString) && !p.tok(i+1).nl,
+			t.text == "global" && p.is(i+1, "{"):
+			// Namespaces and ambient module blocks are skipped as a whole
+			end := i + 1
+			for end < len(p.tokens) && !p.is(end, "{") && !p.is(end, ";") {
+				end++
+			}
+			if p.is(end, "{") {
+				end = p.match(end)
+			}
+			return end + 1
+		}
+	}
+
+	end := p.statementEnd(i)
+	if isDefault {
+		if p.isIdent(i) && p.withoutSemicolon(i, end) == i {
+			p.exported[t.text] = true
+		} else if fn, ok := p.functionExpression(i, p.withoutSemicolon(i, end)); ok {
+			fn.Name = "default"
+			fn.Documentation = doc
+			p.addFunction(fn, end+1, true)
+		}
+	} else {
+		p.commonJSExports(i, p.withoutSemicolon(i, end))
+	}
+	return end + 1
+}
+
+// decoratorEnd returns the index of the last token of the decorator at i
+func (p *scriptParser) decoratorEnd(i int) int {
+	j := i + 1
+	for p.isIdent(j) && (p.is(j+1, ".") || p.is(j+1, "?.")) {
+		j += 2
+	}
+	if p.is(j+1, "(") {
+		return p.match(j + 1)
+	}
+	return j
+}
+
+// addFunction adds a module level function. Overload signatures are
+// replaced by the implementation that follows them.
+func (p *scriptParser) addFunction(fn codespec.Function, next int, exported bool) {
+	if exported {
+		p.exported[fn.Name] = true
+	}
+	hasBody := p.is(next-1, "}") || len(fn.BodyStatements) > 0
+	if idx, ok := p.overloads[fn.Name]; ok {
+		p.spec.Functions[idx] = fn
+		if hasBody {
+			delete(p.overloads, fn.Name)
+		}
+		return
+	}
+	if !hasBody {
+		p.overloads[fn.Name] = len(p.spec.Functions)
+	}
+	p.spec.Functions = append(p.spec.Functions, fn)
+}
+
+// importDecl parses an import declaration
+func (p *scriptParser) importDecl(i int) int {
+	end := p.statementEnd(i)
+	imp := codespec.Import{}
+	var names []string
+	typeOnly := false
+	j := i + 1
+	if p.is(j, "type") && !p.is(j+1, "from") && !p.is(j+1, ",") {
+		typeOnly = true
+		j++
+	}
+	for ; j <= end; j++ {
+		t := p.tok(j)
+		switch {
+		case t.kind == tokString:
+			imp.Path, _ = strconv.Unquote(`"` + t.text[1:len(t.text)-1] + `"`)
+			if imp.Path == "" {
+				imp.Path = t.text[1 : len(t.text)-1]
+			}
+		case p.is(j, "*") && p.is(j+1, "as"):
+			imp.Alias = p.tok(j + 2).text
+			p.modules[imp.Alias] = true
+			j += 2
+		case p.is(j, "{"):
+			close := p.match(j)
+			for _, part := range p.split(j+1, close-1, ",") {
+				if part[0] <= part[1] {
+					names = append(names, strings.Join(strings.Fields(p.source(part[0], part[1])), " "))
+				}
+			}
+			j = close
+		case t.kind == tokIdent && t.text != "from" && imp.Alias == "":
+			imp.Alias = t.text
+			p.modules[t.text] = true
+		}
+	}
+	setExtra(&imp.Extra, "names", names)
+	if typeOnly {
+		setExtra(&imp.Extra, "type_only", true)
+	}
+	p.spec.Imports = append(p.spec.Imports, imp)
+	return end + 1
+}
+
+// exportList handles export { a, b as c } and re-exports from other modules
+func (p *scriptParser) exportList(i int) int {
+	end := p.statementEnd(i)
+	from := ""
+	for j := i; j <= end; j++ {
+		if p.is(j, "from") && p.tok(j+1).kind == tokString {
+			text := p.tok(j + 1).text
+			from = text[1 : len(text)-1]
+		}
+	}
+	if from != "" {
+		imp := codespec.Import{Path: from}
+		setExtra(&imp.Extra, "reexport", true)
+		p.spec.Imports = append(p.spec.Imports, imp)
+		return end + 1
+	}
+
+	if p.is(i, "type") {
+		i++
+	}
+	if p.is(i, "{") {
+		for _, part := range p.split(i+1, p.match(i)-1, ",") {
+			if part[0] <= part[1] && p.isIdent(part[0]) {
+				name := part[0]
+				if p.is(name, "type") && part[1] > name {
+					name++
+				}
+				p.exported[p.tok(name).text] = true
+			}
+		}
+	}
+	return end + 1
+}
+
+// commonJSExports marks names assigned to module.exports or exports.name
+func (p *scriptParser) commonJSExports(from, to int) {
+	switch {
+	case p.is(from, "module") && p.is(from+1, ".") && p.is(from+2, "exports") && p.is(from+3, "="):
+		value := from + 4
+		if p.isIdent(value) && value == to {
+			p.exported[p.tok(value).text] = true
+		}
+		if p.is(value, "{") && p.match(value) == to {
+			for _, part := range p.split(value+1, to-1, ",") {
+				if part[0] <= part[1] {
+					// { a } and { b: a } both export the local a
+					p.exported[p.tok(part[1]).text] = true
+				}
+			}
+		}
+	case p.is(from, "exports") && p.is(from+1, ".") && p.is(from+3, "="):
+		p.exported[p.tok(from+2).text] = true
+		if p.isIdent(from+4) && from+4 == to {
+			p.exported[p.tok(from+4).text] = true
+		}
+	}
+}
+
+// applyExports sets the visibility of module level declarations once all
+// export statements are known
+func (p *scriptParser) applyExports() {
+	visibility := func(name string) string {
+		if p.exported[name] {
+			return codespec.VisibilityPublic
+		}
+		return codespec.VisibilityPrivate
+	}
+	for i := range p.spec.Types {
+		p.spec.Types[i].Visibility = visibility(p.spec.Types[i].Name)
+	}
+	for i := range p.spec.Functions {
+		if fn := &p.spec.Functions[i]; fn.Receiver == nil {
+			fn.Visibility = visibility(fn.Name)
+		}
+	}
+	for i := range p.spec.Constants {
+		p.spec.Constants[i].Exported = codespec.Bool(p.exported[p.spec.Constants[i].Name])
+	}
+	for i := range p.spec.Variables {
+		p.spec.Variables[i].Exported = codespec.Bool(p.exported[p.spec.Variables[i].Name])
+	}
+}
+
+// functionDecl parses a function declaration starting at the function keyword
+func (p *scriptParser) functionDecl(i int, async, isDefault bool) (codespec.Function, int) {
+	fn := codespec.Function{}
+	i++
+	if p.is(i, "*") {
+		setExtra(&fn.Extra, "generator", true)
+		i++
+	}
+	if p.isIdent(i) {
+		fn.Name = p.tok(i).text
+		i++
+	} else if isDefault {
+		fn.Name = "default"
+	}
+	if async {
+		setExtra(&fn.Extra, "async", true)
+	}
+	return fn, p.signatureAndBody(&fn, i)
+}
+
+// signatureAndBody parses type parameters, parameters, return type and body
+// starting at i and returns the index after them
+func (p *scriptParser) signatureAndBody(fn *codespec.Function, i int) int {
+	if p.is(i, "<") {
+		i = p.matchAngle(i) + 1
+	}
+	if p.is(i, "(") {
+		close := p.match(i)
+		fn.Parameters, _ = p.parameters(i+1, close-1)
+		i = close + 1
+	}
+	if p.is(i, ":") {
+		end := p.typeEnd(i+1, false)
+		if ret := p.source(i+1, end); ret != "void" {
+			fn.Returns = []codespec.ReturnValue{{Type: ret}}
+		}
+		i = end + 1
+	}
+	if p.is(i, "{") {
+		close := p.match(i)
+		fn.BodyStatements = p.statements(i+1, close-1)
+		p.complexity += p.decisions(i+1, close-1)
+		return close + 1
+	}
+	if p.is(i, ";") {
+		i++
+	}
+	return i
+}
+
+// parameters parses a parameter list. Parameters carrying an accessibility
+// modifier are also returned as fields, as TypeScript declares them on the class.
+func (p *scriptParser) parameters(from, to int) ([]codespec.Parameter, []codespec.Field) {
+	var params []codespec.Parameter
+	var fields []codespec.Field
+	for _, part := range p.split(from, to, ",") {
+		j, end := part[0], part[1]
+		if j > end {
+			continue
+		}
+		for p.is(j, "@") {
+			j = p.decoratorEnd(j) + 1
+		}
+		access, readonly := "", false
+		for p.isIdent(j) && j < end && !p.is(j+1, ":") && !p.is(j+1, "=") && !p.is(j+1, "?") && !p.is(j+1, ",") {
+			switch p.tok(j).text {
+			case "public", "private", "protected":
+				access = p.tok(j).text
+			case "readonly":
+				readonly = true
+			case "override":
+			default:
+				goto name
+			}
+			j++
+		}
+	name:
+		param := codespec.Parameter{}
+		rest := ""
+		if p.is(j, "...") {
+			rest = "..."
+			j++
+		}
+		if p.is(j, "{") || p.is(j, "[") {
+			close := p.match(j)
+			param.Name = rest + p.source(j, close)
+			j = close + 1
+		} else {
+			param.Name = rest + p.tok(j).text
+			j++
+		}
+		if param.Name == "this" {
+			continue
+		}
+		if p.is(j, "?") {
+			setExtra(&param.Extra, "optional", true)
+			j++
+		}
+		if p.is(j, ":") {
+			typeEnd := min(p.typeEnd(j+1, false), end)
+			param.Type = p.source(j+1, typeEnd)
+			j = typeEnd + 1
+		}
+		if p.is(j, "=") && j < end {
+			param.Default = codespec.RawValue(jsDefaultValue(p.source(j+1, end)))
+		} else if p.is(j, "=") {
+			param.Default = codespec.RawValue("")
+		}
+		params = append(params, param)
+
+		if access != "" || readonly {
+			if access == "" {
+				access = codespec.VisibilityPublic
+			}
+			field := codespec.Field{Name: param.Name, Type: param.Type, Visibility: access}
+			if readonly {
+				setExtra(&field.Extra, "readonly", true)
+			}
+			fields = append(fields, field)
+		}
+	}
+	return params, fields
+}
+
+// jsDefaultValue converts a default value: numbers and booleans become JSON
+// values, everything else (strings included) stays source
+func jsDefaultValue(code string) any {
+	code = strings.TrimSpace(code)
+	switch code {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if number, ok := pyNumber(code); ok {
+		return number
+	}
+	return code
+}
+
+// functionExpression recognizes a function expression or arrow function
+// spanning tokens from through to
+func (p *scriptParser) functionExpression(from, to int) (codespec.Function, bool) {
+	fn := codespec.Function{}
+	i := from
+	if p.is(i, "async") && !p.is(i+1, "=>") && !p.tok(i+1).nl {
+		setExtra(&fn.Extra, "async", true)
+		i++
+	}
+
+	if p.is(i, "function") {
+		i++
+		if p.is(i, "*") {
+			setExtra(&fn.Extra, "generator", true)
+			i++
+		}
+		if p.isIdent(i) {
+			i++
+		}
+		if next := p.signatureAndBody(&fn, i); next-1 != to && next != to+1 {
+			return codespec.Function{}, false
+		}
+		return fn, true
+	}
+
+	if p.is(i, "<") {
+		i = p.matchAngle(i) + 1
+	}
+	switch {
+	case p.isIdent(i) && p.is(i+1, "=>"):
+		fn.Parameters = []codespec.Parameter{{Name: p.tok(i).text}}
+		i++
+	case p.is(i, "("):
+		close := p.match(i)
+		if close >= to {
+			return codespec.Function{}, false
+		}
+		params, _ := p.parameters(i+1, close-1)
+		i = close + 1
+		if p.is(i, ":") {
+			end := p.typeEnd(i+1, true)
+			if ret := p.source(i+1, end); ret != "void" {
+				fn.Returns = []codespec.ReturnValue{{Type: ret}}
+			}
+			i = end + 1
+		}
+		if !p.is(i, "=>") {
+			return codespec.Function{}, false
+		}
+		fn.Parameters = params
+	default:
+		return codespec.Function{}, false
+	}
+	i++ // =>
+
+	if p.is(i, "{") && p.match(i) == to {
+		fn.BodyStatements = p.statements(i+1, to-1)
+		p.complexity += p.decisions(i+1, to-1)
+		return fn, true
+	}
+	if i > to {
+		return codespec.Function{}, false
+	}
+	// Expression bodies return their value
+	fn.BodyStatements = []codespec.Statement{{Type: codespec.StatementReturn, Value: p.source(i, to)}}
+	p.complexity += p.decisions(i, to)
+	return fn, true
+}
+
+// classDecl parses a class declaration into its type and methods
+func (p *scriptParser) classDecl(i int, isDefault bool) (codespec.TypeDefinition, []codespec.Function, int) {
+	typ := codespec.TypeDefinition{Kind: codespec.KindClass}
+	i++
+	if p.isIdent(i) && !p.is(i, "extends") && !p.is(i, "implements") {
+		typ.Name = p.tok(i).text
+		i++
+	} else if isDefault {
+		typ.Name = "default"
+	}
+	if p.is(i, "<") {
+		i = p.matchAngle(i) + 1
+	}
+
+	var implements []string
+	for i < len(p.tokens) && !p.is(i, "{") {
+		switch {
+		case p.is(i, "extends"):
+			end := p.typeEnd(i+1, false)
+			for p.is(end+1, "(") {
+				// Mixins: extends Base(Other)
+				end = p.match(end + 1)
+			}
+			setExtra(&typ.Extra, "extends", p.source(i+1, end))
+			i = end + 1
+		case p.is(i, "implements"):
+			j := i + 1
+			for {
+				end := p.typeEnd(j, false)
+				implements = append(implements, p.source(j, end))
+				j = end + 1
+				if !p.is(j, ",") {
+					break
+				}
+				j++
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	setExtra(&typ.Extra, "implements", implements)
+
+	close := p.match(i)
+	methods := p.classMembers(&typ, i+1, close-1)
+	return typ, methods, close + 1
+}
+
+// jsModifiers are the keywords that may precede a class member name
+var jsModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "static": true, "readonly": true, "abstract": true,
+	"async": true, "override": true, "declare": true, "accessor": true, "get": true, "set": true,
+}
+
+// classMembers parses the body of a class, adding fields and method
+// signatures to typ and returning the methods
+func (p *scriptParser) classMembers(typ *codespec.TypeDefinition, from, to int) []codespec.Function {
+	var methods []codespec.Function
+	for j := from; j <= to; {
+		if p.is(j, ";") {
+			j++
+			continue
+		}
+		doc := p.tok(j).doc
+		for p.is(j, "@") {
+			j = p.decoratorEnd(j) + 1
+			if doc == "" {
+				doc = p.tok(j).doc
+			}
+		}
+
+		mods := make(map[string]bool)
+		for p.isIdent(j) && jsModifiers[p.tok(j).text] {
+			next := p.tok(j + 1)
+			isName := next.kind == tokIdent || next.kind == tokString || next.kind == tokNumber ||
+				(next.kind == tokPunct && (next.text == "[" || next.text == "*" || next.text == "{"))
+			if !isName || next.nl && !p.isIdent(j+1) {
+				break
+			}
+			mods[p.tok(j).text] = true
+			j++
+		}
+		if mods["static"] && p.is(j, "{") {
+			// Static initialization block
+			j = p.match(j) + 1
+			continue
+		}
+		generator := false
+		if p.is(j, "*") {
+			generator = true
+			j++
+		}
+
+		var name string
+		if p.is(j, "[") {
+			close := p.match(j)
+			name = p.source(j, close)
+			j = close + 1
+		} else {
+			name = p.tok(j).text
+			if p.tok(j).kind == tokString {
+				name = name[1 : len(name)-1]
+			}
+			j++
+		}
+		optional := false
+		if p.is(j, "?") || p.is(j, "!") {
+			optional = p.is(j, "?")
+			j++
+		}
+
+		access := codespec.VisibilityPublic
+		switch {
+		case mods["private"] || strings.HasPrefix(name, "#"):
+			access = codespec.VisibilityPrivate
+		case mods["protected"]:
+			access = codespec.VisibilityProtected
+		}
+
+		member := func(fn *codespec.Function) {
+			fn.Name = name
+			fn.Receiver = &codespec.Receiver{Name: "this", Type: typ.Name}
+			fn.Visibility = access
+			fn.Documentation = doc
+			for _, mod := range []string{"static", "abstract", "async"} {
+				if mods[mod] {
+					setExtra(&fn.Extra, mod, true)
+				}
+			}
+			if mods["static"] {
+				fn.Receiver.Name = ""
+			}
+			if mods["get"] {
+				setExtra(&fn.Extra, "accessor", "get")
+			} else if mods["set"] {
+				setExtra(&fn.Extra, "accessor", "set")
+			}
+			if generator {
+				setExtra(&fn.Extra, "generator", true)
+			}
+			methods = append(methods, *fn)
+			typ.Methods = append(typ.Methods, jsMethodSignature(*fn))
+		}
+
+		if p.is(j, "(") || p.is(j, "<") {
+			fn := codespec.Function{}
+			open := j
+			if p.is(open, "<") {
+				open = p.matchAngle(open) + 1
+			}
+			if name == "constructor" && p.is(open, "(") {
+				_, fields := p.parameters(open+1, p.match(open)-1)
+				typ.Fields = append(typ.Fields, fields...)
+			}
+			j = p.signatureAndBody(&fn, j)
+			member(&fn)
+			continue
+		}
+
+		// Property, possibly initialized with a function
+		field := codespec.Field{Name: name, Visibility: access, Documentation: doc}
+		if p.is(j, ":") {
+			end := p.typeEnd(j+1, false)
+			field.Type = p.source(j+1, end)
+			j = end + 1
+		}
+		end := j - 1
+		if p.is(j, "=") {
+			end = p.withoutSemicolon(j, p.statementEnd(j+1))
+			if fn, ok := p.functionExpression(j+1, end); ok {
+				member(&fn)
+				j = end + 1
+				continue
+			}
+			if field.Type == "" {
+				field.Type = jsLiteralType(p.source(j+1, end))
+			}
+			setExtra(&field.Extra, "default", p.source(j+1, end))
+		}
+		for _, mod := range []string{"static", "readonly"} {
+			if mods[mod] {
+				setExtra(&field.Extra, mod, true)
+			}
+		}
+		if optional {
+			setExtra(&field.Extra, "optional", true)
+		}
+		if name != "" && name != "}" {
+			typ.Fields = append(typ.Fields, field)
+		}
+		j = end + 1
+	}
+	return methods
+}
+
+// jsMethodSignature renders a method the way it is listed on its type
+func jsMethodSignature(fn codespec.Function) string {
+	var params []string
+	for _, param := range fn.Parameters {
+		text := param.Name
+		if _, ok := param.Extra["optional"]; ok {
+			text += "?"
+		}
+		if param.Type != "" {
+			text += ": " + param.Type
+		}
+		if len(param.Default) > 0 {
+			text += " = " + fmtScriptDefault(codespec.DecodeValue(param.Default))
+		}
+		params = append(params, text)
+	}
+	signature := fn.Name + "(" + strings.Join(params, ", ") + ")"
+	if len(fn.Returns) > 0 {
+		signature += ": " + fn.Returns[0].Type
+	}
+	return signature
+}
+
+// fmtScriptDefault renders a parameter default as source
+func fmtScriptDefault(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return formatJSON(value)
+}
+
+// interfaceDecl parses an interface declaration
+func (p *scriptParser) interfaceDecl(i int) (codespec.TypeDefinition, int) {
+	typ := codespec.TypeDefinition{Kind: codespec.KindInterface, Name: p.tok(i + 1).text}
+	i += 2
+	if p.is(i, "<") {
+		i = p.matchAngle(i) + 1
+	}
+	if p.is(i, "extends") {
+		var extends []string
+		for _, part := range p.split(i+1, p.indexOf(i, "{")-1, ",") {
+			extends = append(extends, p.source(part[0], part[1]))
+		}
+		setExtra(&typ.Extra, "extends", extends)
+	}
+	open := p.indexOf(i, "{")
+	close := p.match(open)
+	p.typeMembers(&typ, open+1, close-1)
+	return typ, close + 1
+}
+
+// typeMembers parses the members of an interface or object type
+func (p *scriptParser) typeMembers(typ *codespec.TypeDefinition, from, to int) {
+	for j := from; j <= to; {
+		if p.is(j, ";") || p.is(j, ",") {
+			j++
+			continue
+		}
+		doc := p.tok(j).doc
+		readonly := false
+		if p.is(j, "readonly") && !p.is(j+1, ":") && !p.is(j+1, "?") && !p.is(j+1, "(") {
+			readonly = true
+			j++
+		}
+
+		start := j
+		var name string
+		switch {
+		case p.is(j, "(") || p.is(j, "<") || p.is(j, "new"):
+			// Call and construct signatures
+			end := p.memberEnd(j, to)
+			typ.Methods = append(typ.Methods, p.source(start, p.withoutSemicolon(start, end)))
+			j = end + 1
+			continue
+		case p.is(j, "["):
+			close := p.match(j)
+			name = p.source(j, close)
+			j = close + 1
+		default:
+			name = p.tok(j).text
+			if p.tok(j).kind == tokString {
+				name = name[1 : len(name)-1]
+			}
+			j++
+		}
+		optional := false
+		if p.is(j, "?") {
+			optional = true
+			j++
+		}
+
+		if p.is(j, "(") || p.is(j, "<") {
+			end := p.memberEnd(j, to)
+			typ.Methods = append(typ.Methods, p.source(start, p.withoutSemicolon(start, end)))
+			j = end + 1
+			continue
+		}
+
+		field := codespec.Field{Name: name, Visibility: codespec.VisibilityPublic, Documentation: doc}
+		if p.is(j, ":") {
+			end := min(p.typeEnd(j+1, false), to)
+			field.Type = p.source(j+1, end)
+			j = end + 1
+		}
+		if optional {
+			setExtra(&field.Extra, "optional", true)
+		}
+		if readonly {
+			setExtra(&field.Extra, "readonly", true)
+		}
+		typ.Fields = append(typ.Fields, field)
+		if j == start {
+			j++
+		}
+	}
+}
+
+// memberEnd returns the index of the last token of a method signature in an
+// interface body
+func (p *scriptParser) memberEnd(j, to int) int {
+	if p.is(j, "new") {
+		j++
+	}
+	if p.is(j, "<") {
+		j = p.matchAngle(j) + 1
+	}
+	if p.is(j, "(") {
+		j = p.match(j)
+	}
+	if p.is(j+1, ":") {
+		j = p.typeEnd(j+2, false)
+	}
+	if p.is(j+1, ";") || p.is(j+1, ",") {
+		j++
+	}
+	return min(j, to)
+}
+
+// typeAlias parses a type alias. Object types keep their members as fields,
+// unions become union kinds and anything else a single unnamed field.
+func (p *scriptParser) typeAlias(i int) (codespec.TypeDefinition, int) {
+	typ := codespec.TypeDefinition{Kind: codespec.KindTypeAlias, Name: p.tok(i + 1).text}
+	i += 2
+	if p.is(i, "<") {
+		i = p.matchAngle(i) + 1
+	}
+	i++ // =
+	end := p.typeEnd(i, false)
+	next := end + 1
+	if p.is(next, ";") {
+		next++
+	}
+
+	switch {
+	case p.is(i, "{") && p.match(i) == end:
+		typ.Kind = codespec.KindInterface
+		p.typeMembers(&typ, i+1, end-1)
+	default:
+		if parts := p.split(i, end, "|"); len(parts) > 1 {
+			typ.Kind = codespec.KindUnion
+		}
+		typ.Fields = []codespec.Field{{Type: p.source(i, end), Visibility: codespec.VisibilityPublic}}
+	}
+	return typ, next
+}
+
+// enumDecl parses an enum declaration
+func (p *scriptParser) enumDecl(i int) (codespec.TypeDefinition, int) {
+	typ := codespec.TypeDefinition{Kind: codespec.KindEnum}
+	if p.is(i, "const") {
+		setExtra(&typ.Extra, "const", true)
+		i++
+	}
+	typ.Name = p.tok(i + 1).text
+	open := i + 2
+	close := p.match(open)
+	for _, part := range p.split(open+1, close-1, ",") {
+		if part[0] > part[1] {
+			continue
+		}
+		name := p.tok(part[0]).text
+		if p.tok(part[0]).kind == tokString {
+			name = name[1 : len(name)-1]
+		}
+		field := codespec.Field{Name: name, Type: "number", Visibility: codespec.VisibilityPublic, Documentation: p.tok(part[0]).doc}
+		if p.is(part[0]+1, "=") {
+			value := p.source(part[0]+2, part[1])
+			if t := jsLiteralType(value); t != "" {
+				field.Type = t
+			}
+			setExtra(&field.Extra, "default", value)
+		}
+		typ.Fields = append(typ.Fields, field)
+	}
+	return typ, close + 1
+}
+
+// variableDecl parses a module level const, let or var declaration.
+// Functions assigned to a name become functions, const literals constants
+// and require calls imports.
+func (p *scriptParser) variableDecl(i int, doc string, exported bool) int {
+	kind := p.tok(i).text
+	end := p.statementEnd(i)
+	last := p.withoutSemicolon(i, end)
+
+	for _, part := range p.split(i+1, last, ",") {
+		j, partEnd := part[0], part[1]
+		if j > partEnd {
+			continue
+		}
+		var name string
+		if p.is(j, "{") || p.is(j, "[") {
+			close := p.match(j)
+			name = p.source(j, close)
+			j = close + 1
+		} else {
+			name = p.tok(j).text
+			j++
+		}
+		if p.is(j, "!") {
+			j++
+		}
+		typ := ""
+		if p.is(j, ":") {
+			typeEnd := min(p.typeEnd(j+1, false), partEnd)
+			typ = p.source(j+1, typeEnd)
+			j = typeEnd + 1
+		}
+		if exported {
+			p.exported[name] = true
+		}
+		if !p.is(j, "=") {
+			p.spec.Variables = append(p.spec.Variables, codespec.Variable{Name: name, Type: typ, Doc: doc})
+			continue
+		}
+		value := p.source(j+1, partEnd)
+
+		if p.is(j+1, "require") && p.is(j+2, "(") && p.tok(j+3).kind == tokString && p.match(j+2) == partEnd {
+			text := p.tok(j + 3).text
+			imp := codespec.Import{Path: text[1 : len(text)-1]}
+			if strings.HasPrefix(name, "{") {
+				setExtra(&imp.Extra, "names", strings.Fields(strings.NewReplacer("{", "", "}", "", ",", " ").Replace(name)))
+			} else {
+				imp.Alias = name
+				p.modules[name] = true
+			}
+			p.spec.Imports = append(p.spec.Imports, imp)
+			continue
+		}
+		if fn, ok := p.functionExpression(j+1, partEnd); ok {
+			fn.Name = name
+			fn.Documentation = doc
+			if typ != "" {
+				setExtra(&fn.Extra, "type", typ)
+			}
+			p.addFunction(fn, partEnd+1, exported)
+			continue
+		}
+
+		literal := jsLiteralType(value)
+		if kind == "const" && (literal == "string" || literal == "number" || literal == "boolean") {
+			if typ == "" {
+				typ = literal
+			}
+			p.spec.Constants = append(p.spec.Constants, codespec.Constant{Name: name, Type: typ, Value: codespec.RawValue(jsConstantValue(value)), Doc: doc})
+			continue
+		}
+		if typ == "" {
+			typ = literal
+		}
+		variable := codespec.Variable{Name: name, Type: typ, Doc: doc, Initialization: map[string]any{"code": value}}
+		if kind != "const" {
+			setExtra(&variable.Extra, "kind", kind)
+		}
+		p.spec.Variables = append(p.spec.Variables, variable)
+	}
+	return end + 1
+}
+
+// jsLiteralType infers the type of a literal value
+func jsLiteralType(code string) string {
+	code = strings.TrimSpace(code)
+	switch {
+	case code == "":
+		return ""
+	case code == "true" || code == "false":
+		return "boolean"
+	case strings.HasPrefix(code, "[") && strings.HasSuffix(code, "]"):
+		return "array"
+	case strings.HasPrefix(code, "{") && strings.HasSuffix(code, "}"):
+		return "object"
+	case strings.HasPrefix(code, "`") && strings.HasSuffix(code, "`") && !strings.Contains(code, "${"):
+		return "string"
+	}
+	if _, ok := jsStringValue(code); ok {
+		return "string"
+	}
+	if _, ok := pyNumber(code); ok {
+		return "number"
+	}
+	return ""
+}
+
+// jsConstantValue converts a literal into its JSON value
+func jsConstantValue(code string) any {
+	code = strings.TrimSpace(code)
+	if s, ok := jsStringValue(code); ok {
+		return s
+	}
+	if strings.HasPrefix(code, "`") {
+		return code[1 : len(code)-1]
+	}
+	return jsDefaultValue(code)
+}
+
+// jsStringValue decodes a single or double quoted string literal
+func jsStringValue(code string) (string, bool) {
+	if len(code) < 2 || (code[0] != '"' && code[0] != '\'') || quotedEnd([]byte(code), 0, false) != len(code) {
+		return "", false
+	}
+	if code[0] == '\'' {
+		code = `"` + strings.ReplaceAll(strings.ReplaceAll(code[1:len(code)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	var s string
+	if err := json.Unmarshal([]byte(code), &s); err != nil {
+		if unquoted, err := strconv.Unquote(code); err == nil {
+			return unquoted, true
+		}
+		return code[1 : len(code)-1], true
+	}
+	return s, true
+}
+
+// statements converts the statements between tokens from and to
+func (p *scriptParser) statements(from, to int) []codespec.Statement {
+	var statements []codespec.Statement
+	for i := from; i <= to; {
+		st, next, ok := p.statement(i, to)
+		if ok {
+			statements = append(statements, st)
+		}
+		if next <= i {
+			next = i + 1
+		}
+		i = next
+	}
+	return statements
+}
+
+// body converts the body of a control flow statement, either a block or a
+// single statement
+func (p *scriptParser) body(i, to int) ([]codespec.Statement, int) {
+	if p.is(i, "{") {
+		close := p.match(i)
+		return p.statements(i+1, close-1), close + 1
+	}
+	st, next, ok := p.statement(i, to)
+	if !ok {
+		return nil, next
+	}
+	return []codespec.Statement{st}, next
+}
+
+// statement converts the statement starting at i and returns the index of
+// the following one
+func (p *scriptParser) statement(i, to int) (codespec.Statement, int, bool) {
+	t := p.tok(i)
+	if p.is(i, ";") {
+		return codespec.Statement{}, i + 1, false
+	}
+
+	if t.kind == tokIdent {
+		switch t.text {
+		case "if":
+			if !p.is(i+1, "(") {
+				break
+			}
+			close := p.match(i + 1)
+			st := codespec.Statement{Type: codespec.StatementIf, Condition: p.source(i+2, close-1)}
+			var next int
+			st.TrueBranch, next = p.body(close+1, to)
+			if p.is(next, "else") {
+				if p.is(next+1, "if") {
+					elseIf, after, _ := p.statement(next+1, to)
+					st.FalseBranch = []codespec.Statement{elseIf}
+					next = after
+				} else {
+					st.FalseBranch, next = p.body(next+1, to)
+				}
+			}
+			return st, next, true
+
+		case "for":
+			j := i + 1
+			st := codespec.Statement{Type: codespec.StatementFor}
+			if p.is(j, "await") {
+				st.Description = "await"
+				j++
+			}
+			if !p.is(j, "(") {
+				break
+			}
+			close := p.match(j)
+			st.Condition = p.source(j+1, close-1)
+			seps := []string{"of", "in"}
+			if p.java {
+				seps = []string{":"}
+			}
+			for _, sep := range seps {
+				if parts := p.split(j+1, close-1, ";"); len(parts) == 1 && p.indexOf(j+1, sep) < close {
+					st.Operation = "range"
+				}
+			}
+			var next int
+			st.TrueBranch, next = p.body(close+1, to)
+			return st, next, true
+
+		case "while":
+			if !p.is(i+1, "(") {
+				break
+			}
+			close := p.match(i + 1)
+			st := codespec.Statement{Type: codespec.StatementFor, Operation: "while", Condition: p.source(i+2, close-1)}
+			var next int
+			st.TrueBranch, next = p.body(close+1, to)
+			return st, next, true
+
+		case "do":
+			st := codespec.Statement{Type: codespec.StatementFor, Operation: "do_while"}
+			var next int
+			st.TrueBranch, next = p.body(i+1, to)
+			if p.is(next, "while") && p.is(next+1, "(") {
+				close := p.match(next + 1)
+				st.Condition = p.source(next+2, close-1)
+				next = close + 1
+				if p.is(next, ";") {
+					next++
+				}
+			}
+			return st, next, true
+
+		case "switch":
+			if !p.is(i+1, "(") {
+				break
+			}
+			close := p.match(i + 1)
+			st := codespec.Statement{Type: codespec.StatementSwitch, Condition: p.source(i+2, close-1)}
+			open := close + 1
+			end := p.match(open)
+			for j := open + 1; j < end; {
+				label := "default"
+				if p.is(j, "case") {
+					colon := p.caseColon(j+1, end)
+					label = "case " + p.source(j+1, colon-1)
+					j = colon + 1
+				} else if p.isDefaultLabel(j) {
+					j += 2
+				} else {
+					j++
+					continue
+				}
+				c := codespec.Case{Type: label}
+				if p.is(j-1, "->") && p.is(j, "{") {
+					// case X -> { ... }
+					close := p.match(j)
+					c.Statements = p.statements(j+1, close-1)
+					st.Cases = append(st.Cases, c)
+					j = close + 1
+					continue
+				}
+				for j < end && !p.is(j, "case") && !p.isDefaultLabel(j) {
+					st, next, ok := p.statement(j, end-1)
+					if ok {
+						c.Statements = append(c.Statements, st)
+					}
+					if next <= j {
+						next = j + 1
+					}
+					j = next
+				}
+				st.Cases = append(st.Cases, c)
+			}
+			return st, end + 1, true
+
+		case "return":
+			end := p.statementEnd(i)
+			last := p.withoutSemicolon(i, end)
+			return codespec.Statement{Type: codespec.StatementReturn, Code: p.code(i, last), Value: p.source(i+1, last)}, end + 1, true
+
+		case "const", "let", "var", "using":
+			end := p.statementEnd(i)
+			last := p.withoutSemicolon(i, end)
+			st := codespec.Statement{Type: codespec.StatementVariableDeclaration, Code: p.code(i, last)}
+			if parts := p.split(i+1, last, ","); len(parts) == 1 {
+				if eq := p.assignmentIndex(parts[0][0], last); eq >= 0 {
+					st.Operation = p.callee(p.skipAwait(eq+1), last)
+				}
+			}
+			return st, end + 1, true
+
+		case "try":
+			end := i + 1
+			if p.is(end, "(") {
+				// try-with-resources
+				end = p.match(end) + 1
+			}
+			end = p.match(end)
+			for p.is(end+1, "catch") || p.is(end+1, "finally") {
+				j := end + 2
+				if p.is(j, "(") {
+					j = p.match(j) + 1
+				}
+				end = p.match(j)
+			}
+			return codespec.Statement{Type: codespec.StatementFunctionBody, Code: p.code(i, end)}, end + 1, true
+
+		case "function", "class":
+			end := p.statementEnd(i)
+			return codespec.Statement{Type: codespec.StatementFunctionBody, Code: p.code(i, p.withoutSemicolon(i, end))}, end + 1, true
+		}
+	}
+
+	if p.is(i, "{") {
+		close := p.match(i)
+		return codespec.Statement{Type: codespec.StatementFunctionBody, Code: p.code(i, close)}, close + 1, true
+	}
+
+	end := min(p.statementEnd(i), to)
+	last := p.withoutSemicolon(i, end)
+	if p.java && p.localDeclaration(i, last) {
+		st := codespec.Statement{Type: codespec.StatementVariableDeclaration, Code: p.code(i, last)}
+		if parts := p.split(i, last, ","); len(parts) == 1 {
+			if eq := p.assignmentIndex(i, last); eq >= 0 {
+				st.Operation = p.callee(eq+1, last)
+			}
+		}
+		return st, end + 1, true
+	}
+	return p.expressionStatement(i, last), end + 1, true
+}
+
+// isDefaultLabel reports whether a default case label starts at i
+func (p *scriptParser) isDefaultLabel(i int) bool {
+	return p.is(i, "default") && (p.is(i+1, ":") || p.is(i+1, "->"))
+}
+
+// caseColon returns the index of the colon (or Java arrow) ending a case
+// label, skipping the colons of conditional expressions
+func (p *scriptParser) caseColon(i, to int) int {
+	conditionals := 0
+	for j := i; j < to; j++ {
+		switch {
+		case p.is(j, "(") || p.is(j, "[") || p.is(j, "{"):
+			j = p.match(j)
+		case p.is(j, "?"):
+			conditionals++
+		case p.is(j, ":"):
+			if conditionals == 0 {
+				return j
+			}
+			conditionals--
+		case p.is(j, "->") && p.java:
+			return j
+		}
+	}
+	return to
+}
+
+// jsAssignmentOperators lists the assignment punctuators
+var jsAssignmentOperators = map[string]bool{
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true, "**=": true, "<<=": true,
+	"&=": true, "|=": true, "^=": true, "&&=": true, "||=": true, "??=": true,
+}
+
+// assignmentIndex returns the index of the top level assignment operator
+// between tokens from and to, or -1
+func (p *scriptParser) assignmentIndex(from, to int) int {
+	for j := from; j <= to; j++ {
+		t := p.tok(j)
+		if t.kind != tokPunct {
+			continue
+		}
+		switch {
+		case t.text == "(" || t.text == "[" || t.text == "{":
+			j = p.match(j)
+		case t.text == "=>":
+			return -1
+		case jsAssignmentOperators[t.text]:
+			// >= and >>= are split into single characters
+			if prev := p.tok(j - 1); t.text == "=" && prev.text == ">" && prev.end == t.start {
+				continue
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// assignmentOperator renders the operator at j, joining shifts split by the tokenizer
+func (p *scriptParser) assignmentOperator(j int) string {
+	op := p.tok(j).text
+	for k := j - 1; op == "=" && p.is(k, ">") && p.tok(k).end == p.tok(k+1).start; k-- {
+		op = ">" + op
+	}
+	return op
+}
+
+// skipAwait skips an await keyword at i
+func (p *scriptParser) skipAwait(i int) int {
+	if p.is(i, "await") {
+		return i + 1
+	}
+	return i
+}
+
+// callee returns the called expression when tokens from through to form a
+// single call, or ""
+func (p *scriptParser) callee(from, to int) string {
+	if p.is(from, "new") {
+		return ""
+	}
+	j := from
+	for p.isIdent(j) && (p.is(j+1, ".") || p.is(j+1, "?.")) {
+		j += 2
+	}
+	if !p.isIdent(j) {
+		return ""
+	}
+	open := j + 1
+	if p.is(open, "<") {
+		open = p.matchAngle(open) + 1
+	}
+	if p.is(open, "?.") {
+		open++
+	}
+	if !p.is(open, "(") || p.match(open) != to {
+		return ""
+	}
+	return strings.ReplaceAll(p.source(from, j), "?.", ".")
+}
+
+// expressionStatement classifies an expression statement
+func (p *scriptParser) expressionStatement(from, to int) codespec.Statement {
+	code := p.code(from, to)
+	i := p.skipAwait(from)
+
+	if p.is(i, "++") || p.is(i, "--") || p.is(to, "++") || p.is(to, "--") {
+		op := p.tok(to).text
+		if p.is(i, "++") || p.is(i, "--") {
+			op = p.tok(i).text
+		}
+		return codespec.Statement{Type: codespec.StatementAssignment, Operation: op, Code: code}
+	}
+
+	if eq := p.assignmentIndex(i, to); eq >= 0 {
+		st := codespec.Statement{Type: codespec.StatementAssignment, Code: code}
+		if op := p.assignmentOperator(eq); op != "=" {
+			st.Operation = op
+			return st
+		}
+		rhs := p.skipAwait(eq + 1)
+		st.Operation = p.callee(rhs, to)
+		for j := rhs; j <= to; j++ {
+			if p.is(j, "as") && p.tok(j).kind == tokIdent {
+				st.Type = codespec.StatementTypeAssertion
+			}
+			if p.is(j, "(") || p.is(j, "[") || p.is(j, "{") {
+				j = p.match(j)
+			}
+		}
+		return st
+	}
+
+	st := codespec.Statement{Type: codespec.StatementFunctionBody, Code: code}
+	if callee := p.callee(i, to); callee != "" {
+		st.Type = codespec.StatementFunctionCall
+		st.Operation = callee
+		if dot := strings.LastIndexByte(callee, '.'); dot >= 0 {
+			switch {
+			case strings.HasSuffix(callee, ".push") || strings.HasSuffix(callee, ".unshift"),
+				p.java && (strings.HasSuffix(callee, ".add") || strings.HasSuffix(callee, ".addAll")):
+				st.Type = codespec.StatementAppend
+			case !p.modules[callee[:strings.IndexByte(callee, '.')]]:
+				// namespace.fn is a function call, value.method a method call
+				st.Type = codespec.StatementMethodCall
+			}
+		}
+	}
+	if i > from {
+		st.Description = "await"
+	}
+	return st
+}
+
+// decisions counts the decision points between tokens from and to
+func (p *scriptParser) decisions(from, to int) int {
+	count := 1
+	for j := from; j <= to; j++ {
+		t := p.tok(j)
+		switch {
+		case t.kind == tokIdent && (t.text == "if" || t.text == "for" || t.text == "while" || t.text == "case" || t.text == "catch"):
+			count++
+		case t.kind == tokPunct && (t.text == "&&" || t.text == "||" || t.text == "??" || t.text == "?"):
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,210 @@
+package deepspec
+
+import (
+	"testing"
+
+	"github.com/commercetools/deepspec/pkg/codespec"
+)
+
+const cartScript = `/** Shopping cart helpers. */
+import { EventEmitter } from "events";
+import * as path from "path";
+import type { Money } from "./money";
+
+/** Maximum items per cart. */
+export const MAX_ITEMS = 50;
+const currency = "EUR";
+
+/** A line item. */
+export interface Item {
+  sku: string;
+  quantity?: number;
+  readonly note: string | null;
+  total(price: Money): Money;
+}
+
+export type Sku = string;
+
+export enum Status {
+  Open = "open",
+  Closed = "closed",
+}
+
+/** A cart of items. */
+export class Cart extends EventEmitter {
+  private items: Item[] = [];
+  protected owner: string;
+  static count = 0;
+
+  constructor(owner: string) {
+    super();
+    this.owner = owner;
+  }
+
+  /** Adds an item. */
+  add(sku: Sku, quantity = 1): Item {
+    if (quantity <= 0) {
+      throw new Error("quantity must be positive");
+    }
+    const item = { sku, quantity, note: null } as Item;
+    this.items.push(item);
+    return item;
+  }
+
+  async checkout(): Promise<void> {
+    for (const item of this.items) {
+      this.emit("checkout", item);
+    }
+  }
+
+  #secret(): void {}
+}
+
+export const total = (cart: Cart, discount: number = 0): number => {
+  return cart.size * (1 - discount);
+};
+
+function helper(name: string): string {
+  return path.join("carts", name);
+}
+
+export default function createCart(owner: string): Cart {
+  return new Cart(owner);
+}
+`
+
+const utilScript = `const fs = require("fs");
+
+const RETRIES = 3;
+
+/**
+ * Reads a file, retrying on failure.
+ * @param {string} file
+ */
+async function readRetry(file, retries = RETRIES) {
+  for (let i = 0; i < retries; i++) {
+    try {
+      return await fs.promises.readFile(file, "utf8");
+    } catch (err) {
+      if (i === retries - 1) throw err;
+    }
+  }
+}
+
+function internal() {
+  return /ab+c/.test("x" + "y");
+}
+
+class Queue {
+  constructor() {
+    this.items = [];
+  }
+  push(item) {
+    this.items.push(item);
+  }
+}
+
+module.exports = { readRetry, Queue };
+`
+
+const viewScript = `export function Greeting({ name }) {
+  return <div className="greeting">Hello {name}</div>;
+}
+`
+
+func TestScriptExtractor(t *testing.T) {
+	files := map[string]string{"src/cart.ts": cartScript, "lib/util.js": utilScript, "lib/view.jsx": viewScript}
+
+	t.Run("typescript", func(t *testing.T) {
+		checkSpecFacts(t, extractFixture(t, codespec.LanguageTypeScript, files, "src/cart.ts"), []specFact{
+			{name: "module", got: func(s *codespec.Spec) any { return []string{s.Language, s.Module} }, want: `["typescript", "src/cart"]`},
+			{name: "imports", got: func(s *codespec.Spec) any { return s.Imports }, want: `[
+			{"path": "events", "names": ["EventEmitter"]}, {"path": "path", "alias": "path"}, {"path": "./money", "names": ["Money"], "type_only": true}]`},
+			{name: "interface", got: func(s *codespec.Spec) any { return findType(s, "Item") }, want: `{
+			"name": "Item", "kind": "interface", "visibility": "public", "documentation": "A line item.",
+			"fields": [
+				{"name": "sku", "type": "string", "visibility": "public"},
+				{"name": "quantity", "type": "number", "visibility": "public", "optional": true},
+				{"name": "note", "type": "string | null", "visibility": "public", "readonly": true}
+			],
+			"methods": ["total(price: Money): Money"]}`},
+			{name: "type alias", got: func(s *codespec.Spec) any { return findType(s, "Sku") }, want: `{
+			"name": "Sku", "kind": "type_alias", "visibility": "public", "fields": [{"name": "", "type": "string", "visibility": "public"}]}`},
+			{name: "enum", got: func(s *codespec.Spec) any { return findType(s, "Status").Fields }, want: `[
+			{"name": "Open", "type": "string", "visibility": "public", "default": "\"open\""},
+			{"name": "Closed", "type": "string", "visibility": "public", "default": "\"closed\""}]`},
+			{name: "class", got: func(s *codespec.Spec) any { return findType(s, "Cart") }, want: `{
+			"name": "Cart", "kind": "class", "visibility": "public", "documentation": "A cart of items.", "extends": "EventEmitter",
+			"fields": [
+				{"name": "items", "type": "Item[]", "visibility": "private", "default": "[]"},
+				{"name": "owner", "type": "string", "visibility": "protected"},
+				{"name": "count", "type": "number", "visibility": "public", "default": "0", "static": true}
+			],
+			"methods": ["constructor(owner: string)", "add(sku: Sku, quantity = 1): Item", "checkout(): Promise<void>", "#secret()"]}`},
+			{name: "method", got: func(s *codespec.Spec) any {
+				fn := findFunction(s, "add")
+				return []any{fn.Receiver, fn.Documentation, fn.Parameters, fn.Returns}
+			}, want: `[{"name": "this", "type": "Cart"}, "Adds an item.", [{"name": "sku", "type": "Sku"}, {"name": "quantity", "type": "", "default": 1}], [{"type": "Item"}]]`},
+			{name: "async method", got: func(s *codespec.Spec) any { return findFunction(s, "checkout").Extra }, want: `{"async": true}`},
+			{name: "private name", got: func(s *codespec.Spec) any { return findFunction(s, "#secret").Visibility }, want: `"private"`},
+			{name: "exported arrow function", got: func(s *codespec.Spec) any {
+				fn := findFunction(s, "total")
+				return []any{fn.Visibility, fn.Parameters, fn.Returns}
+			}, want: `["public", [{"name": "cart", "type": "Cart"}, {"name": "discount", "type": "number", "default": 0}], [{"type": "number"}]]`},
+			{name: "unexported function", got: func(s *codespec.Spec) any { return findFunction(s, "helper").Visibility }, want: `"private"`},
+			{name: "default export", got: func(s *codespec.Spec) any { return findFunction(s, "createCart").Visibility }, want: `"public"`},
+			{name: "constants", got: func(s *codespec.Spec) any { return s.Constants }, want: `[
+			{"name": "MAX_ITEMS", "type": "number", "value": 50, "doc": "Maximum items per cart.", "exported": true},
+			{"name": "currency", "type": "string", "value": "EUR", "exported": false}]`},
+		})
+	})
+	t.Run("javascript", func(t *testing.T) {
+		checkSpecFacts(t, extractFixture(t, codespec.LanguageJavaScript, files, "lib/util.js"), []specFact{
+			{name: "module", got: func(s *codespec.Spec) any { return []any{s.Language, s.Module, s.Imports} }, want: `["javascript", "lib/util", [{"path": "fs", "alias": "fs"}]]`},
+			{name: "commonjs exports", got: func(s *codespec.Spec) any {
+				return []string{findFunction(s, "readRetry").Visibility, findFunction(s, "internal").Visibility, findType(s, "Queue").Visibility}
+			}, want: `["public", "private", "public"]`},
+			{name: "jsdoc", got: func(s *codespec.Spec) any {
+				fn := findFunction(s, "readRetry")
+				return []any{fn.Documentation, fn.Parameters, fn.Extra}
+			}, want: `["Reads a file, retrying on failure.\n@param {string} file", [{"name": "file", "type": ""}, {"name": "retries", "type": "", "default": "RETRIES"}], {"async": true}]`},
+		})
+	})
+	t.Run("jsx", func(t *testing.T) {
+		checkSpecFacts(t, extractFixture(t, codespec.LanguageJavaScript, files, "lib/view.jsx"), []specFact{
+			{name: "element", got: func(s *codespec.Spec) any { return findFunction(s, "Greeting").BodyStatements }, want: `[
+			{"type": "return", "code": "return <div className=\"greeting\">Hello {name}</div>", "value": "<div className=\"greeting\">Hello {name}</div>"}]`},
+		})
+	})
+}
+
+func TestScriptExtractorStrayClosers(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		src  string
+		// want are the body statements of f, when it is declared
+		want string
+	}{
+		{name: "stray brace", file: "a.ts", src: "}"},
+		{name: "stray parenthesis and bracket", file: "a.ts", src: ") ]"},
+		{name: "stray brace after a function", file: "a.ts", src: "function f() { } }", want: `null`},
+		{name: "regex after if", file: "a.ts", src: "export function f(s: string) { if (s) /}/.test(s); }", want: `[
+			{"type": "if_statement", "condition": "s", "true_branch": [{"type": "function_body", "code": "/}/.test(s)"}]}]`},
+		{name: "regex after for", file: "a.ts", src: "function f(s: string) { for (;;) /}/g.exec(s); }", want: `[
+			{"type": "for_loop", "condition": ";;", "true_branch": [{"type": "function_body", "code": "/}/g.exec(s)"}]}]`},
+		{name: "javascript stray brace", file: "a.js", src: "}"},
+		{name: "javascript stray brace after a function", file: "a.js", src: "function f() { } }", want: `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := extractWithin(t, scriptLanguage(tt.file), tt.file, tt.src)
+			if tt.want == "" {
+				return
+			}
+			checkSpecFacts(t, spec, []specFact{
+				{name: "body", got: func(s *codespec.Spec) any { return findFunction(s, "f").BodyStatements }, want: tt.want},
+			})
+		})
+	}
+}
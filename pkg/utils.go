@@ -6,7 +6,13 @@ import (
 	"time"
 )
 
-const logFilePath = "out.log"
+// logFilePath is the file LogToFile appends to
+var logFilePath = "out.log"
+
+// SetLogFile changes the file LogToFile appends to
+func SetLogFile(path string) {
+	logFilePath = path
+}
 
 // LogToFile writes a log message to a file for debugging
 func LogToFile(format string, args ...interface{}) {
@@ -0,0 +1,19 @@
+package deepspec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain keeps the debug log of every test out of the package tree
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "deepspec-log")
+	if err != nil {
+		panic(err)
+	}
+	SetLogFile(filepath.Join(dir, "out.log"))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
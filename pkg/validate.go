@@ -0,0 +1,435 @@
+package deepspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/commercetools/deepspec/schemas"
+)
+
+// ValidationError describes a single schema violation
+type ValidationError struct {
+	Pointer string `json:"pointer"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// Error formats the violation as "line: pointer: message"
+func (e ValidationError) Error() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("%d: %s: %s", e.Line, pointer, e.Message)
+}
+
+var (
+	codeSpecSchemaOnce sync.Once
+	codeSpecSchema     *jsonSchema
+	codeSpecSchemaErr  error
+)
+
+// ValidateSpec validates a code spec document against the embedded
+// code-spec schema and returns every violation found
+func ValidateSpec(data []byte) []ValidationError {
+	codeSpecSchemaOnce.Do(func() {
+		codeSpecSchema, codeSpecSchemaErr = newJSONSchema(schemas.CodeSpec)
+	})
+	if codeSpecSchemaErr != nil {
+		return []ValidationError{{Message: "invalid embedded schema: " + codeSpecSchemaErr.Error()}}
+	}
+	return codeSpecSchema.validateDocument(data)
+}
+
+// ValidateSpecFile reads and validates a single spec file
+func ValidateSpecFile(path string) ([]ValidationError, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateSpec(data), nil
+}
+
+// FindSpecFiles expands files and directories into the JSON files they contain
+func FindSpecFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), ".json") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// jsonSchema validates values against the subset of JSON Schema draft-07
+// used by deepspec: type, enum, const, required, properties,
+// additionalProperties, items, minimum/maximum, minItems/maxItems and local $ref
+type jsonSchema struct {
+	root map[string]any
+}
+
+// newJSONSchema parses a schema document
+func newJSONSchema(data []byte) (*jsonSchema, error) {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	return &jsonSchema{root: root}, nil
+}
+
+// validateDocument decodes a JSON document, validates it and attaches
+// source line numbers to every violation
+func (s *jsonSchema) validateDocument(data []byte) []ValidationError {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		line := 1
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line = lineAt(data, syntaxErr.Offset)
+		}
+		return []ValidationError{{Line: line, Message: "invalid JSON: " + err.Error()}}
+	}
+
+	violations := s.validate(value)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	lines := pointerLines(data)
+	for i := range violations {
+		violations[i].Line = lookupLine(lines, violations[i].Pointer)
+	}
+	return violations
+}
+
+// validate validates a decoded value against the schema root
+func (s *jsonSchema) validate(value any) []ValidationError {
+	var violations []ValidationError
+	s.validateNode(s.root, value, "", &violations)
+	return violations
+}
+
+// validateNode validates value at pointer against a schema node
+func (s *jsonSchema) validateNode(node map[string]any, value any, pointer string, violations *[]ValidationError) {
+	report := func(format string, args ...any) {
+		*violations = append(*violations, ValidationError{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if ref, ok := node["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			report("%v", err)
+			return
+		}
+		s.validateNode(target, value, pointer, violations)
+		return
+	}
+
+	if typ, ok := node["type"]; ok {
+		var allowed []string
+		switch t := typ.(type) {
+		case string:
+			allowed = []string{t}
+		case []any:
+			for _, item := range t {
+				if name, ok := item.(string); ok {
+					allowed = append(allowed, name)
+				}
+			}
+		}
+		if !matchesAnyType(value, allowed) {
+			report("expected %s, got %s", strings.Join(allowed, " or "), jsonTypeName(value))
+			return
+		}
+	}
+
+	if expected, ok := node["const"]; ok && !jsonEqual(expected, value) {
+		report("must be %s", formatJSON(expected))
+	}
+
+	if enum, ok := node["enum"].([]any); ok {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			var options []string
+			for _, candidate := range enum {
+				options = append(options, formatJSON(candidate))
+			}
+			report("%s is not one of [%s]", formatJSON(value), strings.Join(options, ", "))
+		}
+	}
+
+	if number, ok := toFloat(value); ok {
+		if minimum, ok := toFloat(node["minimum"]); ok && number < minimum {
+			report("%s is less than the minimum of %s", formatJSON(value), formatJSON(node["minimum"]))
+		}
+		if maximum, ok := toFloat(node["maximum"]); ok && number > maximum {
+			report("%s is greater than the maximum of %s", formatJSON(value), formatJSON(node["maximum"]))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.validateObject(node, v, pointer, violations)
+	case []any:
+		if minItems, ok := toFloat(node["minItems"]); ok && float64(len(v)) < minItems {
+			report("expected at least %s items, got %d", formatJSON(node["minItems"]), len(v))
+		}
+		if maxItems, ok := toFloat(node["maxItems"]); ok && float64(len(v)) > maxItems {
+			report("expected at most %s items, got %d", formatJSON(node["maxItems"]), len(v))
+		}
+		if items, ok := node["items"].(map[string]any); ok {
+			for i, item := range v {
+				s.validateNode(items, item, pointer+"/"+strconv.Itoa(i), violations)
+			}
+		}
+	}
+}
+
+// validateObject checks required, properties and additionalProperties
+func (s *jsonSchema) validateObject(node map[string]any, object map[string]any, pointer string, violations *[]ValidationError) {
+	if required, ok := node["required"].([]any); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, present := object[key]; !present {
+				*violations = append(*violations, ValidationError{
+					Pointer: pointer,
+					Message: fmt.Sprintf("missing required property %q", key),
+				})
+			}
+		}
+	}
+
+	properties, _ := node["properties"].(map[string]any)
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := pointer + "/" + escapePointer(key)
+		if property, ok := properties[key].(map[string]any); ok {
+			s.validateNode(property, object[key], child, violations)
+			continue
+		}
+		switch additional := node["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*violations = append(*violations, ValidationError{
+					Pointer: child,
+					Message: fmt.Sprintf("additional property %q is not allowed", key),
+				})
+			}
+		case map[string]any:
+			s.validateNode(additional, object[key], child, violations)
+		}
+	}
+}
+
+// resolve looks up a local reference such as #/$defs/function
+func (s *jsonSchema) resolve(ref string) (map[string]any, error) {
+	path, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported schema reference %q", ref)
+	}
+	var node any = s.root
+	for _, part := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+		}
+		node = object[unescapePointer(part)]
+	}
+	target, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+	}
+	return target, nil
+}
+
+// matchesAnyType reports whether value is one of the JSON Schema types
+func matchesAnyType(value any, types []string) bool {
+	actual := jsonTypeName(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON Schema type name of a decoded value
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case int, int64:
+		return "integer"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// toFloat converts decoded JSON numbers into float64
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// jsonEqual compares two decoded JSON values
+func jsonEqual(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return formatJSON(a) == formatJSON(b)
+}
+
+// formatJSON renders a decoded value for messages
+func formatJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// escapePointer escapes a JSON pointer reference token
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// unescapePointer reverses escapePointer
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// pointerLines maps every JSON pointer of a document to its source line
+func pointerLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(pointer string) error
+	walk = func(pointer string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		lines[pointer] = lineAt(data, dec.InputOffset())
+
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				name, _ := key.(string)
+				if err := walk(pointer + "/" + escapePointer(name)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(pointer + "/" + strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+
+	walk("")
+	return lines
+}
+
+// lookupLine finds the line of a pointer, falling back to its closest parent
+func lookupLine(lines map[string]int, pointer string) int {
+	for {
+		if line, ok := lines[pointer]; ok {
+			return line
+		}
+		idx := strings.LastIndex(pointer, "/")
+		if idx < 0 {
+			return 1
+		}
+		pointer = pointer[:idx]
+	}
+}
+
+// lineAt returns the 1-based line number of a byte offset
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
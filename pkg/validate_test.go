@@ -0,0 +1,117 @@
+package deepspec
+
+import (
+	"slices"
+	"testing"
+)
+
+// testSchema exercises every keyword the validator supports
+const testSchema = `{
+	"type": "object",
+	"required": ["name", "kind"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string"},
+		"kind": {"enum": ["struct", "interface"]},
+		"version": {"const": 1},
+		"size": {"type": ["integer", "null"], "minimum": 0, "maximum": 10},
+		"tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string"}},
+		"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+		"fields": {"type": "array", "items": {"$ref": "#/$defs/field"}},
+		"broken": {"$ref": "#/$defs/missing"}
+	},
+	"$defs": {
+		"field": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}, "a/b": {"type": "boolean"}}}
+	}
+}`
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := newJSONSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		// want are the violations as line: pointer: message
+		want []string
+	}{
+		{name: "valid", document: `{"name": "T", "kind": "struct", "version": 1.0, "size": null, "tags": ["a"], "labels": {"x": "y"}, "fields": [{"name": "F", "a/b": true}]}`},
+		{
+			name:     "type",
+			document: "{\n\"name\": 1,\n\"kind\": \"struct\",\n\"size\": 1.5\n}",
+			want:     []string{`2: /name: expected string, got integer`, `4: /size: expected integer or null, got number`},
+		},
+		{
+			name:     "enum and const",
+			document: "{\"name\": \"T\",\n\"kind\": \"class\",\n\"version\": 2}",
+			want:     []string{`2: /kind: "class" is not one of ["struct", "interface"]`, `3: /version: must be 1`},
+		},
+		{
+			name:     "required",
+			document: "{\n  \"fields\": [\n    {}\n  ]\n}",
+			want:     []string{`1: /: missing required property "name"`, `1: /: missing required property "kind"`, `3: /fields/0: missing required property "name"`},
+		},
+		{
+			name:     "additional properties",
+			document: "{\"name\": \"T\", \"kind\": \"struct\",\n\"extra\": true,\n\"labels\": {\"x\": 1}}",
+			want:     []string{`2: /extra: additional property "extra" is not allowed`, `3: /labels/x: expected string, got integer`},
+		},
+		{
+			name:     "references",
+			document: "{\"name\": \"T\", \"kind\": \"struct\", \"fields\": [\n{\"name\": \"F\", \"a/b\": 1}],\n\"broken\": 1}",
+			want:     []string{`3: /broken: unresolvable schema reference "#/$defs/missing"`, `2: /fields/0/a~1b: expected boolean, got integer`},
+		},
+		{
+			name:     "bounds",
+			document: "{\"name\": \"T\", \"kind\": \"struct\",\n\"size\": 11,\n\"tags\": []}",
+			want:     []string{`2: /size: 11 is greater than the maximum of 10`, `3: /tags: expected at least 1 items, got 0`},
+		},
+		{
+			name:     "invalid JSON",
+			document: "{\"name\": \"T\",\n\"kind\": }",
+			want:     []string{`2: /: invalid JSON: invalid character '}' looking for beginning of value`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range schema.validateDocument([]byte(tt.document)) {
+				got = append(got, violation.Error())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violations:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+	}{
+		{name: "valid", document: `{"spec_version": "1.0.0", "language": "go", "module": "m", "file": "a.go", "functions": [{"name": "F", "visibility": "public"}]}`},
+		{
+			name:     "invalid",
+			document: "{\n  \"spec_version\": \"1.0.0\",\n  \"language\": \"go\",\n  \"module\": \"m\",\n  \"functions\": [\n    {\"name\": \"F\", \"visibility\": \"hidden\"}\n  ]\n}",
+			want: []string{
+				`1: /: missing required property "file"`,
+				`6: /functions/0/visibility: "hidden" is not one of ["public", "private", "protected", "internal", "exported", "unexported"]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range ValidateSpec([]byte(tt.document)) {
+				got = append(got, violation.Error())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violations:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package deepspec
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -9,14 +11,12 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-// ViewportComponent represents the viewport for displaying messages
+// ViewportComponent represents the viewport displaying the conversation
 type ViewportComponent struct {
-	viewport    viewport.Model
-	content     []string
-	ready       bool
-	spinner     spinner.Model
-	loaderIndex int
-	showLoader  bool
+	viewport     viewport.Model
+	conversation *Conversation
+	ready        bool
+	spinner      spinner.Model
 }
 
 // NewViewportComponent creates a new viewport component
@@ -26,10 +26,8 @@ func NewViewportComponent() *ViewportComponent {
 	sp.Spinner = spinner.Line
 
 	v := &ViewportComponent{
-		content:     []string{},
-		spinner:     sp,
-		loaderIndex: -1,
-		showLoader:  false,
+		conversation: NewConversation(),
+		spinner:      sp,
 	}
 
 	// Add introductory banner and text
@@ -61,8 +59,13 @@ func (v *ViewportComponent) addIntro() {
 	banner := strings.Join(coloredLines, "\n")
 	intro := "Welcome to deepspec. Enter a message to get started or execute commands using `/`.\n"
 
-	v.content = append(v.content, banner)
-	v.content = append(v.content, intro)
+	v.conversation.Add(RoleInfo, banner)
+	v.conversation.Add(RoleInfo, intro)
+}
+
+// Conversation returns the conversation the viewport displays
+func (v *ViewportComponent) Conversation() *Conversation {
+	return v.conversation
 }
 
 // SetSize updates the viewport dimensions
@@ -80,98 +83,169 @@ func (v *ViewportComponent) SetSize(width, height int) {
 	v.updateContent()
 }
 
-// AddMessage adds a new message to the viewport with text wrapping
+// AddMessage adds an info message, such as help or command output
 func (v *ViewportComponent) AddMessage(message string) {
-	v.content = append(v.content, message)
+	v.conversation.Add(RoleInfo, message)
 	v.updateContent()
 }
 
-// AddUserMessage convenience helper for chat user messages
+// AddUserMessage adds a user turn
 func (v *ViewportComponent) AddUserMessage(text string) {
-	v.AddMessage(UserMessageLabelStyle.Render("➤ ") + UserMessageTextStyle.Render(text))
+	v.conversation.Add(RoleUser, text)
+	v.updateContent()
 }
 
-// StartLoader inserts a loader line and begins animation
+// AddError adds an error that is not the answer to a turn
+func (v *ViewportComponent) AddError(text string) {
+	v.conversation.Add(RoleError, text)
+	v.updateContent()
+}
+
+// StartLoader adds a pending assistant message, shown as a loader, and
+// begins animation
 func (v *ViewportComponent) StartLoader() tea.Cmd {
 	// Reset spinner
 	v.spinner = spinner.New()
 	v.spinner.Style = LoaderStyle
 	v.spinner.Spinner = spinner.Points
-	v.showLoader = true
-	v.AddMessage(LoaderStyle.Render(v.spinner.View()))
-	v.loaderIndex = v.Count() - 1
+	v.conversation.Await()
+	v.updateContent()
 	return v.spinner.Tick
 }
 
 // UpdateLoaderTick handles spinner tick updates
 func (v *ViewportComponent) UpdateLoaderTick(msg tea.Msg) tea.Cmd {
-	if !v.showLoader {
+	if !v.conversation.Pending() {
 		return nil
 	}
 	if tick, ok := msg.(spinner.TickMsg); ok {
 		var cmd tea.Cmd
 		v.spinner, cmd = v.spinner.Update(tick)
-		if v.loaderIndex >= 0 {
-			v.ReplaceMessage(v.loaderIndex, LoaderStyle.Render(v.spinner.View()))
-		}
+		v.updateContent()
 		return cmd
 	}
 	return nil
 }
 
+// StreamLoader appends a piece of streamed response text to the pending
+// message, which replaces the loader
+func (v *ViewportComponent) StreamLoader(text string) {
+	v.conversation.Stream(text)
+	v.updateContent()
+}
+
+// AddToolCall shows a tool call in the middle of a response. The text
+// streamed so far stays above it and the loader moves below.
+func (v *ViewportComponent) AddToolCall(step int, call ToolCall) {
+	v.conversation.AddToolCall(step, call)
+	v.updateContent()
+}
+
+// SetToolResult marks a tool call as finished
+func (v *ViewportComponent) SetToolResult(step int, result ToolResult) {
+	v.conversation.SetToolResult(step, result)
+	v.updateContent()
+}
+
 // CompleteLoader replaces loader with final assistant response
 func (v *ViewportComponent) CompleteLoader(response string) {
-	if v.loaderIndex >= 0 {
-		v.ReplaceMessage(v.loaderIndex, "↳ "+AssistantStyle.Render(response)+"\n")
-	} else {
-		v.AddMessage(AssistantStyle.Render(response) + "\n")
-	}
-	v.loaderIndex = -1
-	v.showLoader = false
+	v.conversation.Complete(response)
+	v.updateContent()
 }
 
-// ErrorLoader replaces loader with an error line
+// ErrorLoader fails the pending message with an error. Text streamed before
+// the error is kept.
 func (v *ViewportComponent) ErrorLoader(errText string) {
-	if v.loaderIndex >= 0 {
-		v.ReplaceMessage(v.loaderIndex, ErrorStyle.Render("Error: "+errText))
-	} else {
-		v.AddMessage(ErrorStyle.Render("Error: " + errText))
-	}
-	v.loaderIndex = -1
-	v.showLoader = false
+	v.conversation.Fail(errText)
+	v.updateContent()
 }
 
-// ReplaceMessage replaces the message at a given index
-func (v *ViewportComponent) ReplaceMessage(index int, message string) {
-	if index < 0 || index >= len(v.content) {
-		return
-	}
-	v.content[index] = message
+// CancelLoader marks the pending message as cancelled. Text streamed before
+// is kept.
+func (v *ViewportComponent) CancelLoader() {
+	v.conversation.Cancel()
 	v.updateContent()
 }
 
 // Count returns number of messages
-func (v *ViewportComponent) Count() int { return len(v.content) }
+func (v *ViewportComponent) Count() int { return v.conversation.Len() }
 
 // Clear clears all messages from the viewport
 func (v *ViewportComponent) Clear() {
-	v.content = []string{}
+	v.conversation.Clear()
 	v.updateContent()
 }
 
-// updateContent updates the viewport content
+// updateContent renders the conversation into the viewport
 func (v *ViewportComponent) updateContent() {
 	if v.ready {
 		// Wrap all content to viewport width
-		wrappedContent := make([]string, len(v.content))
-		for i, msg := range v.content {
-			wrappedContent[i] = lipgloss.NewStyle().Width(v.viewport.Width).Render(msg)
+		messages := v.conversation.Messages()
+		wrappedContent := make([]string, len(messages))
+		for i, message := range messages {
+			// Text streamed before a tool call continues after it
+			interrupted := i+1 < len(messages) && messages[i+1].Role == RoleTool
+			wrappedContent[i] = lipgloss.NewStyle().Width(v.viewport.Width).Render(v.renderMessage(message, interrupted))
 		}
 		v.viewport.SetContent(strings.Join(wrappedContent, "\n"))
 		v.viewport.GotoBottom()
 	}
 }
 
+// renderMessage styles a message by its role and status
+func (v *ViewportComponent) renderMessage(message ConversationMessage, interrupted bool) string {
+	switch message.Role {
+	case RoleUser:
+		return UserMessageLabelStyle.Render("➤ ") + UserMessageTextStyle.Render(message.Text)
+	case RoleError:
+		return ErrorStyle.Render(message.Text)
+	case RoleTool:
+		line := renderToolCall(message.Step, *message.ToolCall)
+		switch message.Status {
+		case StatusDone:
+			line += ToolCallStyle.Render(" ✓")
+		case StatusError:
+			line += ErrorStyle.Render(" ✗ " + message.Error)
+		}
+		return line
+	case RoleAssistant:
+		var lines []string
+		if message.Text != "" || message.Status == StatusDone {
+			lines = append(lines, "↳ "+AssistantStyle.Render(message.Text))
+		}
+		switch message.Status {
+		case StatusPending:
+			if message.Text == "" {
+				lines = append(lines, LoaderStyle.Render(v.spinner.View()))
+			}
+		case StatusDone:
+			if !interrupted {
+				lines[0] += "\n"
+			}
+		case StatusError:
+			lines = append(lines, ErrorStyle.Render("Error: "+message.Error))
+		case StatusCancelled:
+			lines = append(lines, CancelledStyle.Render("Cancelled")+"\n")
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return message.Text
+	}
+}
+
+// renderToolCall renders a tool call shown inline in a response
+func renderToolCall(step int, call ToolCall) string {
+	var args []byte
+	if len(call.Args) > 0 {
+		args, _ = json.Marshal(call.Args)
+	}
+	summary := []rune(call.Name + "(" + string(args) + ")")
+	if len(summary) > 120 {
+		summary = append(summary[:117], []rune("...")...)
+	}
+	return ToolCallStyle.Render(fmt.Sprintf("⚙ %d. %s", step, string(summary)))
+}
+
 // Implement Init method for ViewportComponent
 func (v *ViewportComponent) Init() tea.Cmd {
 	return nil
@@ -0,0 +1,9 @@
+// Package schemas embeds the JSON Schema definitions shipped with deepspec
+package schemas
+
+import _ "embed"
+
+// CodeSpec is the raw code-spec.schema.json document
+//
+//go:embed code-spec.schema.json
+var CodeSpec []byte